@@ -0,0 +1,250 @@
+// Package apierror - пакет для ошибок API с постоянными кодами, кодами ответа HTTP
+// и сообщениями на русском и английском языках
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// Code - постоянный код ошибки, по которому клиенты различают ошибки
+type Code string
+
+// Коды ошибок
+const (
+	CodeBadRequest         Code = "BAD_REQUEST"
+	CodeInvalidJSON        Code = "INVALID_JSON"
+	CodeValidationFailed   Code = "VALIDATION_FAILED"
+	CodeUnauthorized       Code = "UNAUTHORIZED"
+	CodeInvalidCredentials Code = "INVALID_CREDENTIALS"
+	CodeTokenInvalid       Code = "TOKEN_INVALID"
+	CodeEmailNotVerified   Code = "EMAIL_NOT_VERIFIED"
+	CodeMFACodeInvalid     Code = "MFA_CODE_INVALID"
+	CodeMFAAlreadyEnabled  Code = "MFA_ALREADY_ENABLED"
+	CodeMFANotEnabled      Code = "MFA_NOT_ENABLED"
+	CodeMFARequired        Code = "MFA_REQUIRED"
+	CodeForbidden          Code = "FORBIDDEN"
+	CodeNotFound           Code = "NOT_FOUND"
+	CodeMethodNotAllowed   Code = "METHOD_NOT_ALLOWED"
+	CodeAlreadyExists      Code = "ALREADY_EXISTS"
+	CodeUserEmailTaken     Code = "USER_EMAIL_TAKEN"
+	CodeUserNicknameTaken  Code = "USER_NICKNAME_TAKEN"
+	CodeReferenceNotFound  Code = "REFERENCE_NOT_FOUND"
+	CodePermissionInUse    Code = "PERMISSION_IN_USE"
+	CodeGroupHasAPIKeys    Code = "GROUP_HAS_API_KEYS"
+	CodeTooManyRequests    Code = "TOO_MANY_REQUESTS"
+	CodeInternal           Code = "INTERNAL_ERROR"
+)
+
+// Коды ошибок в полях запроса
+const (
+	FieldRequired = "REQUIRED"
+	FieldInvalid  = "INVALID"
+	FieldTaken    = "TAKEN"
+	FieldNotFound = "NOT_FOUND"
+	FieldExpired  = "EXPIRED"
+)
+
+// FieldError - ошибка в поле запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Error - ошибка API: код, код ответа HTTP, ошибки в полях и исходная ошибка
+type Error struct {
+	Code   Code
+	Status int
+	Fields []FieldError
+	// Сообщение вместо сообщения по умолчанию для кода (не переводится)
+	Message string
+	// Исходная ошибка: записывается в журнал, но не отдаётся клиенту
+	Cause error
+}
+
+// Error - Текст ошибки на русском языке
+func (e *Error) Error() string {
+	return e.Localize(Russian)
+}
+
+// Unwrap - Исходная ошибка
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Localize - Сообщение на языке lang
+func (e *Error) Localize(lang string) string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return message(messages, string(e.Code), lang)
+}
+
+// LocalizeFields - Ошибки в полях с сообщениями на языке lang
+func (e *Error) LocalizeFields(lang string) []FieldError {
+	fields := make([]FieldError, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		if field.Message == "" {
+			field.Message = fmt.Sprintf(message(fieldMessages, field.Code, lang), field.Field)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// New - Ошибка с кодом и кодом ответа HTTP
+func New(code Code, status int) *Error {
+	return &Error{Code: code, Status: status}
+}
+
+// Wrap - Ошибка с кодом, кодом ответа HTTP и исходной ошибкой
+func Wrap(code Code, status int, cause error) *Error {
+	return &Error{Code: code, Status: status, Cause: cause}
+}
+
+// Field - Ошибка в поле запроса
+func Field(field, code string) FieldError {
+	return FieldError{Field: field, Code: code}
+}
+
+// Validation - Ошибка проверки полей запроса
+func Validation(fields ...FieldError) *Error {
+	return &Error{Code: CodeValidationFailed, Status: http.StatusUnprocessableEntity, Fields: fields}
+}
+
+// Required - Ошибка проверки: не указано поле
+func Required(field string) *Error {
+	return Validation(Field(field, FieldRequired))
+}
+
+// Invalid - Ошибка проверки: неверное значение поля
+func Invalid(field string) *Error {
+	return Validation(Field(field, FieldInvalid))
+}
+
+// Reference - Ошибка проверки: запись, на которую ссылается поле, не найдена
+func Reference(field string) *Error {
+	return &Error{Code: CodeReferenceNotFound, Status: http.StatusUnprocessableEntity, Fields: []FieldError{Field(field, FieldNotFound)}}
+}
+
+// Часто используемые ошибки
+var (
+	ErrUnauthorized       = New(CodeUnauthorized, http.StatusUnauthorized)
+	ErrInvalidCredentials = New(CodeInvalidCredentials, http.StatusUnauthorized)
+	ErrTokenInvalid       = New(CodeTokenInvalid, http.StatusUnprocessableEntity)
+	ErrForbidden          = New(CodeForbidden, http.StatusForbidden)
+	ErrNotFound           = New(CodeNotFound, http.StatusNotFound)
+	ErrMethodNotAllowed   = New(CodeMethodNotAllowed, http.StatusMethodNotAllowed)
+	ErrAlreadyExists      = New(CodeAlreadyExists, http.StatusConflict)
+	ErrTooManyRequests    = New(CodeTooManyRequests, http.StatusTooManyRequests)
+)
+
+// statusCodes - код ошибки по коду ответа HTTP для ошибок без типа
+var statusCodes = map[int]Code{
+	http.StatusBadRequest:          CodeBadRequest,
+	http.StatusUnauthorized:        CodeUnauthorized,
+	http.StatusForbidden:           CodeForbidden,
+	http.StatusNotFound:            CodeNotFound,
+	http.StatusMethodNotAllowed:    CodeMethodNotAllowed,
+	http.StatusConflict:            CodeAlreadyExists,
+	http.StatusUnprocessableEntity: CodeValidationFailed,
+	http.StatusTooManyRequests:     CodeTooManyRequests,
+}
+
+// From - Ошибка API из любой ошибки: ошибки API возвращаются как есть, ошибки PostgreSQL
+// и gorm переводятся в ошибки API, остальные получают код по коду ответа status и сообщение
+// из каталога на языке клиента (текст исходной ошибки остаётся только в Cause)
+func From(err error, status int) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	if dbErr := FromDB(err); dbErr != nil {
+		return dbErr
+	}
+	if jsonErr := fromJSON(err, status); jsonErr != nil {
+		return jsonErr
+	}
+	if status >= http.StatusInternalServerError {
+		return Wrap(CodeInternal, status, err)
+	}
+	code, ok := statusCodes[status]
+	if !ok {
+		code = CodeBadRequest
+	}
+	return &Error{Code: code, Status: status, Cause: err}
+}
+
+// uniqueViolations - ошибки для нарушения уникальности по названию ограничения
+var uniqueViolations = map[string]*Error{
+	"users_email_key":    {Code: CodeUserEmailTaken, Status: http.StatusConflict, Fields: []FieldError{{Field: "email", Code: FieldTaken}}},
+	"users_nickname_key": {Code: CodeUserNicknameTaken, Status: http.StatusConflict, Fields: []FieldError{{Field: "nickname", Code: FieldTaken}}},
+}
+
+// FromDB - Ошибка API для ошибки базы данных по коду SQLSTATE; nil, если ошибка не из базы данных
+func FromDB(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	if gorm.IsRecordNotFoundError(err) {
+		return Wrap(CodeNotFound, http.StatusNotFound, err)
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		if known, ok := uniqueViolations[pqErr.Constraint]; ok {
+			result := *known
+			result.Cause = err
+			return &result
+		}
+		result := Wrap(CodeAlreadyExists, http.StatusConflict, err)
+		if field := constraintField(pqErr.Table, pqErr.Constraint); field != "" {
+			result.Fields = []FieldError{Field(field, FieldTaken)}
+		}
+		return result
+	case "23503": // foreign_key_violation
+		return Wrap(CodeReferenceNotFound, http.StatusUnprocessableEntity, err)
+	case "23502": // not_null_violation
+		return &Error{Code: CodeValidationFailed, Status: http.StatusUnprocessableEntity, Fields: []FieldError{Field(pqErr.Column, FieldRequired)}, Cause: err}
+	}
+	return nil
+}
+
+// fromJSON - Ошибка API для ошибки разбора тела запроса; nil, если ошибка не из encoding/json
+func fromJSON(err error, status int) *Error {
+	if status < http.StatusBadRequest || status >= http.StatusInternalServerError {
+		status = http.StatusBadRequest
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		result := Wrap(CodeInvalidJSON, status, err)
+		if typeErr.Field != "" {
+			result.Fields = []FieldError{Field(typeErr.Field, FieldInvalid)}
+		}
+		return result
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return Wrap(CodeInvalidJSON, status, err)
+	}
+	return nil
+}
+
+// constraintField - Поле из названия ограничения уникальности вида <таблица>_<поле>_key
+func constraintField(table, constraint string) string {
+	if !strings.HasPrefix(constraint, table+"_") || !strings.HasSuffix(constraint, "_key") {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(constraint, table+"_"), "_key")
+}
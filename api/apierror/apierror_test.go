@@ -0,0 +1,168 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+func jsonError(body string) error {
+	target := struct {
+		ID int `json:"id"`
+	}{}
+	return json.Unmarshal([]byte(body), &target)
+}
+
+func TestFrom(t *testing.T) {
+	plain := errors.New("strconv.ParseUint: parsing \"x\": invalid syntax")
+	tests := []struct {
+		title  string
+		err    error
+		status int
+		code   Code
+		want   int
+		cause  bool
+	}{
+		{"api error as is", ErrForbidden, http.StatusInternalServerError, CodeForbidden, http.StatusForbidden, false},
+		{"wrapped api error", fmt.Errorf("form: %w", ErrNotFound), http.StatusInternalServerError, CodeNotFound, http.StatusNotFound, false},
+		{"database error", &pq.Error{Code: "23503"}, http.StatusInternalServerError, CodeReferenceNotFound, http.StatusUnprocessableEntity, true},
+		{"json error", jsonError(`{`), http.StatusUnprocessableEntity, CodeInvalidJSON, http.StatusUnprocessableEntity, true},
+		{"internal error", plain, http.StatusInternalServerError, CodeInternal, http.StatusInternalServerError, true},
+		{"bad request", plain, http.StatusBadRequest, CodeBadRequest, http.StatusBadRequest, true},
+		{"not found", plain, http.StatusNotFound, CodeNotFound, http.StatusNotFound, true},
+		{"validation", plain, http.StatusUnprocessableEntity, CodeValidationFailed, http.StatusUnprocessableEntity, true},
+		{"unknown status", plain, http.StatusTeapot, CodeBadRequest, http.StatusTeapot, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			got := From(tt.err, tt.status)
+			if got.Code != tt.code || got.Status != tt.want {
+				t.Fatalf("From() = %s/%d, want %s/%d", got.Code, got.Status, tt.code, tt.want)
+			}
+			if tt.cause && got.Cause != tt.err {
+				t.Errorf("Cause = %v, want %v", got.Cause, tt.err)
+			}
+			// Текст исходной ошибки не попадает в сообщение для клиента
+			if got.Localize(English) == plain.Error() || got.Localize(Russian) == plain.Error() {
+				t.Errorf("message leaks the cause: %q", got.Localize(English))
+			}
+		})
+	}
+}
+
+func TestFromDB(t *testing.T) {
+	tests := []struct {
+		title  string
+		err    error
+		code   Code
+		status int
+		fields []FieldError
+	}{
+		{"known unique constraint", &pq.Error{Code: "23505", Table: "users", Constraint: "users_email_key"},
+			CodeUserEmailTaken, http.StatusConflict, []FieldError{{Field: "email", Code: FieldTaken}}},
+		{"known unique constraint wrapped", fmt.Errorf("save: %w", &pq.Error{Code: "23505", Table: "users", Constraint: "users_nickname_key"}),
+			CodeUserNicknameTaken, http.StatusConflict, []FieldError{{Field: "nickname", Code: FieldTaken}}},
+		{"unknown unique constraint", &pq.Error{Code: "23505", Table: "user_groups", Constraint: "user_groups_name_key"},
+			CodeAlreadyExists, http.StatusConflict, []FieldError{{Field: "name", Code: FieldTaken}}},
+		{"unique index without field", &pq.Error{Code: "23505", Table: "grouped_users", Constraint: "idx_grouped_users_pair"},
+			CodeAlreadyExists, http.StatusConflict, nil},
+		{"foreign key", &pq.Error{Code: "23503", Table: "forms", Constraint: "forms_author_id_fkey"},
+			CodeReferenceNotFound, http.StatusUnprocessableEntity, nil},
+		{"not null", &pq.Error{Code: "23502", Table: "users", Column: "email"},
+			CodeValidationFailed, http.StatusUnprocessableEntity, []FieldError{{Field: "email", Code: FieldRequired}}},
+		{"record not found", gorm.ErrRecordNotFound,
+			CodeNotFound, http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			got := FromDB(tt.err)
+			if got == nil {
+				t.Fatal("FromDB() = nil")
+			}
+			if got.Code != tt.code || got.Status != tt.status {
+				t.Errorf("FromDB() = %s/%d, want %s/%d", got.Code, got.Status, tt.code, tt.status)
+			}
+			if !reflect.DeepEqual(got.Fields, tt.fields) {
+				t.Errorf("Fields = %v, want %v", got.Fields, tt.fields)
+			}
+			if got.Cause != tt.err {
+				t.Errorf("Cause = %v, want %v", got.Cause, tt.err)
+			}
+		})
+	}
+
+	t.Run("known errors are not shared", func(t *testing.T) {
+		got := FromDB(&pq.Error{Code: "23505", Constraint: "users_email_key"})
+		if uniqueViolations["users_email_key"].Cause != nil || got == uniqueViolations["users_email_key"] {
+			t.Error("FromDB modified the shared error")
+		}
+	})
+	for _, err := range []error{nil, errors.New("timeout"), &pq.Error{Code: "40001"}} {
+		if got := FromDB(err); got != nil {
+			t.Errorf("FromDB(%v) = %v, want nil", err, got)
+		}
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		title  string
+		err    error
+		status int
+		want   int
+		fields []FieldError
+	}{
+		{"type error", jsonError(`{"id":"x"}`), http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, []FieldError{{Field: "id", Code: FieldInvalid}}},
+		{"type error without field", jsonError(`"x"`), http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, nil},
+		{"syntax error", jsonError(`{"id":}`), http.StatusBadRequest, http.StatusBadRequest, nil},
+		{"unexpected end", jsonError(`{`), http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, nil},
+		{"truncated body", io.ErrUnexpectedEOF, http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, nil},
+		{"server status becomes 400", jsonError(`{`), http.StatusInternalServerError, http.StatusBadRequest, nil},
+		{"success status becomes 400", jsonError(`{`), http.StatusOK, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			got := fromJSON(tt.err, tt.status)
+			if got == nil {
+				t.Fatalf("fromJSON(%v) = nil", tt.err)
+			}
+			if got.Code != CodeInvalidJSON || got.Status != tt.want {
+				t.Errorf("fromJSON() = %s/%d, want %s/%d", got.Code, got.Status, CodeInvalidJSON, tt.want)
+			}
+			if !reflect.DeepEqual(got.Fields, tt.fields) {
+				t.Errorf("Fields = %v, want %v", got.Fields, tt.fields)
+			}
+		})
+	}
+	if got := fromJSON(errors.New("timeout"), http.StatusBadRequest); got != nil {
+		t.Errorf("fromJSON(timeout) = %v, want nil", got)
+	}
+}
+
+func TestConstraintField(t *testing.T) {
+	tests := []struct {
+		table      string
+		constraint string
+		want       string
+	}{
+		{"users", "users_email_key", "email"},
+		{"user_groups", "user_groups_name_key", "name"},
+		{"api_keys", "api_keys_key_prefix_key", "key_prefix"},
+		{"users", "forms_email_key", ""},
+		{"users", "users_email_idx", ""},
+		{"users", "users_pkey", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := constraintField(tt.table, tt.constraint); got != tt.want {
+			t.Errorf("constraintField(%q, %q) = %q, want %q", tt.table, tt.constraint, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package apierror
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Поддерживаемые языки сообщений
+const (
+	Russian = "ru"
+	English = "en"
+)
+
+// DefaultLanguage - язык сообщений, если клиент не указал поддерживаемый язык
+const DefaultLanguage = Russian
+
+// messages - сообщения для кодов ошибок
+var messages = map[string]map[string]string{
+	string(CodeBadRequest):         {Russian: "Неверный запрос", English: "Bad request"},
+	string(CodeInvalidJSON):        {Russian: "Тело запроса не является правильным JSON", English: "Request body is not valid JSON"},
+	string(CodeValidationFailed):   {Russian: "Данные не прошли проверку", English: "Validation failed"},
+	string(CodeUnauthorized):       {Russian: "Требуется авторизация", English: "Unauthorized"},
+	string(CodeInvalidCredentials): {Russian: "Неверный адрес электронной почты или пароль", English: "Invalid email or password"},
+	string(CodeTokenInvalid):       {Russian: "Ссылка или токен недействительны или устарели", English: "Link or token is invalid or expired"},
+	string(CodeEmailNotVerified):   {Russian: "Электронная почта не подтверждена", English: "Email is not verified"},
+	string(CodeMFACodeInvalid):     {Russian: "Неверный код двухфакторной аутентификации", English: "Invalid two-factor authentication code"},
+	string(CodeMFAAlreadyEnabled):  {Russian: "Двухфакторная аутентификация уже включена", English: "Two-factor authentication is already enabled"},
+	string(CodeMFANotEnabled):      {Russian: "Двухфакторная аутентификация не включена", English: "Two-factor authentication is not enabled"},
+	string(CodeMFARequired):        {Russian: "Группа пользователя требует двухфакторную аутентификацию", English: "User group requires two-factor authentication"},
+	string(CodeForbidden):          {Russian: "Недостаточно прав", English: "Forbidden"},
+	string(CodeNotFound):           {Russian: "Не найдено", English: "Not found"},
+	string(CodeMethodNotAllowed):   {Russian: "Метод не поддерживается", English: "Method not allowed"},
+	string(CodeAlreadyExists):      {Russian: "Запись уже существует", English: "Record already exists"},
+	string(CodeUserEmailTaken):     {Russian: "Адрес электронной почты уже занят", English: "Email is already taken"},
+	string(CodeUserNicknameTaken):  {Russian: "Псевдоним уже занят", English: "Nickname is already taken"},
+	string(CodeReferenceNotFound):  {Russian: "Связанная запись не найдена", English: "Referenced record not found"},
+	string(CodePermissionInUse):    {Russian: "Разрешение выдано группам пользователей, сначала его нужно у них забрать", English: "Permission is granted to user groups, revoke it first"},
+	string(CodeGroupHasAPIKeys):    {Russian: "К группе привязаны действующие ключи доступа, сначала их нужно отозвать", English: "Group has active API keys, revoke them first"},
+	string(CodeTooManyRequests):    {Russian: "Слишком много запросов", English: "Too many requests"},
+	string(CodeInternal):           {Russian: "Внутренняя ошибка сервера", English: "Internal server error"},
+}
+
+// fieldMessages - сообщения для кодов ошибок в полях; %s - название поля
+var fieldMessages = map[string]map[string]string{
+	FieldRequired: {Russian: "Поле %s обязательно", English: "Field %s is required"},
+	FieldInvalid:  {Russian: "Неверное значение поля %s", English: "Field %s is invalid"},
+	FieldTaken:    {Russian: "Значение поля %s уже занято", English: "Field %s is already taken"},
+	FieldNotFound: {Russian: "Запись из поля %s не найдена", English: "Record referenced by field %s not found"},
+	FieldExpired:  {Russian: "Срок действия из поля %s уже истёк", English: "Field %s is already expired"},
+}
+
+// message - Сообщение для кода на языке lang или на языке по умолчанию
+func message(catalog map[string]map[string]string, code, lang string) string {
+	translations, ok := catalog[code]
+	if !ok {
+		return code
+	}
+	if text, ok := translations[lang]; ok {
+		return text
+	}
+	return translations[DefaultLanguage]
+}
+
+// Negotiate - Язык сообщений по заголовку Accept-Language с учётом весов q
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if i := strings.IndexAny(tag, "-_"); i >= 0 {
+			tag = tag[:i]
+		}
+		if tag != Russian && tag != English {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return DefaultLanguage
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].lang
+}
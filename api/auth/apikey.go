@@ -0,0 +1,113 @@
+// Package auth - пакет для авторизации пользователей
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/doka-guide/api/api/utils/randomize"
+)
+
+// APIKeyHeader – Заголовок, в котором сервисы передают ключ доступа
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyPrefix – Начало всех ключей доступа, по нему ключ отличается от JWT
+const apiKeyPrefix = "doka_"
+
+// APIKeyVerifier – Хранилище ключей доступа
+type APIKeyVerifier interface {
+	// VerifyAPIKey – проверка ключа по префиксу и хэшу, возвращает ID пользователя и ID группы
+	VerifyAPIKey(prefix string, hash string) (uint64, uint64, error)
+}
+
+var apiKeys APIKeyVerifier
+
+// SetAPIKeyVerifier – Установка хранилища, в котором проверяются ключи доступа
+func SetAPIKeyVerifier(verifier APIKeyVerifier) {
+	apiKeys = verifier
+}
+
+// Identity – Субъект запроса: пользователь с токеном доступа или сервис с ключом доступа
+type Identity struct {
+	UserID  uint64
+	GroupID uint64
+	APIKey  bool
+	// Permissions и Denies – разрешения и запреты из токена доступа (nil, если токен выдан без них)
+	Permissions       []string
+	Denies            []string
+	PermissionVersion int64
+}
+
+// CreateAPIKey – Создание ключа доступа, возвращает ключ, его префикс и хэш для хранения в базе данных
+func CreateAPIKey() (string, string, string, error) {
+	prefix, err := randomize.GetSecureToken(4)
+	if err != nil {
+		return "", "", "", err
+	}
+	secret, err := randomize.GetSecureToken(24)
+	if err != nil {
+		return "", "", "", err
+	}
+	key := apiKeyPrefix + prefix + "_" + secret
+	return key, apiKeyPrefix + prefix, HashToken(key), nil
+}
+
+// IsAPIKey – Проверка, является ли токен ключом доступа
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// verifyAPIKey – Проверка ключа доступа в хранилище
+func verifyAPIKey(key string) (*Identity, error) {
+	if apiKeys == nil {
+		return nil, errors.New("API keys are not supported")
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, apiKeyPrefix), "_", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("Invalid API key")
+	}
+	uid, gid, err := apiKeys.VerifyAPIKey(apiKeyPrefix+parts[0], HashToken(key))
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{UserID: uid, GroupID: gid, APIKey: true}, nil
+}
+
+// ExtractIdentity – Определение субъекта запроса по токену доступа или ключу доступа
+func ExtractIdentity(r *http.Request) (*Identity, error) {
+	token := ExtractToken(r)
+	if IsAPIKey(token) {
+		return verifyAPIKey(token)
+	}
+	claims, err := parseToken(r)
+	if err != nil {
+		return nil, err
+	}
+	uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	identity := &Identity{UserID: uid}
+	if perms, ok := claims["perms"].([]interface{}); ok {
+		identity.Permissions = claimStrings(perms)
+		deny, _ := claims["deny"].([]interface{})
+		identity.Denies = claimStrings(deny)
+		pv, _ := claims["pv"].(float64)
+		identity.PermissionVersion = int64(pv)
+	}
+	return identity, nil
+}
+
+// claimStrings – Список строк из утверждения токена
+func claimStrings(values []interface{}) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,223 @@
+// Package auth - пакет для авторизации пользователей
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	jwt "github.com/golang-jwt/jwt"
+)
+
+// Key – Ключ подписи токенов; у ключей, выведенных из ротации, есть только открытая часть
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// KeyRing – Набор ключей: одним подписываются новые токены, всеми остальными проверяются выданные ранее
+type KeyRing struct {
+	keys    map[string]*Key
+	signing *Key
+}
+
+// JWK – Открытый ключ в формате JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet – Набор открытых ключей для проверки токенов другими сервисами
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+var keyRing *KeyRing
+
+// SetKeyRing – Установка набора ключей для подписи и проверки токенов
+func SetKeyRing(ring *KeyRing) {
+	keyRing = ring
+}
+
+// currentKeyRing – Набор ключей; пока он не задан через SetKeyRing, токены не подписываются и не проверяются
+func currentKeyRing() *KeyRing {
+	if keyRing != nil {
+		return keyRing
+	}
+	return &KeyRing{keys: map[string]*Key{}}
+}
+
+// NewSecretKeyRing – Набор из одного симметричного ключа HS256 (прежний способ подписи токенов)
+func NewSecretKeyRing(secret string) *KeyRing {
+	key := &Key{Method: jwt.SigningMethodHS256, Private: []byte(secret), Public: []byte(secret)}
+	return &KeyRing{keys: map[string]*Key{"": key}, signing: key}
+}
+
+// LoadKeyRing – Загрузка ключей из каталога: <kid>.pem – закрытые ключи RSA или Ed25519,
+// <kid>.pub.pem – открытые ключи, которые только проверяют подпись. Новые токены подписываются
+// ключом signingKID, а если он не указан – последним по алфавиту закрытым ключом.
+// Если legacySecret не пустой, продолжают приниматься токены без kid, подписанные HS256.
+func LoadKeyRing(dir string, signingKID string, legacySecret string) (*KeyRing, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	ring := &KeyRing{keys: map[string]*Key{}}
+	for _, file := range files {
+		name := filepath.Base(file)
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var key *Key
+		if strings.HasSuffix(name, ".pub.pem") {
+			key, err = parsePublicKey(strings.TrimSuffix(name, ".pub.pem"), data)
+		} else {
+			key, err = parsePrivateKey(strings.TrimSuffix(name, ".pem"), data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ключ %s: %v", name, err)
+		}
+		if _, exists := ring.keys[key.ID]; exists && key.Private == nil {
+			continue
+		}
+		ring.keys[key.ID] = key
+		if key.Private != nil && signingKID == "" {
+			ring.signing = key
+		}
+	}
+	if signingKID != "" {
+		ring.signing = ring.keys[signingKID]
+	}
+	if ring.signing == nil || ring.signing.Private == nil {
+		return nil, fmt.Errorf("в каталоге %s нет закрытого ключа для подписи токенов", dir)
+	}
+	if legacySecret != "" {
+		ring.keys[""] = NewSecretKeyRing(legacySecret).signing
+	}
+	return ring, nil
+}
+
+// parsePrivateKey – Разбор закрытого ключа в формате PEM (PKCS#8 или PKCS#1)
+func parsePrivateKey(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("не удалось прочитать PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, Private: private, Public: &private.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, Private: private, Public: private.Public()}, nil
+	}
+	return nil, errors.New("поддерживаются только ключи RSA и Ed25519")
+}
+
+// parsePublicKey – Разбор открытого ключа в формате PEM (PKIX)
+func parsePublicKey(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("не удалось прочитать PEM")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch public := parsed.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, Public: public}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, Public: public}, nil
+	}
+	return nil, errors.New("поддерживаются только ключи RSA и Ed25519")
+}
+
+// Sign – Подпись токена текущим ключом
+func (ring *KeyRing) Sign(claims jwt.MapClaims) (string, error) {
+	if ring.signing == nil {
+		return "", errors.New("Ключ подписи токенов не задан")
+	}
+	token := jwt.NewWithClaims(ring.signing.Method, claims)
+	if ring.signing.ID != "" {
+		token.Header["kid"] = ring.signing.ID
+	}
+	return token.SignedString(ring.signing.Private)
+}
+
+// Keyfunc – Выбор ключа для проверки подписи по заголовку kid
+func (ring *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ring.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown signing key: %v", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public, nil
+}
+
+// JWKS – Открытые ключи набора (симметричные ключи не публикуются)
+func (ring *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	ids := make([]string, 0, len(ring.keys))
+	for id := range ring.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		key := ring.keys[id]
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return set
+}
+
+// PublicKeys – Открытые ключи текущего набора в формате JWKS
+func PublicKeys() JWKSet {
+	return currentKeyRing().JWKS()
+}
@@ -0,0 +1,72 @@
+// Package auth - пакет для авторизации пользователей
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/doka-guide/api/api/utils/rbac"
+)
+
+// ErrForbidden – У субъекта запроса нет прав на операцию
+var ErrForbidden = errors.New("Forbidden")
+
+// PermissionStore – Хранилище прав доступа
+type PermissionStore interface {
+	// PermissionVersion – текущая версия прав, увеличивается при любом изменении групп и разрешений
+	PermissionVersion() int64
+	// UserPermissions – разрешения и запреты пользователя и версия прав, на момент которой они получены
+	UserPermissions(userID uint64) ([]rbac.Grant, int64, error)
+	// UserHasPermission – проверка разрешения пользователя в хранилище
+	UserHasPermission(userID uint64, permName string) bool
+	// GroupHasPermission – проверка разрешения группы в хранилище
+	GroupHasPermission(groupID uint64, permName string) bool
+}
+
+var permissions PermissionStore
+
+// SetPermissionStore – Установка хранилища прав доступа
+func SetPermissionStore(store PermissionStore) {
+	permissions = store
+}
+
+// Can – Проверка права на операцию. Разрешения из токена используются, пока версия прав в токене
+// совпадает с текущей; после изменения групп или разрешений права проверяются по базе данных
+func (identity *Identity) Can(permName string) bool {
+	if permissions == nil {
+		return false
+	}
+	if identity.APIKey {
+		return permissions.GroupHasPermission(identity.GroupID, permName)
+	}
+	if identity.Permissions != nil && identity.PermissionVersion == permissions.PermissionVersion() {
+		return rbac.Allowed(rbac.Join(identity.Permissions, identity.Denies), permName)
+	}
+	return permissions.UserHasPermission(identity.UserID, permName)
+}
+
+// Authorize – Определение субъекта запроса и проверка его права на операцию (с собственными или любыми записями)
+func Authorize(r *http.Request, permName string) (*Identity, error) {
+	identity, err := ExtractIdentity(r)
+	if err != nil {
+		return nil, err
+	}
+	if !identity.Allows(permName) {
+		return identity, ErrForbidden
+	}
+	return identity, nil
+}
+
+type identityKey struct{}
+
+// NewContext – Сохранение субъекта запроса в контексте
+func NewContext(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext – Получение субъекта запроса, сохранённого посредником авторизации
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
@@ -0,0 +1,22 @@
+// Package auth - пакет для авторизации пользователей
+package auth
+
+import "github.com/doka-guide/api/api/utils/rbac"
+
+// AnySuffix – Окончание разрешения на операции с любыми записями (FORM-DELETE-ANY);
+// разрешение без окончания (FORM-DELETE) действует только на собственные записи
+const AnySuffix = rbac.AnySuffix
+
+// Allows – Проверка права на операцию хотя бы с собственными записями
+func (identity *Identity) Allows(permName string) bool {
+	return identity.Can(permName) || identity.Can(permName+AnySuffix)
+}
+
+// CanAccess – Проверка права на операцию с записью, автором которой является ownerID:
+// с разрешением PERM-ANY доступны любые записи, с разрешением PERM – только собственные
+func (identity *Identity) CanAccess(permName string, ownerID uint64) bool {
+	if identity.Can(permName + AnySuffix) {
+		return true
+	}
+	return identity.UserID != 0 && identity.UserID == ownerID && identity.Can(permName)
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/doka-guide/api/api/utils/rbac"
+)
+
+// tokenStore – хранилище прав для тестов: права берутся из токена, версия прав не меняется
+type tokenStore struct{}
+
+func (tokenStore) PermissionVersion() int64 { return 1 }
+
+func (tokenStore) UserPermissions(uint64) ([]rbac.Grant, int64, error) { return nil, 1, nil }
+
+func (tokenStore) UserHasPermission(uint64, string) bool { return false }
+
+func (tokenStore) GroupHasPermission(uint64, string) bool { return false }
+
+func withTokenStore(t *testing.T) {
+	previous := permissions
+	SetPermissionStore(tokenStore{})
+	t.Cleanup(func() { SetPermissionStore(previous) })
+}
+
+func identity(userID uint64, allow []string, deny []string) *Identity {
+	return &Identity{UserID: userID, Permissions: allow, Denies: deny, PermissionVersion: 1}
+}
+
+// Права на записи форм, подписок, ссылок на профиль и отчётов о рассылке, которые проверяют контроллеры
+var ownedPermissions = map[string][]string{
+	"FORM":                {"FORM-GET", "FORM-POST", "FORM-PUT", "FORM-DELETE"},
+	"SUBSCRIPTION":        {"SUBSCRIPTION-GET", "SUBSCRIPTION-POST", "SUBSCRIPTION-PUT", "SUBSCRIPTION-DELETE"},
+	"PROFILE-LINK":        {"PROFILE-LINK-GET", "PROFILE-LINK-DELETE"},
+	"SUBSCRIPTION-REPORT": {"SUBSCRIPTION-REPORT-GET", "SUBSCRIPTION-REPORT-DELETE"},
+}
+
+func TestCanAccess(t *testing.T) {
+	withTokenStore(t)
+	const owner, other = 1, 2
+	for entity, perms := range ownedPermissions {
+		for _, perm := range perms {
+			tests := []struct {
+				title    string
+				identity *Identity
+				want     bool
+			}{
+				{"owner with PERM", identity(owner, []string{perm}, nil), true},
+				{"owner without permissions", identity(owner, nil, nil), false},
+				{"non-owner with PERM", identity(other, []string{perm}, nil), false},
+				{"non-owner with PERM-ANY", identity(other, []string{perm + AnySuffix}, nil), true},
+				{"owner with PERM-ANY", identity(owner, []string{perm + AnySuffix}, nil), true},
+				{"non-owner with entity wildcard", identity(other, []string{entity + "-*"}, nil), true},
+				{"owner with denied PERM", identity(owner, []string{entity + "-*"}, []string{perm}), false},
+				{"non-owner with denied PERM", identity(other, []string{entity + "-*"}, []string{perm}), false},
+				{"non-owner with denied PERM-ANY", identity(other, []string{entity + "-*"}, []string{perm + AnySuffix}), false},
+				{"owner with denied PERM-ANY", identity(owner, []string{entity + "-*"}, []string{perm + AnySuffix}), true},
+				{"anonymous record", identity(0, []string{perm}, nil), false},
+			}
+			for _, tt := range tests {
+				t.Run(perm+"/"+tt.title, func(t *testing.T) {
+					ownerID := uint64(owner)
+					if tt.identity.UserID == 0 {
+						ownerID = 0
+					}
+					if got := tt.identity.CanAccess(perm, ownerID); got != tt.want {
+						t.Errorf("CanAccess(%q, %d) = %v, want %v", perm, ownerID, got, tt.want)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	withTokenStore(t)
+	tests := []struct {
+		title    string
+		identity *Identity
+		want     bool
+	}{
+		{"PERM", identity(1, []string{"FORM-DELETE"}, nil), true},
+		{"PERM-ANY", identity(1, []string{"FORM-DELETE-ANY"}, nil), true},
+		{"nothing", identity(1, []string{"FORM-GET"}, nil), false},
+		{"denied PERM", identity(1, []string{"FORM-*"}, []string{"FORM-DELETE"}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := tt.identity.Allows("FORM-DELETE"); got != tt.want {
+				t.Errorf("Allows(FORM-DELETE) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
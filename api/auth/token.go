@@ -2,50 +2,188 @@
 package auth
 
 import (
-	"encoding/json"
+	"crypto/sha256"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
+	"github.com/doka-guide/api/api/utils/randomize"
+	"github.com/doka-guide/api/api/utils/rbac"
 	jwt "github.com/golang-jwt/jwt"
 )
 
-// CreateToken – Создание токена
+const (
+	// AccessTokenLifetime – Время жизни токена доступа
+	AccessTokenLifetime = time.Hour * 1
+	// RefreshTokenLifetime – Время жизни токена обновления
+	RefreshTokenLifetime = time.Hour * 24 * 30
+	// VerificationTokenLifetime – Время жизни токена для подтверждения электронной почты
+	VerificationTokenLifetime = time.Hour * 48
+	// MFATokenLifetime – Время жизни токена, который выдаётся после пароля и ждёт второго фактора
+	MFATokenLifetime = time.Minute * 5
+)
+
+// Назначения специальных токенов, которые нельзя использовать как токен доступа
+const (
+	purposeVerifyEmail = "verify-email"
+	purposeMFA         = "mfa-pending"
+)
+
+// ErrTokenRevoked – Токен доступа отозван
+var ErrTokenRevoked = errors.New("Token has been revoked")
+
+// Denylist – Список отозванных токенов доступа (по идентификатору jti)
+type Denylist interface {
+	IsRevoked(jti string) bool
+}
+
+var denylist Denylist
+
+// SetDenylist – Установка списка отозванных токенов, который проверяется при валидации токенов
+func SetDenylist(list Denylist) {
+	denylist = list
+}
+
+// TokenPair – Пара из токена доступа и токена обновления
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+// CreateToken – Создание токена; в токен записываются разрешения пользователя и версия прав
 func CreateToken(userID uint64) (string, error) {
+	jti, err := randomize.GetSecureToken(16)
+	if err != nil {
+		return "", err
+	}
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
 	claims["user_id"] = userID
+	claims["jti"] = jti
+	if permissions != nil {
+		grants, version, err := permissions.UserPermissions(userID)
+		if err != nil {
+			return "", err
+		}
+		claims["perms"], claims["deny"] = rbac.Split(grants)
+		claims["pv"] = version
+	}
 
 	// Токен работает после часа бездействия
-	claims["exp"] = time.Now().Add(time.Hour * 1).Unix()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("API_SECRET")))
+	claims["exp"] = time.Now().Add(AccessTokenLifetime).Unix()
+	return currentKeyRing().Sign(claims)
+}
+
+// CreateRefreshToken – Создание токена обновления, возвращает сам токен и его хэш для хранения в базе данных
+func CreateRefreshToken() (string, string, error) {
+	token, err := randomize.GetSecureToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashToken(token), nil
+}
+
+// HashToken – Хэширование непрозрачного токена для хранения в базе данных
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("%x", hash[:])
+}
+
+// CreateVerificationToken – Создание подписанного токена для подтверждения электронной почты
+func CreateVerificationToken(userID uint64, email string) (string, error) {
+	claims := jwt.MapClaims{}
+	claims["purpose"] = purposeVerifyEmail
+	claims["user_id"] = userID
+	claims["email"] = email
+	claims["exp"] = time.Now().Add(VerificationTokenLifetime).Unix()
+	return currentKeyRing().Sign(claims)
+}
+
+// ParseVerificationToken – Проверка токена подтверждения электронной почты, возвращает ID пользователя и адрес
+func ParseVerificationToken(tokenString string) (uint64, string, error) {
+	claims, err := parseTokenString(tokenString)
+	if err != nil {
+		return 0, "", err
+	}
+	if purpose, _ := claims["purpose"].(string); purpose != purposeVerifyEmail {
+		return 0, "", errors.New("Invalid token")
+	}
+	uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	email, _ := claims["email"].(string)
+	return uid, email, nil
+}
 
+// CreateMFAToken – Создание короткоживущего токена для входа, ожидающего второй фактор
+func CreateMFAToken(userID uint64) (string, error) {
+	claims := jwt.MapClaims{}
+	claims["purpose"] = purposeMFA
+	claims["user_id"] = userID
+	claims["exp"] = time.Now().Add(MFATokenLifetime).Unix()
+	return currentKeyRing().Sign(claims)
+}
+
+// ParseMFAToken – Проверка токена, ожидающего второй фактор, возвращает ID пользователя
+func ParseMFAToken(tokenString string) (uint64, error) {
+	claims, err := parseTokenString(tokenString)
+	if err != nil {
+		return 0, err
+	}
+	if purpose, _ := claims["purpose"].(string); purpose != purposeMFA {
+		return 0, errors.New("Invalid token")
+	}
+	return strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 64)
+}
+
+// parseTokenString – Разбор и проверка подписи токена
+func parseTokenString(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, currentKeyRing().Keyfunc)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("Invalid token")
+	}
+	return claims, nil
+}
+
+// parseToken – Разбор и проверка токена доступа из запроса
+func parseToken(r *http.Request) (jwt.MapClaims, error) {
+	claims, err := parseTokenString(ExtractToken(r))
+	if err != nil {
+		return nil, err
+	}
+	if purpose, _ := claims["purpose"].(string); purpose != "" {
+		return nil, errors.New("Invalid token")
+	}
+	if jti, _ := claims["jti"].(string); jti != "" && denylist != nil && denylist.IsRevoked(jti) {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
 }
 
 // TokenValid – Валидация токена
 func TokenValid(r *http.Request) error {
-	tokenString := ExtractToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
-	if err != nil {
+	if token := ExtractToken(r); IsAPIKey(token) {
+		_, err := verifyAPIKey(token)
 		return err
 	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		Pretty(claims)
-	}
-	return nil
+	_, err := parseToken(r)
+	return err
 }
 
 // ExtractToken – Экстракция токена
 func ExtractToken(r *http.Request) string {
+	apiKey := r.Header.Get(APIKeyHeader)
+	if apiKey != "" {
+		return apiKey
+	}
 	keys := r.URL.Query()
 	token := keys.Get("token")
 	if token != "" {
@@ -60,34 +198,34 @@ func ExtractToken(r *http.Request) string {
 
 // ExtractTokenID – Экстракция токена с возвращением ID пользователя
 func ExtractTokenID(r *http.Request) (uint64, error) {
-	tokenString := ExtractToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	if token := ExtractToken(r); IsAPIKey(token) {
+		identity, err := verifyAPIKey(token)
+		if err != nil {
+			return 0, err
 		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
+		return identity.UserID, nil
+	}
+	claims, err := parseToken(r)
 	if err != nil {
 		return 0, err
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 64)
-		if err != nil {
-			return 0, err
-		}
-		return uid, nil
+	uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 64)
+	if err != nil {
+		return 0, err
 	}
-	return 0, nil
+	return uid, nil
 }
 
-// Pretty - отображать информация красиво в терминале
-func Pretty(data interface{}) {
-	b, err := json.MarshalIndent(data, "", " ")
+// ExtractTokenJTI – Экстракция идентификатора токена (jti) и времени окончания его действия
+func ExtractTokenJTI(r *http.Request) (string, time.Time, error) {
+	claims, err := parseToken(r)
 	if err != nil {
-		log.Println(err)
-		return
+		return "", time.Time{}, err
 	}
-
-	fmt.Println(string(b))
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return "", time.Time{}, errors.New("Token has no identifier")
+	}
+	exp, _ := claims["exp"].(float64)
+	return jti, time.Unix(int64(exp), 0), nil
 }
@@ -0,0 +1,443 @@
+// Package api - основной пакет приложения, реализующий функционал REST API
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doka-guide/api/api/auth"
+	"github.com/doka-guide/api/api/config"
+	"github.com/doka-guide/api/api/controllers"
+	"github.com/doka-guide/api/api/migrations"
+	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/seed"
+	"github.com/doka-guide/api/api/utils/randomize"
+	"github.com/jinzhu/gorm"
+)
+
+// migrate - Команда migrate: up применяет новые миграции, down [N] откатывает N последних (по умолчанию одну),
+// status выводит состояние миграций
+func migrate(args []string) error {
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	steps := 1
+	if command == "down" && len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("Неверное количество миграций для отката: %s", args[1])
+		}
+		steps = n
+	}
+	if command != "up" && command != "down" && command != "status" {
+		return fmt.Errorf("Неизвестная команда migrate %s: используйте up, down [N] или status", command)
+	}
+
+	_, err := connect()
+	if err != nil {
+		return err
+	}
+	defer server.DB.Close()
+
+	switch command {
+	case "up":
+		applied, err := migrations.Up(server.DB)
+		if err != nil {
+			return err
+		}
+		for _, m := range applied {
+			fmt.Printf("Применена миграция %d_%s\n", m.Version, m.Name)
+		}
+		fmt.Printf("Применено миграций: %d\n", len(applied))
+	case "down":
+		reverted, err := migrations.Down(server.DB, steps)
+		if err != nil {
+			return err
+		}
+		for _, m := range reverted {
+			fmt.Printf("Откачена миграция %d_%s\n", m.Version, m.Name)
+		}
+		fmt.Printf("Откачено миграций: %d\n", len(reverted))
+	default:
+		statuses, err := migrations.List(server.DB)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "не применена"
+			if s.AppliedAt != nil {
+				state = "применена " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Unknown {
+				state += " (нет в этой сборке)"
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+	}
+	return nil
+}
+
+// seedPolicy - Команда seed: применение политики доступа (таблицы не пересоздаются даже в режиме отладки)
+func seedPolicy(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	file := flags.String("file", "", "файл политики доступа (по умолчанию SEED_POLICY_FILE или policy.json)")
+	dryRun := flags.Bool("dry-run", false, "только вывести изменения")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := connect()
+	if err != nil {
+		return err
+	}
+	defer server.DB.Close()
+
+	path := *file
+	if path == "" {
+		path = cfg.Seed.PolicyFile
+	}
+	if path == "" {
+		path = "policy.json"
+	}
+	changes, err := seed.ApplyPolicy(server.DB, path, *dryRun)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Printf("Политика доступа '%s' не применена (пробный запуск): изменений – %d.\n", path, len(changes))
+		return nil
+	}
+	fmt.Printf("Политика доступа '%s' применена.\n", path)
+	return nil
+}
+
+// createAdmin - Команда create-admin: создание пользователя с подтверждённой почтой и добавление его в группу администраторов
+func createAdmin(args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	email := flags.String("email", "", "электронная почта")
+	nickname := flags.String("nickname", "", "имя пользователя (по умолчанию часть адреса до @)")
+	password := flags.String("password", "", "пароль (по умолчанию создаётся случайный)")
+	group := flags.String("group", "", "группа администраторов (по умолчанию ADMIN_GROUP_NAME)")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("Необходимо указать --email")
+	}
+
+	cfg, err := connect()
+	if err != nil {
+		return err
+	}
+	defer server.DB.Close()
+
+	groupName := *group
+	if groupName == "" {
+		groupName = cfg.Seed.AdminGroup
+	}
+	adminGroup := models.UserGroup{}
+	err = server.DB.Model(&models.UserGroup{}).Where("name = ?", groupName).Take(&adminGroup).Error
+	if err != nil {
+		return fmt.Errorf("Группа '%s' не найдена: %v", groupName, err)
+	}
+
+	user := models.User{}
+	err = server.DB.Model(&models.User{}).Where("email = ?", *email).Take(&user).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+	if gorm.IsRecordNotFoundError(err) {
+		generated := *password == ""
+		if generated {
+			*password, err = randomize.GetSecureToken(12)
+			if err != nil {
+				return err
+			}
+		}
+		if *nickname == "" {
+			*nickname = strings.Split(*email, "@")[0]
+		}
+		user = models.User{Nickname: *nickname, Email: *email, Password: *password}
+		user.Prepare()
+		err = user.Validate("")
+		if err != nil {
+			return err
+		}
+		verifiedAt := time.Now()
+		user.VerifiedAt = &verifiedAt
+		_, err = user.SaveUser(server.DB)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Создан пользователь %s <%s> с id = %d\n", user.Nickname, user.Email, user.ID)
+		if generated {
+			fmt.Printf("Пароль: %s\n", *password)
+		}
+	} else {
+		fmt.Printf("Пользователь <%s> уже существует (id = %d), пароль не изменён\n", user.Email, user.ID)
+	}
+
+	membership := models.GroupedUser{GroupID: adminGroup.ID, UserID: user.ID}
+	if membership.IsDuplicate(server.DB) {
+		fmt.Printf("Пользователь уже состоит в группе '%s'\n", adminGroup.Name)
+		return nil
+	}
+	_, err = membership.SaveGroupedUser(server.DB)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Пользователь добавлен в группу '%s'\n", adminGroup.Name)
+	return nil
+}
+
+// resetPassword - Команда reset-password: установка нового пароля и отзыв токенов обновления пользователя
+func resetPassword(args []string) error {
+	flags := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	email := flags.String("email", "", "электронная почта пользователя")
+	password := flags.String("password", "", "новый пароль (по умолчанию создаётся случайный)")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("Необходимо указать --email")
+	}
+
+	_, err = connect()
+	if err != nil {
+		return err
+	}
+	defer server.DB.Close()
+
+	user := models.User{}
+	err = server.DB.Model(&models.User{}).Where("email = ?", *email).Take(&user).Error
+	if err != nil {
+		return fmt.Errorf("Пользователь <%s> не найден: %v", *email, err)
+	}
+	generated := *password == ""
+	if generated {
+		*password, err = randomize.GetSecureToken(12)
+		if err != nil {
+			return err
+		}
+	}
+	revoked, err := setPassword(server.DB, &user, *password)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Пароль пользователя <%s> изменён, отозвано токенов обновления: %d\n", user.Email, revoked)
+	if generated {
+		fmt.Printf("Пароль: %s\n", *password)
+	}
+	return nil
+}
+
+// setPassword - Установка нового пароля пользователя с отзывом его токенов обновления; после записи
+// проверяется, что в базе хранится хэш нового пароля. Возвращает количество отозванных токенов
+func setPassword(db *gorm.DB, user *models.User, password string) (int64, error) {
+	previousHash := user.Password
+	user.Password = password
+	err := user.Validate("update")
+	if err != nil {
+		return 0, err
+	}
+	var revoked int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		_, err := user.UpdateAUser(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		token := models.RefreshToken{}
+		revoked, err = token.RevokeAllUserRefreshTokens(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	stored := models.User{}
+	err = db.Model(&models.User{}).Where("id = ?", user.ID).Take(&stored).Error
+	if err != nil {
+		return 0, err
+	}
+	if stored.Password == previousHash || models.VerifyPassword(stored.Password, password) != nil {
+		return 0, fmt.Errorf("Пароль пользователя <%s> не сохранён в базе данных", user.Email)
+	}
+	return revoked, nil
+}
+
+// exportSubscribers - Команда export-subscribers: выгрузка адресов, ссылок на профиль и настроек подписки
+func exportSubscribers(args []string) error {
+	flags := flag.NewFlagSet("export-subscribers", flag.ContinueOnError)
+	from := flags.String("from", "1970-01-01", "начало периода подписки")
+	to := flags.String("to", time.Now().Format("2006-01-02 15:04:05"), "конец периода подписки")
+	format := flags.String("format", "csv", "формат выгрузки: csv или json")
+	output := flags.String("output", "", "файл для выгрузки (по умолчанию стандартный вывод)")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("Неизвестный формат выгрузки: %s", *format)
+	}
+
+	_, err = connect()
+	if err != nil {
+		return err
+	}
+	defer server.DB.Close()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	form := models.Form{}
+	subscribers := *form.SubscriptionFormsWithHash(server.DB, *from, *to)
+	if *format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(subscribers)
+	} else {
+		writer := csv.NewWriter(w)
+		err = writer.Write([]string{"email", "hash", "data"})
+		for _, subscriber := range subscribers {
+			if err != nil {
+				break
+			}
+			err = writer.Write([]string{subscriber.Email, subscriber.Hash, subscriber.Data})
+		}
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Выгружено подписчиков: %d\n", len(subscribers))
+	return nil
+}
+
+// sendTestMail - Команда send-test-mail: отправка тестового письма через почтовый сервер из настроек
+func sendTestMail(args []string) error {
+	flags := flag.NewFlagSet("send-test-mail", flag.ContinueOnError)
+	to := flags.String("to", "", "адрес получателя")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *to == "" {
+		return errors.New("Необходимо указать --to")
+	}
+	cfg := loadConfig()
+
+	subject := "Тестовое письмо " + cfg.App.Name
+	text := "Это тестовое письмо: настройки почты работают. Отправлено " + time.Now().Format(time.RFC1123Z)
+	err = controllers.NewMailer(cfg.Mail).Send(*to, *to, subject, text, "<p>"+text+"</p>", false)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Письмо отправлено на %s через %s\n", *to, cfg.Mail.Host)
+	return nil
+}
+
+// checkConfig - Команда check-config: проверка настроек, шаблонов писем, ключей, политики доступа
+// и соединения с базой данных; выводит все найденные проблемы сразу
+func checkConfig(args []string) error {
+	problems := []string{}
+	report := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	cfg, err := config.Load(".env")
+	if err != nil {
+		report("%v", err)
+	}
+	if cfg == nil {
+		fmt.Println("✗", problems[0])
+		return errors.New("не удалось прочитать настройки")
+	}
+
+	// Шаблоны писем
+	templates := map[string]string{
+		"MAIL_BODY_VERIFY_TEXT": cfg.Mail.BodyVerifyText,
+		"MAIL_BODY_VERIFY_HTML": cfg.Mail.BodyVerifyHTML,
+		"MAIL_BODY_RESET_TEXT":  cfg.Mail.BodyResetText,
+		"MAIL_BODY_RESET_HTML":  cfg.Mail.BodyResetHTML,
+		"MAIL_BODY_HI_TEXT":     cfg.Mail.BodyHiText,
+		"MAIL_BODY_HI_HTML":     cfg.Mail.BodyHiHTML,
+	}
+	for name, path := range templates {
+		if _, err := os.Stat(path); err != nil {
+			report("%s: %v", name, err)
+		}
+	}
+	if cfg.Mail.Host == "" || cfg.Mail.User == "" {
+		report("не заданы параметры почтового сервера MAIL_HOST и MAIL_USER: письма не будут отправляться")
+	}
+
+	// Ключи подписи токенов
+	if cfg.Auth.KeysDir != "" {
+		if _, err := auth.LoadKeyRing(cfg.Auth.KeysDir, cfg.Auth.SigningKey, cfg.Auth.Secret); err != nil {
+			report("JWT_KEYS_DIR: %v", err)
+		}
+	}
+
+	// Политика доступа
+	if cfg.Seed.PolicyFile != "" {
+		if _, err := seed.LoadPolicy(cfg.Seed.PolicyFile); err != nil {
+			report("SEED_POLICY_FILE: %v", err)
+		}
+	}
+
+	// Соединение с базой данных и миграции
+	err = server.Connect(cfg.DB)
+	if err != nil {
+		report("%v", err)
+	} else {
+		defer server.DB.Close()
+		statuses, err := migrations.List(server.DB)
+		if err != nil {
+			report("миграции: %v", err)
+		}
+		pending := 0
+		for _, s := range statuses {
+			if s.AppliedAt == nil {
+				pending++
+			}
+		}
+		if pending > 0 {
+			fmt.Printf("Не применено миграций: %d (применятся при запуске сервера или командой migrate up)\n", pending)
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		for _, problem := range problems {
+			fmt.Println("✗", problem)
+		}
+		return fmt.Errorf("найдено проблем: %d", len(problems))
+	}
+	fmt.Println("Настройки в порядке")
+	return nil
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+
+	"github.com/doka-guide/api/api/models"
+)
+
+func mockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open("postgres", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.LogMode(false)
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return db, mock
+}
+
+// expectPasswordUpdate – обновление пароля и отзыв токенов в одной транзакции, затем чтение сохранённого хэша
+func expectPasswordUpdate(mock sqlmock.Sqlmock, storedHash string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "user@example.com"))
+	mock.ExpectExec(`UPDATE "users" SET "email" = \$1, "nickname" = \$2, "password" = \$3, "updated_at" = \$4 WHERE`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET`).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password"}).AddRow(7, "user@example.com", storedHash))
+}
+
+func TestSetPassword(t *testing.T) {
+	db, mock := mockDB(t)
+	newHash, err := models.Hash("new-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectPasswordUpdate(mock, string(newHash))
+
+	user := models.User{ID: 7, Nickname: "user", Email: "user@example.com", Password: "old-hash"}
+	revoked, err := setPassword(db, &user, "new-password")
+	if err != nil {
+		t.Fatalf("setPassword: %v", err)
+	}
+	if revoked != 3 {
+		t.Errorf("revoked = %d, want 3", revoked)
+	}
+}
+
+// TestSetPasswordHashUnchanged – если в базе остался прежний хэш, команда сообщает об ошибке
+func TestSetPasswordHashUnchanged(t *testing.T) {
+	db, mock := mockDB(t)
+	expectPasswordUpdate(mock, "old-hash")
+
+	user := models.User{ID: 7, Nickname: "user", Email: "user@example.com", Password: "old-hash"}
+	if _, err := setPassword(db, &user, "new-password"); err == nil {
+		t.Fatal("setPassword succeeded with the old hash stored")
+	}
+}
@@ -0,0 +1,358 @@
+// Package config - пакет для чтения и проверки настроек приложения
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config - настройки приложения
+type Config struct {
+	// Режим работы приложения: PRODUCTION или DEBUG
+	Mode     string `env:"MODE" default:"PRODUCTION"`
+	GetLimit int    `env:"GET_LIMIT" default:"1000"`
+
+	App     App
+	DB      Database
+	Mail    Mail
+	Auth    Auth
+	Login   Login
+	Upload  Upload
+	Seed    Seed
+	Log     Log
+	Metrics Metrics
+	Health  Health
+	OpenAPI OpenAPI
+	API     API
+}
+
+// App - настройки HTTP-сервера
+type App struct {
+	Host       string `env:"APP_HOST"`
+	Port       string `env:"APP_PORT" required:"true"`
+	Name       string `env:"APP_NAME"`
+	URL        string `env:"APP_URL"`
+	TrustProxy bool   `env:"APP_TRUST_PROXY" default:"false"`
+
+	// Тайм-ауты чтения запроса, записи ответа и простоя соединения keep-alive
+	ReadTimeout  time.Duration `env:"APP_READ_TIMEOUT" default:"15s"`
+	WriteTimeout time.Duration `env:"APP_WRITE_TIMEOUT" default:"30s"`
+	IdleTimeout  time.Duration `env:"APP_IDLE_TIMEOUT" default:"60s"`
+	// Сколько ждать завершения запросов и отправки писем при остановке сервера
+	ShutdownTimeout time.Duration `env:"APP_SHUTDOWN_TIMEOUT" default:"30s"`
+}
+
+// Database - настройки соединения с базой данных
+type Database struct {
+	Driver         string `env:"DB_DRIVER" default:"postgres"`
+	Host           string `env:"DB_HOST" required:"true"`
+	Port           string `env:"DB_PORT" default:"5432"`
+	User           string `env:"DB_USER" required:"true"`
+	Password       string `env:"DB_PASSWORD"`
+	Name           string `env:"DB_NAME" required:"true"`
+	MigrateOnStart bool   `env:"DB_MIGRATE_ON_START" default:"true"`
+	// Записывать запросы в журнал (без значений параметров)
+	LogSQL bool `env:"DB_LOG_SQL" default:"false"`
+}
+
+// Mail - настройки почтового сервера и шаблонов писем
+type Mail struct {
+	Type     string `env:"MAIL_TYPE"`
+	Host     string `env:"MAIL_HOST"`
+	User     string `env:"MAIL_USER"`
+	Password string `env:"MAIL_PASS"`
+	Sender   string `env:"MAIL_SENDER"`
+	// Ссылка для отписки от рассылки
+	UnsubscribeURL string `env:"MAIL_URL"`
+
+	TitleVerify    string `env:"MAIL_TITLE_VERIFY"`
+	BodyVerifyText string `env:"MAIL_BODY_VERIFY_TEXT" default:"templates/verify.txt"`
+	BodyVerifyHTML string `env:"MAIL_BODY_VERIFY_HTML" default:"templates/verify.html"`
+	TitleReset     string `env:"MAIL_TITLE_RESET"`
+	BodyResetText  string `env:"MAIL_BODY_RESET_TEXT" default:"templates/reset.txt"`
+	BodyResetHTML  string `env:"MAIL_BODY_RESET_HTML" default:"templates/reset.html"`
+	TitleHi        string `env:"MAIL_TITLE"`
+	BodyHiText     string `env:"MAIL_BODY_HI_TEXT" default:"templates/hi.txt"`
+	BodyHiHTML     string `env:"MAIL_BODY_HI_HTML" default:"templates/hi.html"`
+	ImagesHiHTML   string `env:"MAIL_IMAGES_HI_HTML"`
+}
+
+// Auth - настройки токенов и проверки прав
+type Auth struct {
+	Secret             string        `env:"API_SECRET"`
+	RequireVerified    bool          `env:"AUTH_REQUIRE_VERIFIED" default:"false"`
+	KeysDir            string        `env:"JWT_KEYS_DIR"`
+	SigningKey         string        `env:"JWT_SIGNING_KEY"`
+	AcceptLegacyHS256  bool          `env:"JWT_ACCEPT_LEGACY_HS256" default:"false"`
+	PermissionCacheTTL time.Duration `env:"PERMISSION_CACHE_TTL" default:"5s"`
+}
+
+// Login - настройки защиты от перебора паролей
+type Login struct {
+	ThrottleStore   string        `env:"LOGIN_THROTTLE_STORE" default:"memory"`
+	MaxFailures     int           `env:"LOGIN_MAX_FAILURES" default:"10"`
+	IPMaxFailures   int           `env:"LOGIN_IP_MAX_FAILURES" default:"50"`
+	LockoutDuration time.Duration `env:"LOGIN_LOCKOUT_DURATION" default:"15m"`
+}
+
+// Upload - настройки загрузки файлов через форму
+type Upload struct {
+	Folder  string `env:"UPLOAD_FOLDER"`
+	MaxSize int64  `env:"UPLOAD_MAX_SIZE" default:"10485760"`
+}
+
+// Seed - настройки политики доступа
+type Seed struct {
+	PolicyFile string `env:"SEED_POLICY_FILE"`
+	DryRun     bool   `env:"SEED_DRY_RUN" default:"false"`
+	AdminGroup string `env:"ADMIN_GROUP_NAME"`
+}
+
+// Log - настройки журнала
+type Log struct {
+	// Минимальный уровень записей: debug, info, warn или error
+	Level string `env:"LOG_LEVEL" default:"info"`
+	// Формат записей: json или text (по умолчанию text в режиме DEBUG и json в остальных режимах)
+	Format string `env:"LOG_FORMAT"`
+}
+
+// Metrics - настройки метрик Prometheus: без токена и отдельного адреса метрики не публикуются
+type Metrics struct {
+	// Токен для заголовка Authorization: Bearer <токен>
+	Token string `env:"METRICS_TOKEN"`
+	// Отдельный адрес для /metrics (например, 127.0.0.1:9100), недоступный снаружи
+	Addr string `env:"METRICS_ADDR"`
+}
+
+// Health - настройки проверки готовности /readyz
+type Health struct {
+	// Наибольшее время каждой проверки
+	Timeout time.Duration `env:"HEALTH_TIMEOUT" default:"2s"`
+	// Проверять соединение с почтовым сервером
+	CheckSMTP bool `env:"HEALTH_CHECK_SMTP" default:"false"`
+}
+
+// OpenAPI - настройки описания API
+type OpenAPI struct {
+	// Показывать страницу Swagger UI на /docs
+	UI bool `env:"OPENAPI_UI" default:"false"`
+}
+
+// API - настройки версий API
+type API struct {
+	// Точки входа /v1 доступны и без префикса, как до появления версий (с заголовками Deprecation и Sunset)
+	RootAliases bool `env:"API_ROOT_ALIASES" default:"true"`
+	// Дата, с которой пути без префикса считаются устаревшими (заголовок Deprecation)
+	DeprecatedAt time.Time `env:"API_DEPRECATED_AT"`
+	// Дата, после которой пути без префикса перестанут работать (заголовок Sunset)
+	SunsetAt time.Time `env:"API_SUNSET_AT"`
+}
+
+// Error - все ошибки в настройках: незаданные обязательные параметры и неверные значения
+type Error struct {
+	Missing []string
+	Invalid []string
+}
+
+// Error - Текст ошибки со списком всех проблем
+func (e *Error) Error() string {
+	parts := []string{}
+	if len(e.Missing) > 0 {
+		parts = append(parts, "не заданы параметры: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Invalid) > 0 {
+		parts = append(parts, "неверные значения: "+strings.Join(e.Invalid, "; "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// IsDebug - Включён ли режим отладки
+func (c *Config) IsDebug() bool {
+	return c.Mode == "DEBUG"
+}
+
+// Address - Адрес, на котором сервер принимает соединения
+func (c *Config) Address() string {
+	return c.App.Host + ":" + c.App.Port
+}
+
+// Load - Чтение настроек. Источники по убыванию приоритета: переменные окружения, файл envFile (.env),
+// JSON-файл из параметра CONFIG_FILE, значения по умолчанию. Вместо любого параметра KEY можно указать
+// KEY_FILE – путь к файлу со значением (например, секрет Docker)
+func Load(envFile string) (*Config, error) {
+	if _, err := os.Stat(envFile); err == nil {
+		err = godotenv.Load(envFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", envFile, err)
+		}
+	}
+	values := map[string]string{}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		file, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		values = file
+	}
+	for _, pair := range os.Environ() {
+		if i := strings.Index(pair, "="); i > 0 {
+			values[pair[:i]] = pair[i+1:]
+		}
+	}
+	return Parse(values)
+}
+
+// readFile - Чтение JSON-файла с настройками: объект с теми же ключами, что и у переменных окружения
+func readFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]interface{}{}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	values := map[string]string{}
+	for key, value := range raw {
+		if value != nil {
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
+
+// Parse - Заполнение настроек из набора значений с проверкой всех параметров сразу
+func Parse(values map[string]string) (*Config, error) {
+	cfg := &Config{}
+	problems := &Error{}
+	fill(reflect.ValueOf(cfg).Elem(), values, problems)
+
+	// Секрет нужен для подписи токенов HS256 (по умолчанию или на время перехода на ключи)
+	if cfg.Auth.Secret == "" && (cfg.Auth.KeysDir == "" || cfg.Auth.AcceptLegacyHS256) {
+		problems.Missing = append(problems.Missing, "API_SECRET")
+	}
+	if cfg.Login.ThrottleStore != "memory" && cfg.Login.ThrottleStore != "postgres" {
+		problems.Invalid = append(problems.Invalid, fmt.Sprintf("LOGIN_THROTTLE_STORE: ожидается memory или postgres, получено '%s'", cfg.Login.ThrottleStore))
+	}
+
+	switch strings.ToLower(cfg.Log.Level) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		problems.Invalid = append(problems.Invalid, fmt.Sprintf("LOG_LEVEL: ожидается debug, info, warn или error, получено '%s'", cfg.Log.Level))
+	}
+	if cfg.Log.Format == "" {
+		cfg.Log.Format = "json"
+		if cfg.IsDebug() {
+			cfg.Log.Format = "text"
+		}
+	}
+	if cfg.Log.Format != "json" && cfg.Log.Format != "text" {
+		problems.Invalid = append(problems.Invalid, fmt.Sprintf("LOG_FORMAT: ожидается json или text, получено '%s'", cfg.Log.Format))
+	}
+
+	if len(problems.Missing) > 0 || len(problems.Invalid) > 0 {
+		sort.Strings(problems.Missing)
+		return cfg, problems
+	}
+	return cfg, nil
+}
+
+// fill - Заполнение полей структуры по тегам env, default и required
+func fill(v reflect.Value, values map[string]string, problems *Error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		tag := t.Field(i).Tag
+		if field.Kind() == reflect.Struct && tag.Get("env") == "" {
+			fill(field, values, problems)
+			continue
+		}
+		key := tag.Get("env")
+		if key == "" {
+			continue
+		}
+		raw, err := lookup(values, key)
+		if err != nil {
+			problems.Invalid = append(problems.Invalid, err.Error())
+			continue
+		}
+		if raw == "" {
+			raw = tag.Get("default")
+		}
+		if raw == "" {
+			if tag.Get("required") == "true" {
+				problems.Missing = append(problems.Missing, key)
+			}
+			continue
+		}
+		err = set(field, raw)
+		if err != nil {
+			problems.Invalid = append(problems.Invalid, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+}
+
+// lookup - Значение параметра key или содержимое файла из параметра key_FILE
+func lookup(values map[string]string, key string) (string, error) {
+	value := strings.TrimSpace(values[key])
+	path := strings.TrimSpace(values[key+"_FILE"])
+	if path == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("%s: указаны одновременно %s и %s_FILE", key, key, key)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %v", key, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// set - Запись строкового значения в поле нужного типа
+func set(field reflect.Value, raw string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(raw)
+	case bool:
+		b, err := strconv.ParseBool(strings.ToLower(raw))
+		if err != nil {
+			return fmt.Errorf("ожидается true или false, получено '%s'", raw)
+		}
+		field.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("ожидается длительность (например, 15m), получено '%s'", raw)
+		}
+		field.SetInt(int64(d))
+	case time.Time:
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339, raw)
+		}
+		if err != nil {
+			return fmt.Errorf("ожидается дата (например, 2025-06-30) или время RFC 3339, получено '%s'", raw)
+		}
+		field.Set(reflect.ValueOf(t.UTC()))
+	case int, int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("ожидается целое число, получено '%s'", raw)
+		}
+		field.SetInt(n)
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Package controllers - пакет для обработки данных запросов
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/doka-guide/api/api/auth"
+	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/responses"
+	"github.com/gorilla/mux"
+)
+
+// APIKeyCreated – Созданный ключ доступа; сам ключ возвращается только один раз
+type APIKeyCreated struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+// OptionsAPIKeys – Для предварительной загрузки (prefetch)
+func (server *Server) OptionsAPIKeys(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, []byte("Запрос OPTIONS обработан"))
+}
+
+// CreateAPIKey – Создание ключа доступа для сервиса
+func (server *Server) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	// Пользователь, от имени которого выполняется запрос
+	uid := RequestUserID(r)
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	apiKey := models.APIKey{}
+	err = json.Unmarshal(body, &apiKey)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	apiKey.Prepare()
+
+	// По умолчанию записи, созданные с ключом, принадлежат пользователю, который создал ключ
+	if apiKey.UserID == 0 {
+		apiKey.UserID = uid
+	}
+	err = server.DB.Model(&models.UserGroup{}).Where("id = ?", apiKey.GroupID).Take(&models.UserGroup{}).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apierror.Reference("group_id"))
+		return
+	}
+	err = server.DB.Model(&models.User{}).Where("id = ?", apiKey.UserID).Take(&models.User{}).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apierror.Reference("user_id"))
+		return
+	}
+	// Ключ не может получить больше прав, чем у создателя: привязка только к своим группам и к себе
+	membership := models.GroupedUser{GroupID: apiKey.GroupID, UserID: uid}
+	if apiKey.UserID != uid || !membership.IsDuplicate(server.DB) {
+		responses.ERROR(w, http.StatusForbidden, apierror.ErrForbidden)
+		return
+	}
+
+	key, prefix, hash, err := auth.CreateAPIKey()
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	apiKey.Prefix = prefix
+	apiKey.Hash = hash
+	err = apiKey.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	apiKeyCreated, err := apiKey.SaveAPIKey(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.URL.Path, apiKeyCreated.ID))
+	responses.JSON(w, http.StatusCreated, APIKeyCreated{APIKey: apiKeyCreated, Key: key})
+}
+
+// GetAPIKeys – Вывод всех ключей доступа
+func (server *Server) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	apiKey := models.APIKey{}
+	query, ok := listQuery(w, r, models.APIKeyListSpec)
+	if !ok {
+		return
+	}
+	apiKeys, next, err := apiKey.FindAllAPIKeys(server.DB, query)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	setNextPage(w, r, next)
+	responses.JSON(w, http.StatusOK, apiKeys)
+}
+
+// GetAPIKey – Вывод ключа доступа по ID
+func (server *Server) GetAPIKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	kid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	apiKey := models.APIKey{}
+	apiKeyReceived, err := apiKey.FindAPIKeyByID(server.DB, kid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, apiKeyReceived)
+}
+
+// RevokeAPIKey – Отзыв ключа доступа (запись остаётся для истории)
+func (server *Server) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	kid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	apiKey := models.APIKey{}
+	_, err = apiKey.RevokeAnAPIKey(server.DB, kid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Entity", fmt.Sprintf("%d", kid))
+	w.WriteHeader(http.StatusNoContent)
+}
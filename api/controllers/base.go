@@ -2,10 +2,17 @@
 package controllers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
@@ -13,67 +20,251 @@ import (
 	// Драйвер для работы с PostgreSQL
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/doka-guide/api/api/auth"
+	"github.com/doka-guide/api/api/config"
+	"github.com/doka-guide/api/api/middlewares"
+	"github.com/doka-guide/api/api/migrations"
 	"github.com/doka-guide/api/api/models"
 	"github.com/doka-guide/api/api/responses"
+	"github.com/doka-guide/api/api/utils/logger"
+	"github.com/doka-guide/api/api/utils/mail"
+	"github.com/doka-guide/api/api/utils/throttle"
 )
 
-// GetUserIDByToken — проверка авторизации пользователей
-func GetUserIDByToken(w http.ResponseWriter, r *http.Request) uint64 {
-	uid, err := auth.ExtractTokenID(r)
-	if err != nil {
-		fmt.Printf("Ошибка авторизации: Пользователя таким токеном нет в списке")
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+// RequestUserID — ID пользователя, от имени которого выполняется запрос (сохраняется посредником авторизации)
+func RequestUserID(r *http.Request) uint64 {
+	identity, ok := auth.FromContext(r.Context())
+	if !ok {
 		return 0
 	}
+	return identity.UserID
+}
 
-	return uid
+// AuthorizeRecord — проверка права на операцию с записью автора ownerID: своей (permName) или любой (permName-ANY)
+func AuthorizeRecord(w http.ResponseWriter, r *http.Request, permName string, ownerID uint64) bool {
+	identity, ok := auth.FromContext(r.Context())
+	if !ok || !identity.CanAccess(permName, ownerID) {
+		responses.ERROR(w, http.StatusForbidden, apierror.ErrForbidden)
+		return false
+	}
+	return true
 }
 
-// CheckPermission — проверяет, есть ли права на осуществление запроса
-func CheckPermission(db *gorm.DB, id uint64, permName string) bool {
-	if id == 0 {
+// AuthorizeAllRecords — проверка права на чтение записей всех авторов (permName-ANY), например для сводных отчётов
+func AuthorizeAllRecords(w http.ResponseWriter, r *http.Request, permName string) bool {
+	identity, ok := auth.FromContext(r.Context())
+	if !ok || !identity.Can(permName+auth.AnySuffix) {
+		responses.ERROR(w, http.StatusForbidden, apierror.ErrForbidden)
 		return false
-	} else {
-		users := []models.User{}
-		db.Debug().Raw("SELECT users.* FROM permissions JOIN group_permissions ON group_permissions.perms_id = permissions.id JOIN grouped_users ON grouped_users.group_id = group_permissions.group_id JOIN users ON users.id = grouped_users.user_id WHERE permissions.name = ? AND users.id = ?", permName, id).Scan(&users)
-		if len(users) > 0 {
-			fmt.Printf("Пользователь с id = %d имеет права на операцию '%s'", id, permName)
-		} else {
-			fmt.Printf("Ошибка авторизации: Пользователь с id = %d не имеет права на операцию '%s'", id, permName)
-		}
-		return len(users) > 0
 	}
+	return true
+}
+
+// ScopeRecords — ограничение списка записями пользователя (фильтр author_id), если у него нет права permName-ANY
+func ScopeRecords(r *http.Request, query *models.ListQuery, permName string) {
+	identity, ok := auth.FromContext(r.Context())
+	if ok && identity.Can(permName+auth.AnySuffix) {
+		return
+	}
+	query.Filters["author_id"] = RequestUserID(r)
 }
 
 // Server — Объект Сервер
 type Server struct {
 	DB     *gorm.DB
 	Router *mux.Router
+	Config *config.Config
+	Mailer *mail.Mailer
+
+	// Ограничение неудачных попыток входа для учётных записей и IP-адресов
+	AccountLimiter *throttle.Limiter
+	IPLimiter      *throttle.Limiter
+	Routes         []Route
+
+	// Описание API в формате OpenAPI 3, построенное при запуске
+	openAPI map[string]interface{}
 }
 
-// Initialize — Инициализация сервера
-func (server *Server) Initialize(Dbdriver, DBUser, DBPassword, DBPort, DBHost, DBName string) {
+// Connect — Подключение к базе данных
+func (server *Server) Connect(db config.Database) error {
+	if db.Driver != "postgres" {
+		return fmt.Errorf("Неподдерживаемый драйвер базы данных: '%s'", db.Driver)
+	}
 	var err error
-	if Dbdriver == "postgres" {
-		DBURL := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s", DBHost, DBPort, DBUser, DBName, DBPassword)
-		server.DB, err = gorm.Open(Dbdriver, DBURL)
-		if err != nil {
-			fmt.Printf("Не могу подсоединиться к базе данных, используя драйвер %s", Dbdriver)
-			log.Fatal("Ошибка:", err)
-		} else {
-			fmt.Printf("База данных %s подключена\n", Dbdriver)
-		}
+	DBURL := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s", db.Host, db.Port, db.User, db.Name, db.Password)
+	server.DB, err = gorm.Open(db.Driver, DBURL)
+	if err != nil {
+		return fmt.Errorf("Не могу подсоединиться к базе данных, используя драйвер %s: %v", db.Driver, err)
+	}
+	server.DB.SetLogger(logger.SQL{Logger: logger.Default()})
+	if db.LogSQL {
+		server.DB.LogMode(true)
 	}
+	logger.Info("База данных подключена", "driver", db.Driver)
+	return nil
+}
 
-	// Миграция базы данных
-	server.DB.Debug().AutoMigrate(&models.User{})
+// Initialize — Инициализация сервера
+func (server *Server) Initialize(cfg *config.Config) {
+	server.Config = cfg
+	err := server.Connect(cfg.DB)
+	if err != nil {
+		logger.Fatal("Ошибка подключения к базе данных", "error", err)
+	}
+	server.migrate()
+	models.SetGetLimit(cfg.GetLimit)
+	server.Mailer = NewMailer(cfg.Mail)
+	server.initializeKeyRing()
+	auth.SetDenylist(models.RevokedTokenList{DB: server.DB})
+	auth.SetAPIKeyVerifier(models.APIKeyList{DB: server.DB})
+	server.initializePermissions()
+	server.initializeLimiters()
+	server.initializeMetrics()
 	server.Router = mux.NewRouter()
 	server.initializeRoutes()
 }
 
-// Run — Запуск сервера
-func (server *Server) Run(addr string) {
-	fmt.Println("Запустился на хосте", addr)
-	log.Fatal(http.ListenAndServe(addr, server.Router))
+// NewMailer — Отправитель писем с настройками почтового сервера
+func NewMailer(cfg config.Mail) *mail.Mailer {
+	return &mail.Mailer{
+		Host:           cfg.Host,
+		User:           cfg.User,
+		Password:       cfg.Password,
+		Sender:         cfg.Sender,
+		UnsubscribeURL: cfg.UnsubscribeURL,
+	}
+}
+
+// migrate — Применение новых миграций схемы базы данных (отключается параметром DB_MIGRATE_ON_START=false)
+func (server *Server) migrate() {
+	if !server.Config.DB.MigrateOnStart {
+		return
+	}
+	applied, err := migrations.Up(server.DB)
+	if err != nil {
+		logger.Fatal("Ошибка миграции базы данных", "error", err)
+	}
+	for _, m := range applied {
+		logger.Info("Применена миграция", "version", m.Version, "name", m.Name)
+	}
+}
+
+// initializeKeyRing — Загрузка ключей подписи токенов; без JWT_KEYS_DIR используется HS256 с API_SECRET
+func (server *Server) initializeKeyRing() {
+	cfg := server.Config.Auth
+	if cfg.KeysDir == "" {
+		auth.SetKeyRing(auth.NewSecretKeyRing(cfg.Secret))
+		return
+	}
+	var legacySecret string
+	if cfg.AcceptLegacyHS256 {
+		legacySecret = cfg.Secret
+	}
+	ring, err := auth.LoadKeyRing(cfg.KeysDir, cfg.SigningKey, legacySecret)
+	if err != nil {
+		logger.Fatal("Ошибка загрузки ключей подписи токенов", "error", err)
+	}
+	auth.SetKeyRing(ring)
+}
+
+// initializePermissions — Настройка проверки прав: версия прав кэшируется на PERMISSION_CACHE_TTL,
+// поэтому отозванные разрешения перестают действовать не позже, чем через это время
+func (server *Server) initializePermissions() {
+	auth.SetPermissionStore(models.PermissionStore{DB: server.DB, TTL: server.Config.Auth.PermissionCacheTTL})
+}
+
+// initializeLimiters — Настройка защиты от перебора паролей
+func (server *Server) initializeLimiters() {
+	cfg := server.Config.Login
+	var store throttle.Store
+	if cfg.ThrottleStore == "postgres" {
+		store = models.LoginAttemptStore{DB: server.DB}
+	} else {
+		store = throttle.NewMemoryStore()
+	}
+	server.AccountLimiter = throttle.NewLimiter(store, cfg.MaxFailures, cfg.LockoutDuration)
+	server.IPLimiter = throttle.NewLimiter(store, cfg.IPMaxFailures, cfg.LockoutDuration)
+	server.IPLimiter.FreeAttempts = cfg.IPMaxFailures / 2
+}
+
+// Run — Запуск сервера. По сигналу SIGTERM или SIGINT сервер перестаёт принимать соединения,
+// дожидается завершения запросов и отправки писем (не дольше APP_SHUTDOWN_TIMEOUT) и закрывает базу данных
+func (server *Server) Run(addr string) error {
+	cfg := server.Config.App
+	httpServer := &http.Server{
+		Addr:         addr,
+		Handler:      middlewares.SetMiddlewareRequestLog(middlewares.SetMiddlewareLanguage(server.Router)),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		ErrorLog:     log.New(logger.Default().Writer(logger.LevelWarn), "", 0),
+	}
+
+	failed := make(chan error, 2)
+	go func() {
+		logger.Info("Сервер запущен", "addr", addr)
+		err := httpServer.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			failed <- err
+		}
+	}()
+
+	// Метрики на отдельном адресе, недоступном снаружи
+	var metricsServer *http.Server
+	if server.Config.Metrics.Addr != "" {
+		router := mux.NewRouter()
+		router.HandleFunc("/metrics", server.Metrics).Methods("GET")
+		metricsServer = &http.Server{Addr: server.Config.Metrics.Addr, Handler: router, ReadTimeout: cfg.ReadTimeout, WriteTimeout: cfg.WriteTimeout}
+		go func() {
+			logger.Info("Метрики доступны", "addr", server.Config.Metrics.Addr)
+			err := metricsServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				failed <- err
+			}
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(stop)
+
+	select {
+	case err := <-failed:
+		server.DB.Close()
+		return err
+	case sig := <-stop:
+		logger.Info("Получен сигнал, сервер останавливается", "signal", sig.String())
+		atomic.StoreInt32(&shuttingDown, 1)
+	}
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+	return server.Shutdown(httpServer, cfg.ShutdownTimeout)
+}
+
+// Shutdown — Плавная остановка: завершение запросов, отправка фоновых писем и закрытие базы данных
+func (server *Server) Shutdown(httpServer *http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	problems := []string{}
+	err := httpServer.Shutdown(ctx)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("не все запросы завершены: %v", err))
+	}
+	err = server.Mailer.Wait(ctx)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("не все письма отправлены: %v", err))
+	}
+	err = server.DB.Close()
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("ошибка закрытия базы данных: %v", err))
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	logger.Info("Сервер остановлен")
+	return nil
 }
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/doka-guide/api/api/auth"
+	"github.com/doka-guide/api/api/config"
+	"github.com/doka-guide/api/api/utils/rbac"
+)
+
+// mockServer – сервер с базой данных sqlmock: запросы к базе проверяются по ожиданиям теста
+func mockServer(t *testing.T) (*Server, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open("postgres", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.LogMode(false)
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return &Server{DB: db, Config: &config.Config{}}, mock
+}
+
+// tokenStore – хранилище прав для тестов: права берутся из токена, версия прав не меняется
+type tokenStore struct{}
+
+func (tokenStore) PermissionVersion() int64 { return 1 }
+
+func (tokenStore) UserPermissions(uint64) ([]rbac.Grant, int64, error) { return nil, 1, nil }
+
+func (tokenStore) UserHasPermission(uint64, string) bool { return false }
+
+func (tokenStore) GroupHasPermission(uint64, string) bool { return false }
+
+func TestAuthorizeRecord(t *testing.T) {
+	auth.SetPermissionStore(tokenStore{})
+	t.Cleanup(func() { auth.SetPermissionStore(nil) })
+
+	const owner, other = 1, 2
+	// Права, которые проверяют обработчики форм, подписок, ссылок на профиль и отчётов о рассылке
+	perms := []string{
+		"FORM-GET", "FORM-POST", "FORM-PUT", "FORM-DELETE",
+		"SUBSCRIPTION-GET", "SUBSCRIPTION-POST", "SUBSCRIPTION-PUT", "SUBSCRIPTION-DELETE",
+		"PROFILE-LINK-GET", "PROFILE-LINK-DELETE",
+		"SUBSCRIPTION-REPORT-GET", "SUBSCRIPTION-REPORT-DELETE",
+	}
+	for _, perm := range perms {
+		tests := []struct {
+			title  string
+			userID uint64
+			allow  []string
+			deny   []string
+			want   bool
+		}{
+			{"owner with PERM", owner, []string{perm}, nil, true},
+			{"non-owner with PERM", other, []string{perm}, nil, false},
+			{"non-owner with PERM-ANY", other, []string{perm + auth.AnySuffix}, nil, true},
+			{"owner with denied PERM", owner, []string{"*"}, []string{perm}, false},
+			{"non-owner with denied PERM", other, []string{"*"}, []string{perm}, false},
+		}
+		for _, tt := range tests {
+			t.Run(perm+"/"+tt.title, func(t *testing.T) {
+				identity := &auth.Identity{UserID: tt.userID, Permissions: tt.allow, Denies: tt.deny, PermissionVersion: 1}
+				r := httptest.NewRequest(http.MethodDelete, "/v1/record/1", nil)
+				r = r.WithContext(auth.NewContext(r.Context(), identity))
+				w := httptest.NewRecorder()
+
+				got := AuthorizeRecord(w, r, perm, owner)
+				if got != tt.want {
+					t.Fatalf("AuthorizeRecord(%q) = %v, want %v", perm, got, tt.want)
+				}
+				if got {
+					if w.Body.Len() != 0 {
+						t.Errorf("response written for allowed request: %s", w.Body.String())
+					}
+					return
+				}
+				if w.Code != http.StatusForbidden {
+					t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+				}
+				body := struct {
+					Code apierror.Code `json:"code"`
+				}{}
+				err := json.Unmarshal(w.Body.Bytes(), &body)
+				if err != nil || body.Code != apierror.CodeForbidden {
+					t.Errorf("body = %s, want code %s", w.Body.String(), apierror.CodeForbidden)
+				}
+			})
+		}
+	}
+
+	t.Run("no identity", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		if AuthorizeRecord(w, httptest.NewRequest(http.MethodDelete, "/v1/form/1", nil), "FORM-DELETE", owner) {
+			t.Fatal("request without identity authorized")
+		}
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+		}
+	})
+}
+
+func TestAuthorizeAllRecords(t *testing.T) {
+	auth.SetPermissionStore(tokenStore{})
+	t.Cleanup(func() { auth.SetPermissionStore(nil) })
+
+	tests := []struct {
+		title string
+		allow []string
+		deny  []string
+		want  bool
+	}{
+		{"PERM", []string{"FORM-GET"}, nil, false},
+		{"PERM-ANY", []string{"FORM-GET-ANY"}, nil, true},
+		{"wildcard", []string{"FORM-*"}, nil, true},
+		{"denied PERM", []string{"FORM-*"}, []string{"FORM-GET"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			identity := &auth.Identity{UserID: 1, Permissions: tt.allow, Denies: tt.deny, PermissionVersion: 1}
+			r := httptest.NewRequest(http.MethodGet, "/v1/form/feedback/2024-01-01/2024-02-01", nil)
+			r = r.WithContext(auth.NewContext(r.Context(), identity))
+			w := httptest.NewRecorder()
+			if got := AuthorizeAllRecords(w, r, "FORM-GET"); got != tt.want {
+				t.Fatalf("AuthorizeAllRecords = %v, want %v", got, tt.want)
+			}
+			if !tt.want && w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+// TestListScope – без права PERM-ANY списки форм, подписок, ссылок и отчётов содержат только записи пользователя
+func TestListScope(t *testing.T) {
+	auth.SetPermissionStore(tokenStore{})
+	t.Cleanup(func() { auth.SetPermissionStore(nil) })
+
+	const owner = 7
+	lists := []struct {
+		perm  string
+		table string
+		scope string
+		get   func(server *Server) http.HandlerFunc
+	}{
+		{"FORM-GET", "forms", `author_id = \$1`, func(s *Server) http.HandlerFunc { return s.GetForms }},
+		{"SUBSCRIPTION-GET", "subscriptions", `author_id = \$1`, func(s *Server) http.HandlerFunc { return s.GetSubscriptions }},
+		{"PROFILE-LINK-GET", "profile_links", `author_id = \$1`, func(s *Server) http.HandlerFunc { return s.GetProfileLinks }},
+		{"SUBSCRIPTION-REPORT-GET", "subscription_reports", `profile_id IN \(SELECT id FROM subscriptions WHERE author_id = \$1\)`, func(s *Server) http.HandlerFunc { return s.GetSubscriptionReports }},
+	}
+	for _, list := range lists {
+		t.Run(list.perm, func(t *testing.T) {
+			server, mock := mockServer(t)
+			mock.ExpectQuery(`SELECT \* FROM "` + list.table + `" WHERE \(` + list.scope + `\) ORDER BY`).
+				WithArgs(owner).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			r := httptest.NewRequest(http.MethodGet, "/v1/list?author_id=1", nil)
+			identity := &auth.Identity{UserID: owner, Permissions: []string{list.perm}, PermissionVersion: 1}
+			w := httptest.NewRecorder()
+			list.get(server)(w, r.WithContext(auth.NewContext(r.Context(), identity)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+			}
+		})
+		t.Run(list.perm+auth.AnySuffix, func(t *testing.T) {
+			server, mock := mockServer(t)
+			mock.ExpectQuery(`SELECT \* FROM "` + list.table + `" +ORDER BY`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			r := httptest.NewRequest(http.MethodGet, "/v1/list", nil)
+			identity := &auth.Identity{UserID: owner, Permissions: []string{list.perm + auth.AnySuffix}, PermissionVersion: 1}
+			w := httptest.NewRecorder()
+			list.get(server)(w, r.WithContext(auth.NewContext(r.Context(), identity)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+			}
+		})
+	}
+}
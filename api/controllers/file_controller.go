@@ -9,24 +9,16 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
 	"time"
 
 	"github.com/doka-guide/api/api/responses"
-	"github.com/joho/godotenv"
+	"github.com/doka-guide/api/api/utils/logger"
 )
 
 // UploadFile – Загрузка файла из формы
 func (server *Server) UploadFile(w http.ResponseWriter, r *http.Request) {
-	err := godotenv.Load()
-	if err != nil {
-		responses.ERROR(w, http.StatusInternalServerError, err)
-	}
-	maxUploadSize, err := strconv.ParseInt(os.Getenv("UPLOAD_MAX_SIZE"), 10, 64)
-	if err != nil {
-		responses.ERROR(w, http.StatusInternalServerError, err)
-	}
-	uploadPath := os.Getenv("UPLOAD_FOLDER")
+	maxUploadSize := server.Config.Upload.MaxSize
+	uploadPath := server.Config.Upload.Folder
 
 	// Проверка размера файла
 	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
@@ -92,7 +84,8 @@ func (server *Server) UploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fmt.Printf("Файл загружен на сервер")
+	uploadSize.Observe(float64(len(fileBytes)))
+	logger.FromContext(r.Context()).Info("Файл загружен на сервер", "type", detectedFileType, "file", newPath)
 
 	responses.JSON(w, http.StatusOK, successMessage)
 }
@@ -3,25 +3,19 @@ package controllers
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/doka-guide/api/api/models"
 	"github.com/doka-guide/api/api/responses"
-	"github.com/doka-guide/api/api/utils/formaterror"
 	"github.com/gorilla/mux"
 )
 
 // CreateForm – Создание записи о новой отправленной форме
 func (server *Server) CreateForm(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "FORM-POST") {
-		return
-	}
-
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
@@ -40,12 +34,17 @@ func (server *Server) CreateForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Создать форму от имени другого пользователя можно только с правом FORM-POST-ANY
+	if !AuthorizeRecord(w, r, "FORM-POST", form.AuthorID) {
+		return
+	}
+
 	formCreated, err := form.SaveForm(server.DB)
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	formsCreated.Inc(formCreated.Type)
 
 	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.URL.Path, formCreated.ID))
 	responses.JSON(w, http.StatusCreated, formCreated)
@@ -68,27 +67,24 @@ func (server *Server) OptionsForms(w http.ResponseWriter, r *http.Request) {
 
 // GetForms – Вывод всех форм
 func (server *Server) GetForms(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "FORM-GET") {
+	form := models.Form{}
+	query, ok := listQuery(w, r, models.FormListSpec)
+	if !ok {
 		return
 	}
-
-	form := models.Form{}
-	forms, err := form.FindAllForms(server.DB)
+	// Чужие формы выводятся только с правом FORM-GET-ANY
+	ScopeRecords(r, &query, "FORM-GET")
+	forms, next, err := form.FindAllForms(server.DB, query)
 	if err != nil {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	setNextPage(w, r, next)
 	responses.JSON(w, http.StatusOK, forms)
 }
 
 // GetForm – Вывод формы по ID
 func (server *Server) GetForm(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "FORM-GET") {
-		return
-	}
-
 	vars := mux.Vars(r)
 	pid, err := strconv.ParseUint(vars["id"], 10, 64)
 	if err != nil {
@@ -102,17 +98,15 @@ func (server *Server) GetForm(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	// Чужую форму можно получить только с правом FORM-GET-ANY
+	if !AuthorizeRecord(w, r, "FORM-GET", formReceived.AuthorID) {
+		return
+	}
 	responses.JSON(w, http.StatusOK, formReceived)
 }
 
 // UpdateForm – Обновление информации в форме
 func (server *Server) UpdateForm(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	uid := GetUserIDByToken(w, r)
-	if !CheckPermission(server.DB, uid, "FORM-PUT") {
-		return
-	}
-
 	vars := mux.Vars(r)
 
 	// Валидация полей формы
@@ -124,15 +118,14 @@ func (server *Server) UpdateForm(w http.ResponseWriter, r *http.Request) {
 
 	// Проверка существования формы
 	form := models.Form{}
-	err = server.DB.Debug().Model(models.Form{}).Where("id = ?", pid).Take(&form).Error
+	err = server.DB.Model(models.Form{}).Where("id = ?", pid).Take(&form).Error
 	if err != nil {
-		responses.ERROR(w, http.StatusNotFound, errors.New("Form not found"))
+		responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
 		return
 	}
 
-	// Если пользователь захочет обновить форму, которая отправлена не от него
-	if uid != form.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+	// Чужую форму можно обновить только с правом FORM-PUT-ANY
+	if !AuthorizeRecord(w, r, "FORM-PUT", form.AuthorID) {
 		return
 	}
 	// Чтение данных формы
@@ -150,13 +143,9 @@ func (server *Server) UpdateForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Проверка авторизации
-	if uid != formUpdate.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
-		return
-	}
-
+	// Автор формы не меняется
 	formUpdate.Prepare()
+	formUpdate.AuthorID = form.AuthorID
 	err = formUpdate.Validate()
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
@@ -167,8 +156,7 @@ func (server *Server) UpdateForm(w http.ResponseWriter, r *http.Request) {
 	formUpdated, err := formUpdate.UpdateAForm(server.DB)
 
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 	responses.JSON(w, http.StatusOK, formUpdated)
@@ -176,12 +164,6 @@ func (server *Server) UpdateForm(w http.ResponseWriter, r *http.Request) {
 
 // DeleteForm – Удаляет данные формы из базы данных
 func (server *Server) DeleteForm(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	uid := GetUserIDByToken(w, r)
-	if !CheckPermission(server.DB, uid, "FORM-DELETE") {
-		return
-	}
-
 	vars := mux.Vars(r)
 
 	// Валидация формы
@@ -193,33 +175,31 @@ func (server *Server) DeleteForm(w http.ResponseWriter, r *http.Request) {
 
 	// Проверка наличия формы
 	form := models.Form{}
-	err = server.DB.Debug().Model(models.Form{}).Where("id = ?", pid).Take(&form).Error
+	err = server.DB.Model(models.Form{}).Where("id = ?", pid).Take(&form).Error
 	if err != nil {
-		responses.ERROR(w, http.StatusNotFound, errors.New("Unauthorized"))
+		responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
 		return
 	}
 
-	// Проверка принадлежности формы пользователю
-	if uid != form.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+	// Чужую форму можно удалить только с правом FORM-DELETE-ANY
+	if !AuthorizeRecord(w, r, "FORM-DELETE", form.AuthorID) {
 		return
 	}
-	_, err = form.DeleteAForm(server.DB, pid, uid)
+	_, err = form.DeleteAForm(server.DB, pid, form.AuthorID)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 	w.Header().Set("Entity", fmt.Sprintf("%d", pid))
-	responses.JSON(w, http.StatusNoContent, "")
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // GetFeedbackForms – Вывод информации о заполненных формах обратной связи за период
 func (server *Server) GetFeedbackForms(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "FORM-GET") {
+	// Сводка по формам всех авторов доступна только с правом FORM-GET-ANY
+	if !AuthorizeAllRecords(w, r, "FORM-GET") {
 		return
 	}
-
 	vars := mux.Vars(r)
 	start := vars["start"]
 	end := vars["end"]
@@ -231,11 +211,10 @@ func (server *Server) GetFeedbackForms(w http.ResponseWriter, r *http.Request) {
 
 // GetQuestionForms – Вывод информации о заполненных формах обратной связи за период
 func (server *Server) GetQuestionForms(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "FORM-GET") {
+	// Сводка по формам всех авторов доступна только с правом FORM-GET-ANY
+	if !AuthorizeAllRecords(w, r, "FORM-GET") {
 		return
 	}
-
 	vars := mux.Vars(r)
 	start := vars["start"]
 	end := vars["end"]
@@ -0,0 +1,338 @@
+// Package controllers - пакет для обработки данных запросов
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/responses"
+	"github.com/gorilla/mux"
+)
+
+// OptionsGroups – Для предварительной загрузки (prefetch)
+func (server *Server) OptionsGroups(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, []byte("Запрос OPTIONS обработан"))
+}
+
+// groupTaken – Проверка, заняты ли название или почта группы другой записью
+func (server *Server) groupTaken(group *models.UserGroup, exceptID uint64) bool {
+	var count int
+	server.DB.Model(&models.UserGroup{}).Where("(name = ? OR email = ?) AND id <> ?", group.Name, group.Email, exceptID).Count(&count)
+	return count > 0
+}
+
+// groupFromRequest – Поиск группы по параметру id из адреса запроса
+func (server *Server) groupFromRequest(w http.ResponseWriter, r *http.Request) (*models.UserGroup, bool) {
+	vars := mux.Vars(r)
+	gid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	group := models.UserGroup{}
+	groupReceived, err := group.FindUserGroupByID(server.DB, gid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return nil, false
+	}
+	return groupReceived, true
+}
+
+// CreateGroup – Создание группы пользователей
+func (server *Server) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	group := models.UserGroup{}
+	err = json.Unmarshal(body, &group)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	group.Prepare()
+	err = group.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if server.groupTaken(&group, 0) {
+		responses.ERROR(w, http.StatusConflict, apierror.ErrAlreadyExists)
+		return
+	}
+	err = group.ValidateParent(server.DB, 0)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	groupCreated, err := group.SaveUserGroup(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.URL.Path, groupCreated.ID))
+	responses.JSON(w, http.StatusCreated, groupCreated)
+}
+
+// GetGroups – Вывод всех групп пользователей
+func (server *Server) GetGroups(w http.ResponseWriter, r *http.Request) {
+	group := models.UserGroup{}
+	query, ok := listQuery(w, r, models.UserGroupListSpec)
+	if !ok {
+		return
+	}
+	groups, next, err := group.FindAllUserGroups(server.DB, query)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	setNextPage(w, r, next)
+	responses.JSON(w, http.StatusOK, groups)
+}
+
+// GetGroup – Вывод группы пользователей по ID
+func (server *Server) GetGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := server.groupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	responses.JSON(w, http.StatusOK, group)
+}
+
+// UpdateGroup – Обновление группы пользователей
+func (server *Server) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	current, ok := server.groupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	group := models.UserGroup{}
+	err = json.Unmarshal(body, &group)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	group.Prepare()
+	err = group.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if server.groupTaken(&group, current.ID) {
+		responses.ERROR(w, http.StatusConflict, apierror.ErrAlreadyExists)
+		return
+	}
+	err = group.ValidateParent(server.DB, current.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	groupUpdated, err := group.UpdateAUserGroup(server.DB, current.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, groupUpdated)
+}
+
+// DeleteGroup – Удаление группы пользователей вместе с её участниками и разрешениями
+func (server *Server) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	current, ok := server.groupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	var count int
+	server.DB.Model(&models.APIKey{}).Where("group_id = ? AND revoked_at IS NULL", current.ID).Count(&count)
+	if count > 0 {
+		responses.ERROR(w, http.StatusConflict, apierror.New(apierror.CodeGroupHasAPIKeys, http.StatusConflict))
+		return
+	}
+	group := models.UserGroup{}
+	_, err := group.DeleteAUserGroup(server.DB, current.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Entity", fmt.Sprintf("%d", current.ID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetGroupUsers – Вывод участников группы пользователей
+func (server *Server) GetGroupUsers(w http.ResponseWriter, r *http.Request) {
+	group, ok := server.groupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	groupedUser := models.GroupedUser{}
+	query, ok := listQuery(w, r, models.GroupedUserListSpec)
+	if !ok {
+		return
+	}
+	groupedUsers, next, err := groupedUser.FindAllGroupedUserWithUserGroupID(server.DB, strconv.FormatUint(group.ID, 10), query)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	setNextPage(w, r, next)
+	responses.JSON(w, http.StatusOK, groupedUsers)
+}
+
+// AddGroupUser – Добавление пользователя в группу
+func (server *Server) AddGroupUser(w http.ResponseWriter, r *http.Request) {
+	group, ok := server.groupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	groupedUser := models.GroupedUser{}
+	err = json.Unmarshal(body, &groupedUser)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	groupedUser.Prepare()
+	groupedUser.GroupID = group.ID
+	err = groupedUser.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	err = server.DB.Model(&models.User{}).Where("id = ?", groupedUser.UserID).Take(&models.User{}).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apierror.Reference("user_id"))
+		return
+	}
+	if groupedUser.IsDuplicate(server.DB) {
+		responses.ERROR(w, http.StatusConflict, apierror.ErrAlreadyExists)
+		return
+	}
+
+	groupedUserCreated, err := groupedUser.SaveGroupedUser(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.URL.Path, groupedUserCreated.UserID))
+	responses.JSON(w, http.StatusCreated, groupedUserCreated)
+}
+
+// RemoveGroupUser – Исключение пользователя из группы
+func (server *Server) RemoveGroupUser(w http.ResponseWriter, r *http.Request) {
+	group, ok := server.groupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	uid, err := strconv.ParseUint(mux.Vars(r)["user_id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	groupedUser := models.GroupedUser{}
+	_, err = groupedUser.DeleteAGroupedUserByPair(server.DB, group.ID, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Entity", fmt.Sprintf("%d", uid))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetGroupPermissions – Вывод разрешений группы пользователей
+func (server *Server) GetGroupPermissions(w http.ResponseWriter, r *http.Request) {
+	group, ok := server.groupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	groupPermission := models.GroupPermission{}
+	query, ok := listQuery(w, r, models.GroupPermissionListSpec)
+	if !ok {
+		return
+	}
+	groupPermissions, next, err := groupPermission.FindAllGroupPermissionWithUserGroupID(server.DB, group.ID, query)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	setNextPage(w, r, next)
+	responses.JSON(w, http.StatusOK, groupPermissions)
+}
+
+// AddGroupPermission – Выдача разрешения группе пользователей
+func (server *Server) AddGroupPermission(w http.ResponseWriter, r *http.Request) {
+	group, ok := server.groupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	groupPermission := models.GroupPermission{}
+	err = json.Unmarshal(body, &groupPermission)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	groupPermission.Prepare()
+	groupPermission.GroupID = group.ID
+	err = groupPermission.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	err = server.DB.Model(&models.Permission{}).Where("id = ?", groupPermission.PermsID).Take(&models.Permission{}).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apierror.Reference("perms_id"))
+		return
+	}
+	if groupPermission.IsDuplicate(server.DB) {
+		responses.ERROR(w, http.StatusConflict, apierror.ErrAlreadyExists)
+		return
+	}
+
+	groupPermissionCreated, err := groupPermission.SaveGroupPermission(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.URL.Path, groupPermissionCreated.PermsID))
+	responses.JSON(w, http.StatusCreated, groupPermissionCreated)
+}
+
+// RemoveGroupPermission – Отзыв разрешения у группы пользователей
+func (server *Server) RemoveGroupPermission(w http.ResponseWriter, r *http.Request) {
+	group, ok := server.groupFromRequest(w, r)
+	if !ok {
+		return
+	}
+	pid, err := strconv.ParseUint(mux.Vars(r)["perms_id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	groupPermission := models.GroupPermission{}
+	_, err = groupPermission.DeleteAGroupPermissionByPair(server.DB, group.ID, pid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Entity", fmt.Sprintf("%d", pid))
+	w.WriteHeader(http.StatusNoContent)
+}
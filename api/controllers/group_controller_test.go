@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+func TestUpdateGroup(t *testing.T) {
+	server, mock := mockServer(t)
+	mock.ExpectQuery(`SELECT \* FROM "user_groups" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}).AddRow(3, "editors", "editors@example.com"))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "user_groups" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT \* FROM "user_groups" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "user_groups" SET "email" = \$1, "name" = \$2, "parent_id" = \$3, "require_mfa" = \$4, "updated_at" = \$5 WHERE`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`INSERT INTO permission_versions`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "user_groups" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}).AddRow(3, "authors", "authors@example.com"))
+
+	r := httptest.NewRequest(http.MethodPut, "/v1/group/3", strings.NewReader(`{"name":"authors","email":"authors@example.com"}`))
+	r = mux.SetURLVars(r, map[string]string{"id": "3"})
+	w := httptest.NewRecorder()
+	server.UpdateGroup(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"name":"authors"`) {
+		t.Errorf("body = %s, want updated group", w.Body.String())
+	}
+}
@@ -0,0 +1,153 @@
+// Package controllers - пакет для обработки данных запросов
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/doka-guide/api/api/responses"
+)
+
+// Результаты проверки зависимостей
+const (
+	HealthOK      = "ok"
+	HealthFail    = "fail"
+	HealthSkipped = "skipped"
+)
+
+// HealthCheck – результат проверки одной зависимости
+type HealthCheck struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// HealthReport – результат проверки всех зависимостей
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// shuttingDown – сервер останавливается: проверка готовности возвращает 503, чтобы балансировщик
+// перестал направлять запросы (1 — останавливается)
+var shuttingDown int32
+
+// Healthz – Проверка, что процесс работает (зависимости не проверяются)
+func (server *Server) Healthz(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, HealthReport{Status: HealthOK})
+}
+
+// Readyz – Проверка готовности принимать запросы: база данных, каталог загрузок, шаблоны писем
+// и, если включено HEALTH_CHECK_SMTP, почтовый сервер. Каждая проверка ограничена HEALTH_TIMEOUT
+func (server *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), server.Config.Health.Timeout)
+	defer cancel()
+
+	checks := map[string]func(ctx context.Context) error{
+		"database":  server.checkDatabase,
+		"uploads":   server.checkUploads,
+		"templates": server.checkTemplates,
+	}
+	if server.Config.Health.CheckSMTP {
+		checks["smtp"] = server.checkSMTP
+	}
+
+	report := HealthReport{Status: HealthOK, Checks: map[string]HealthCheck{}}
+	if !server.Config.Health.CheckSMTP {
+		report.Checks["smtp"] = HealthCheck{Status: HealthSkipped}
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			start := time.Now()
+			result := HealthCheck{Status: HealthOK}
+			if err := runCheck(ctx, check); err != nil {
+				result = HealthCheck{Status: HealthFail, Error: err.Error()}
+			}
+			result.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+			mu.Lock()
+			report.Checks[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	for _, check := range report.Checks {
+		if check.Status == HealthFail {
+			report.Status = HealthFail
+			status = http.StatusServiceUnavailable
+		}
+	}
+	if atomic.LoadInt32(&shuttingDown) == 1 {
+		report.Status = HealthFail
+		report.Checks["server"] = HealthCheck{Status: HealthFail, Error: "сервер останавливается"}
+		status = http.StatusServiceUnavailable
+	}
+	responses.JSON(w, status, report)
+}
+
+// runCheck – Проверка, которая не ждёт дольше ctx, даже если сама не учитывает контекст
+func runCheck(ctx context.Context, check func(ctx context.Context) error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// checkDatabase – Соединение с PostgreSQL отвечает
+func (server *Server) checkDatabase(ctx context.Context) error {
+	return server.DB.DB().PingContext(ctx)
+}
+
+// checkUploads – В каталог загрузок можно записать файл
+func (server *Server) checkUploads(ctx context.Context) error {
+	folder := server.Config.Upload.Folder
+	if folder == "" {
+		folder = "."
+	}
+	file, err := ioutil.TempFile(folder, ".readyz-")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// checkTemplates – Шаблоны писем можно прочитать
+func (server *Server) checkTemplates(ctx context.Context) error {
+	mail := server.Config.Mail
+	for _, path := range []string{mail.BodyVerifyText, mail.BodyVerifyHTML, mail.BodyResetText, mail.BodyResetHTML, mail.BodyHiText, mail.BodyHiHTML} {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		file.Close()
+	}
+	return nil
+}
+
+// checkSMTP – Почтовый сервер принимает соединения
+func (server *Server) checkSMTP(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", server.Config.Mail.Host)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -3,14 +3,13 @@ package controllers
 
 import (
 	"net/http"
-	"os"
 
 	"github.com/doka-guide/api/api/responses"
 )
 
 // Home — API точка входа
 func (server *Server) Home(w http.ResponseWriter, r *http.Request) {
-	responses.JSON(w, http.StatusOK, os.Getenv("APP_NAME"))
+	responses.JSON(w, http.StatusOK, server.Config.App.Name)
 }
 
 // OptionsHome – Используется для подготовки соединения
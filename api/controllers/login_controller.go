@@ -4,12 +4,18 @@ package controllers
 import (
 	"encoding/json"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/doka-guide/api/api/auth"
 	"github.com/doka-guide/api/api/models"
 	"github.com/doka-guide/api/api/responses"
-	"github.com/doka-guide/api/api/utils/formaterror"
+	"github.com/doka-guide/api/api/utils/logger"
+	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -38,27 +44,112 @@ func (server *Server) Login(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
-	token, err := server.SignIn(user.Email, user.Password)
+	ip := server.ClientIP(r)
+	if server.LoginThrottled(w, r, user.Email, ip) {
+		return
+	}
+	tokens, challenge, err := server.SignIn(user.Email, user.Password)
+	if err == ErrEmailNotVerified {
+		responses.ERROR(w, http.StatusForbidden, err)
+		return
+	}
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusUnprocessableEntity, formattedError)
+		server.LoginFailed(r, user.Email, ip)
+		// Неизвестная почта и неверный пароль неразличимы для клиента
+		if gorm.IsRecordNotFoundError(err) || err == bcrypt.ErrMismatchedHashAndPassword {
+			err = apierror.ErrInvalidCredentials
+		}
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if challenge != nil {
+		responses.JSON(w, http.StatusOK, challenge)
 		return
 	}
-	responses.JSON(w, http.StatusOK, token)
+	server.LoginSucceeded(r, user.Email)
+	responses.JSON(w, http.StatusOK, tokens)
+}
+
+// ClientIP – IP-адрес клиента (заголовки прокси учитываются, только если APP_TRUST_PROXY=true)
+func (server *Server) ClientIP(r *http.Request) string {
+	if server.Config.App.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return strings.TrimSpace(realIP)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// LoginThrottled – Проверка, не заблокированы ли попытки входа для учётной записи или IP-адреса (при блокировке отправляет 429)
+func (server *Server) LoginThrottled(w http.ResponseWriter, r *http.Request, email string, ip string) bool {
+	accountWait, err := server.AccountLimiter.Check("account:" + email)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("Не удалось проверить счётчик попыток входа", "error", err)
+	}
+	ipWait, err := server.IPLimiter.Check("ip:" + ip)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("Не удалось проверить счётчик попыток входа", "error", err)
+	}
+	wait := accountWait
+	if ipWait > wait {
+		wait = ipWait
+	}
+	if wait <= 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	responses.ERROR(w, http.StatusTooManyRequests, apierror.ErrTooManyRequests)
+	return true
 }
 
-// SignIn - Обработка учётных данных пользователя
-func (server *Server) SignIn(email, password string) (string, error) {
+// LoginFailed – Учёт неудачной попытки входа
+func (server *Server) LoginFailed(r *http.Request, email string, ip string) {
+	if err := server.AccountLimiter.Fail("account:" + email); err != nil {
+		logger.FromContext(r.Context()).Error("Не удалось обновить счётчик попыток входа", "error", err)
+	}
+	if err := server.IPLimiter.Fail("ip:" + ip); err != nil {
+		logger.FromContext(r.Context()).Error("Не удалось обновить счётчик попыток входа", "error", err)
+	}
+}
+
+// LoginSucceeded – Сброс счётчика неудачных попыток входа для учётной записи
+func (server *Server) LoginSucceeded(r *http.Request, email string) {
+	if err := server.AccountLimiter.Succeed("account:" + email); err != nil {
+		logger.FromContext(r.Context()).Error("Не удалось сбросить счётчик попыток входа", "error", err)
+	}
+}
+
+// SignIn - Обработка учётных данных пользователя (если нужен второй фактор, вместо токенов возвращается запрос кода)
+func (server *Server) SignIn(email, password string) (*auth.TokenPair, *MFAChallenge, error) {
 	var err error
 	user := models.User{}
 
-	err = server.DB.Debug().Model(models.User{}).Where("email = ?", email).Take(&user).Error
+	err = server.DB.Model(models.User{}).Where("email = ?", email).Take(&user).Error
 	if err != nil {
-		return "", err
+		return &auth.TokenPair{}, nil, err
 	}
 	err = models.VerifyPassword(user.Password, password)
-	if err != nil && err == bcrypt.ErrMismatchedHashAndPassword {
-		return "", err
+	if err != nil {
+		// Любая ошибка проверки (в том числе повреждённый хэш) считается неверным паролем
+		return &auth.TokenPair{}, nil, bcrypt.ErrMismatchedHashAndPassword
+	}
+	if server.RequireVerifiedEmail() && !user.IsVerified() {
+		return &auth.TokenPair{}, nil, ErrEmailNotVerified
+	}
+	if user.IsTOTPEnabled() || user.RequiresTOTP(server.DB, user.ID) {
+		mfaToken, err := auth.CreateMFAToken(user.ID)
+		if err != nil {
+			return &auth.TokenPair{}, nil, err
+		}
+		return &auth.TokenPair{}, &MFAChallenge{MFARequired: true, MFAToken: mfaToken, MFAEnrolled: user.IsTOTPEnabled()}, nil
 	}
-	return auth.CreateToken(user.ID)
+	_, tokens, err := server.IssueTokens(user.ID)
+	return tokens, nil, err
 }
@@ -0,0 +1,54 @@
+// Package controllers - пакет для обработки данных запросов
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/doka-guide/api/api/utils/metrics"
+)
+
+// Метрики создания записей и загрузки файлов
+var (
+	formsCreated = metrics.NewCounterVec("doka_forms_created_total",
+		"Количество созданных форм по типу", "type").Limit(50)
+	subscriptionsCreated = metrics.NewCounterVec("doka_subscriptions_created_total",
+		"Количество созданных подписок")
+	uploadSize = metrics.NewHistogramVec("doka_upload_size_bytes",
+		"Размер загруженных файлов в байтах", []float64{1 << 10, 10 << 10, 100 << 10, 512 << 10, 1 << 20, 5 << 20, 10 << 20, 50 << 20})
+)
+
+// initializeMetrics – Метрики пула соединений с базой данных
+func (server *Server) initializeMetrics() {
+	db := server.DB.DB()
+	metrics.NewGaugeFunc("doka_db_open_connections", "Открытые соединения с базой данных", func() float64 {
+		return float64(db.Stats().OpenConnections)
+	})
+	metrics.NewGaugeFunc("doka_db_in_use_connections", "Соединения с базой данных, занятые запросами", func() float64 {
+		return float64(db.Stats().InUse)
+	})
+	metrics.NewGaugeFunc("doka_db_idle_connections", "Свободные соединения с базой данных", func() float64 {
+		return float64(db.Stats().Idle)
+	})
+	metrics.NewCounterFunc("doka_db_wait_count_total", "Количество ожиданий свободного соединения", func() float64 {
+		return float64(db.Stats().WaitCount)
+	})
+	metrics.NewCounterFunc("doka_db_wait_duration_seconds_total", "Общее время ожидания свободного соединения", func() float64 {
+		return db.Stats().WaitDuration.Seconds()
+	})
+}
+
+// Metrics – Метрики в текстовом формате Prometheus; если задан METRICS_TOKEN, нужен заголовок Authorization: Bearer <токен>
+func (server *Server) Metrics(w http.ResponseWriter, r *http.Request) {
+	token := server.Config.Metrics.Token
+	if token != "" {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+	}
+	metrics.Handler().ServeHTTP(w, r)
+}
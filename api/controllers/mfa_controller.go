@@ -0,0 +1,354 @@
+// Package controllers - пакет для обработки данных запросов
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/doka-guide/api/api/auth"
+	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/responses"
+	"github.com/doka-guide/api/api/utils/randomize"
+	"github.com/doka-guide/api/api/utils/totp"
+)
+
+// RecoveryCodesCount – Количество кодов восстановления, которые выдаются пользователю
+const RecoveryCodesCount = 10
+
+// ErrSecondFactorInvalid – Неверный код двухфакторной аутентификации
+var ErrSecondFactorInvalid = apierror.New(apierror.CodeMFACodeInvalid, http.StatusUnprocessableEntity)
+
+// MFARequest – Тело запросов двухфакторной аутентификации
+type MFARequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFAChallenge – Ответ на вход по паролю, если нужен второй фактор
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	MFAEnrolled bool   `json:"mfa_enrolled"`
+}
+
+// MFAEnrolment – Секрет для приложения-аутентификатора
+type MFAEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// MFALoginResult – Пара токенов и, если двухфакторная аутентификация только что включена, коды восстановления
+type MFALoginResult struct {
+	*auth.TokenPair
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// readMFARequest – Чтение тела запроса двухфакторной аутентификации
+func readMFARequest(r *http.Request) (*MFARequest, error) {
+	request := MFARequest{}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return &request, err
+	}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &request)
+	}
+	return &request, err
+}
+
+// currentUser – Пользователь, который выполняет запрос (ключи доступа сервисов не подходят)
+func (server *Server) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	identity, err := auth.ExtractIdentity(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
+		return &models.User{}, false
+	}
+	if identity.APIKey {
+		responses.ERROR(w, http.StatusForbidden, apierror.ErrForbidden)
+		return &models.User{}, false
+	}
+	user := models.User{}
+	_, err = user.FindUserByID(server.DB, identity.UserID)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
+		return &models.User{}, false
+	}
+	return &user, true
+}
+
+// enrollTOTP – Создание нового секрета для пользователя, который ещё не включил двухфакторную аутентификацию
+func (server *Server) enrollTOTP(user *models.User) (*MFAEnrolment, error) {
+	if user.IsTOTPEnabled() {
+		return &MFAEnrolment{}, apierror.New(apierror.CodeMFAAlreadyEnabled, http.StatusConflict)
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return &MFAEnrolment{}, err
+	}
+	err = user.SetTOTPSecret(server.DB, user.ID, secret)
+	if err != nil {
+		return &MFAEnrolment{}, err
+	}
+	return &MFAEnrolment{Secret: secret, URI: totp.URI(server.Config.App.Name, user.Email, secret)}, nil
+}
+
+// checkTOTP – Проверка кода из приложения-аутентификатора (каждый код принимается только один раз)
+func (server *Server) checkTOTP(user *models.User, code string) error {
+	if user.TOTPSecret == "" {
+		return ErrSecondFactorInvalid
+	}
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return ErrSecondFactorInvalid
+	}
+	if err := user.UseTOTPStep(server.DB, user.ID, step); err != nil {
+		return ErrSecondFactorInvalid
+	}
+	return nil
+}
+
+// checkSecondFactor – Проверка кода из приложения-аутентификатора или кода восстановления
+func (server *Server) checkSecondFactor(user *models.User, request *MFARequest) error {
+	if request.RecoveryCode != "" {
+		recovery := models.RecoveryCode{}
+		if err := recovery.UseARecoveryCode(server.DB, user.ID, auth.HashToken(normalizeRecoveryCode(request.RecoveryCode))); err != nil {
+			return ErrSecondFactorInvalid
+		}
+		return nil
+	}
+	return server.checkTOTP(user, request.Code)
+}
+
+// normalizeRecoveryCode – Приведение кода восстановления к виду, в котором хранится его хэш
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// generateRecoveryCodes – Выдача новых кодов восстановления (старые становятся недействительными)
+func (server *Server) generateRecoveryCodes(uid uint64) ([]string, error) {
+	codes := make([]string, 0, RecoveryCodesCount)
+	hashes := make([]string, 0, RecoveryCodesCount)
+	for i := 0; i < RecoveryCodesCount; i++ {
+		code, err := randomize.GetSecureToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, auth.HashToken(normalizeRecoveryCode(code)))
+	}
+	recovery := models.RecoveryCode{}
+	if err := recovery.ReplaceUserRecoveryCodes(server.DB, uid, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// OptionsMFA – Используется для подготовки соединения
+func (server *Server) OptionsMFA(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, []byte("Запрос OPTIONS обработан"))
+}
+
+// LoginMFA – Второй шаг входа: обмен токена, ожидающего второй фактор, и кода на пару токенов
+func (server *Server) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	request, err := readMFARequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	uid, err := auth.ParseMFAToken(request.MFAToken)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
+		return
+	}
+	user := models.User{}
+	_, err = user.FindUserByID(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
+		return
+	}
+
+	ip := server.ClientIP(r)
+	if server.LoginThrottled(w, r, user.Email, ip) {
+		return
+	}
+
+	result := MFALoginResult{}
+	if user.IsTOTPEnabled() {
+		err = server.checkSecondFactor(&user, request)
+		if err != nil {
+			server.LoginFailed(r, user.Email, ip)
+			responses.ERROR(w, http.StatusUnauthorized, err)
+			return
+		}
+	} else {
+		// Группа требует второй фактор, а пользователь его ещё не настроил: первый верный код включает его
+		err = server.checkTOTP(&user, request.Code)
+		if err != nil {
+			server.LoginFailed(r, user.Email, ip)
+			responses.ERROR(w, http.StatusUnauthorized, err)
+			return
+		}
+		err = user.EnableTOTP(server.DB, user.ID)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+		result.RecoveryCodes, err = server.generateRecoveryCodes(user.ID)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	server.LoginSucceeded(r, user.Email)
+	_, result.TokenPair, err = server.IssueTokens(user.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, result)
+}
+
+// LoginMFAEnroll – Настройка второго фактора при входе, если группа пользователя его требует
+func (server *Server) LoginMFAEnroll(w http.ResponseWriter, r *http.Request) {
+	request, err := readMFARequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	uid, err := auth.ParseMFAToken(request.MFAToken)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
+		return
+	}
+	user := models.User{}
+	_, err = user.FindUserByID(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
+		return
+	}
+	enrolment, err := server.enrollTOTP(&user)
+	if err != nil {
+		responses.ERROR(w, http.StatusConflict, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, enrolment)
+}
+
+// EnrollMFA – Создание секрета для приложения-аутентификатора
+func (server *Server) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.currentUser(w, r)
+	if !ok {
+		return
+	}
+	enrolment, err := server.enrollTOTP(user)
+	if err != nil {
+		responses.ERROR(w, http.StatusConflict, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, enrolment)
+}
+
+// ConfirmMFA – Включение двухфакторной аутентификации по первому коду из приложения
+func (server *Server) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.currentUser(w, r)
+	if !ok {
+		return
+	}
+	request, err := readMFARequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if user.IsTOTPEnabled() {
+		responses.ERROR(w, http.StatusConflict, apierror.New(apierror.CodeMFAAlreadyEnabled, http.StatusConflict))
+		return
+	}
+	err = server.checkTOTP(user, request.Code)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	err = user.EnableTOTP(server.DB, user.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	codes, err := server.generateRecoveryCodes(user.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, codes)
+}
+
+// RegenerateRecoveryCodes – Выдача новых кодов восстановления
+func (server *Server) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.currentUser(w, r)
+	if !ok {
+		return
+	}
+	request, err := readMFARequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if !user.IsTOTPEnabled() {
+		responses.ERROR(w, http.StatusConflict, apierror.New(apierror.CodeMFANotEnabled, http.StatusConflict))
+		return
+	}
+	err = server.checkTOTP(user, request.Code)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	codes, err := server.generateRecoveryCodes(user.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, codes)
+}
+
+// DisableMFA – Отключение двухфакторной аутентификации (если группа пользователя её не требует)
+func (server *Server) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.currentUser(w, r)
+	if !ok {
+		return
+	}
+	request, err := readMFARequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if user.RequiresTOTP(server.DB, user.ID) {
+		responses.ERROR(w, http.StatusForbidden, apierror.New(apierror.CodeMFARequired, http.StatusForbidden))
+		return
+	}
+	if !user.IsTOTPEnabled() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	err = server.checkSecondFactor(user, request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	err = user.DisableTOTP(server.DB, user.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	recovery := models.RecoveryCode{}
+	err = recovery.DeleteAllUserRecoveryCodes(server.DB, user.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
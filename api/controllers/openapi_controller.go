@@ -0,0 +1,440 @@
+// Package controllers - пакет для обработки данных запросов
+package controllers
+
+import (
+	// Страница Swagger UI встраивается в сборку
+	_ "embed"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/doka-guide/api/api/auth"
+	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/responses"
+)
+
+// OpenAPIVersion – версия описания API
+const OpenAPIVersion = "1.0.0"
+
+// Operation – описание точки входа для OpenAPI: тело запроса и ответа задаются значениями моделей
+type Operation struct {
+	Summary string
+	Tag     string
+	// Значение, в которое читается тело запроса (nil — запрос без тела)
+	Request interface{}
+	// Поле формы multipart/form-data с файлом (вместо Request)
+	Upload string
+	// Значение ответа (nil — ответ без тела)
+	Response interface{}
+	// Код успешного ответа (по умолчанию 200)
+	Status int
+	// Параметры страниц, сортировки и фильтров списка (nil — ответ не делится на страницы)
+	List *models.ListSpec
+}
+
+// oneOf – ответ одного из нескольких видов
+type oneOf []interface{}
+
+// operations – Описание всех точек входа, кроме OPTIONS. Ключ – метод и путь без префикса версии, как в таблице
+// routes; описание только для одной версии задаётся ключом с версией, например "v2 GET /form".
+// При запуске проверяется, что у каждой точки входа есть описание и у каждого описания есть точка входа
+var operations = map[string]Operation{
+	"GET /":                                     {Summary: "Название сервиса", Tag: "Home", Response: ""},
+	"GET /healthz":                              {Summary: "Проверка, что процесс работает", Tag: "Health", Response: HealthReport{}},
+	"GET /readyz":                               {Summary: "Проверка готовности принимать запросы", Tag: "Health", Response: HealthReport{}},
+	"GET /openapi.json":                         {Summary: "Описание API в формате OpenAPI 3", Tag: "Home", Response: map[string]interface{}{}},
+	"GET /docs":                                 {Summary: "Страница Swagger UI в формате HTML (при OPENAPI_UI=true)", Tag: "Home"},
+	"GET /.well-known/jwks.json":                {Summary: "Открытые ключи для проверки токенов", Tag: "Token", Response: auth.JWKSet{}},
+	"POST /login":                               {Summary: "Вход по почте и паролю", Tag: "Login", Request: models.User{}, Response: oneOf{auth.TokenPair{}, MFAChallenge{}}},
+	"POST /login/2fa":                           {Summary: "Вход со вторым фактором", Tag: "Login", Request: MFARequest{}, Response: MFALoginResult{}},
+	"POST /login/2fa/enroll":                    {Summary: "Подключение двухфакторной аутентификации при входе", Tag: "Login", Request: MFARequest{}, Response: MFAEnrolment{}},
+	"DELETE /2fa":                               {Summary: "Отключение двухфакторной аутентификации", Tag: "MFA", Request: MFARequest{}, Status: http.StatusNoContent},
+	"POST /2fa/enroll":                          {Summary: "Создание секрета для приложения-аутентификатора", Tag: "MFA", Response: MFAEnrolment{}},
+	"POST /2fa/confirm":                         {Summary: "Подтверждение двухфакторной аутентификации", Tag: "MFA", Request: MFARequest{}, Response: []string{}},
+	"POST /2fa/recovery-codes":                  {Summary: "Новые коды восстановления", Tag: "MFA", Request: MFARequest{}, Response: []string{}},
+	"POST /token/refresh":                       {Summary: "Новая пара токенов по токену обновления", Tag: "Token", Request: TokenRequest{}, Response: auth.TokenPair{}},
+	"POST /logout":                              {Summary: "Выход: отзыв токена доступа и токена обновления", Tag: "Token", Request: TokenRequest{}, Status: http.StatusNoContent},
+	"POST /password/forgot":                     {Summary: "Письмо для сброса пароля", Tag: "Password", Request: PasswordRequest{}, Response: "", Status: http.StatusAccepted},
+	"POST /password/reset":                      {Summary: "Новый пароль по токену из письма", Tag: "Password", Request: PasswordRequest{}, Response: ""},
+	"POST /user":                                {Summary: "Создание пользователя", Tag: "User", Request: models.User{}, Response: models.User{}, Status: http.StatusCreated},
+	"GET /user":                                 {Summary: "Все пользователи", Tag: "User", Response: []models.User{}, List: &models.UserListSpec},
+	"GET /user/verify/{token}":                  {Summary: "Подтверждение почты по ссылке из письма", Tag: "User", Response: VerificationResult{}},
+	"GET /user/{id}":                            {Summary: "Пользователь", Tag: "User", Response: models.User{}},
+	"PUT /user/{id}":                            {Summary: "Изменение пользователя", Tag: "User", Request: models.User{}, Response: models.User{}},
+	"DELETE /user/{id}":                         {Summary: "Удаление пользователя", Tag: "User", Status: http.StatusNoContent},
+	"POST /user/{id}/unlock":                    {Summary: "Снятие блокировки входа", Tag: "User", Status: http.StatusNoContent},
+	"GET /user/{id}/permissions":                {Summary: "Разрешения пользователя с учётом групп", Tag: "User", Response: []models.EffectivePermission{}},
+	"POST /form":                                {Summary: "Создание формы", Tag: "Form", Request: models.Form{}, Response: models.Form{}, Status: http.StatusCreated},
+	"GET /form":                                 {Summary: "Все формы", Tag: "Form", Response: []models.Form{}, List: &models.FormListSpec},
+	"GET /form/{id}":                            {Summary: "Форма", Tag: "Form", Response: models.Form{}},
+	"PUT /form/{id}":                            {Summary: "Изменение формы", Tag: "Form", Request: models.Form{}, Response: models.Form{}},
+	"DELETE /form/{id}":                         {Summary: "Удаление формы", Tag: "Form", Status: http.StatusNoContent},
+	"GET /form/feedback/{start}/{end}":          {Summary: "Отзывы за период, сгруппированные по данным", Tag: "Form", Response: []models.FormsGroupedByDataResult{}},
+	"GET /form/question/{start}/{end}":          {Summary: "Вопросы за период", Tag: "Form", Response: []models.QuestionFormsResult{}},
+	"POST /subscription":                        {Summary: "Создание подписки", Tag: "Subscription", Request: models.Subscription{}, Response: models.Subscription{}, Status: http.StatusCreated},
+	"GET /subscription":                         {Summary: "Все подписки", Tag: "Subscription", Response: []models.Subscription{}, List: &models.SubscriptionListSpec},
+	"GET /subscription/{id}":                    {Summary: "Подписка", Tag: "Subscription", Response: models.Subscription{}},
+	"PUT /subscription/{id}":                    {Summary: "Изменение подписки", Tag: "Subscription", Request: models.Subscription{}, Response: models.Subscription{}},
+	"DELETE /subscription/{id}":                 {Summary: "Удаление подписки", Tag: "Subscription", Status: http.StatusNoContent},
+	"GET /subscription/report/{start}/{end}":    {Summary: "Подписчики за период со ссылками на профиль", Tag: "Subscription", Response: []models.SubscriptionFormsWithHashResult{}},
+	"POST /profile-link":                        {Summary: "Создание ссылки на профиль", Tag: "ProfileLink", Request: models.ProfileLink{}, Response: models.ProfileLink{}, Status: http.StatusCreated},
+	"GET /profile-link":                         {Summary: "Все ссылки на профиль", Tag: "ProfileLink", Response: []models.ProfileLink{}, List: &models.ProfileLinkListSpec},
+	"GET /profile-link/{id}":                    {Summary: "Ссылка на профиль", Tag: "ProfileLink", Response: models.ProfileLink{}},
+	"DELETE /profile-link/{id}":                 {Summary: "Удаление ссылки на профиль", Tag: "ProfileLink", Status: http.StatusNoContent},
+	"POST /subscription-report":                 {Summary: "Создание отчёта о рассылке", Tag: "SubscriptionReport", Request: models.SubscriptionReport{}, Response: models.SubscriptionReport{}, Status: http.StatusCreated},
+	"GET /subscription-report":                  {Summary: "Все отчёты о рассылке", Tag: "SubscriptionReport", Response: []models.SubscriptionReport{}, List: &models.SubscriptionReportListSpec},
+	"GET /subscription-report/{id}":             {Summary: "Отчёт о рассылке", Tag: "SubscriptionReport", Response: models.SubscriptionReport{}},
+	"DELETE /subscription-report/{id}":          {Summary: "Удаление отчёта о рассылке", Tag: "SubscriptionReport", Status: http.StatusNoContent},
+	"POST /api-key":                             {Summary: "Создание ключа доступа для сервиса", Tag: "APIKey", Request: models.APIKey{}, Response: APIKeyCreated{}, Status: http.StatusCreated},
+	"GET /api-key":                              {Summary: "Все ключи доступа", Tag: "APIKey", Response: []models.APIKey{}, List: &models.APIKeyListSpec},
+	"GET /api-key/{id}":                         {Summary: "Ключ доступа", Tag: "APIKey", Response: models.APIKey{}},
+	"DELETE /api-key/{id}":                      {Summary: "Отзыв ключа доступа", Tag: "APIKey", Status: http.StatusNoContent},
+	"POST /group":                               {Summary: "Создание группы", Tag: "UserGroup", Request: models.UserGroup{}, Response: models.UserGroup{}, Status: http.StatusCreated},
+	"GET /group":                                {Summary: "Все группы", Tag: "UserGroup", Response: []models.UserGroup{}, List: &models.UserGroupListSpec},
+	"GET /group/{id}":                           {Summary: "Группа", Tag: "UserGroup", Response: models.UserGroup{}},
+	"PUT /group/{id}":                           {Summary: "Изменение группы", Tag: "UserGroup", Request: models.UserGroup{}, Response: models.UserGroup{}},
+	"DELETE /group/{id}":                        {Summary: "Удаление группы", Tag: "UserGroup", Status: http.StatusNoContent},
+	"GET /group/{id}/users":                     {Summary: "Участники группы", Tag: "UserGroup", Response: []models.GroupedUser{}, List: &models.GroupedUserListSpec},
+	"POST /group/{id}/users":                    {Summary: "Добавление пользователя в группу", Tag: "UserGroup", Request: models.GroupedUser{}, Response: models.GroupedUser{}, Status: http.StatusCreated},
+	"DELETE /group/{id}/users/{user_id}":        {Summary: "Удаление пользователя из группы", Tag: "UserGroup", Status: http.StatusNoContent},
+	"GET /group/{id}/permissions":               {Summary: "Разрешения группы", Tag: "UserGroup", Response: []models.GroupPermission{}, List: &models.GroupPermissionListSpec},
+	"POST /group/{id}/permissions":              {Summary: "Выдача разрешения группе", Tag: "UserGroup", Request: models.GroupPermission{}, Response: models.GroupPermission{}, Status: http.StatusCreated},
+	"DELETE /group/{id}/permissions/{perms_id}": {Summary: "Отзыв разрешения у группы", Tag: "UserGroup", Status: http.StatusNoContent},
+	"POST /permission":                          {Summary: "Создание разрешения", Tag: "Permission", Request: models.Permission{}, Response: models.Permission{}, Status: http.StatusCreated},
+	"GET /permission":                           {Summary: "Все разрешения", Tag: "Permission", Response: []models.Permission{}, List: &models.PermissionListSpec},
+	"GET /permission/{id}":                      {Summary: "Разрешение", Tag: "Permission", Response: models.Permission{}},
+	"PUT /permission/{id}":                      {Summary: "Изменение разрешения", Tag: "Permission", Request: models.Permission{}, Response: models.Permission{}},
+	"DELETE /permission/{id}":                   {Summary: "Удаление разрешения", Tag: "Permission", Status: http.StatusNoContent},
+	"POST /file":                                {Summary: "Загрузка файла", Tag: "File", Upload: "file-ready-to-upload", Response: ""},
+	"GET /admin/routes":                         {Summary: "Точки входа и нужные для них разрешения", Tag: "Admin", Response: []Route{}},
+}
+
+// pathParam – параметр пути вида {id} или {id:[0-9]+}
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// operationKey – Ключ описания точки входа: сначала ищется описание для версии, затем общее
+func operationKey(route Route) (string, bool) {
+	key := route.Method + " " + route.Path
+	if route.Version != "" {
+		if _, ok := operations[route.Version+" "+key]; ok {
+			return route.Version + " " + key, true
+		}
+	}
+	_, ok := operations[key]
+	return key, ok
+}
+
+// checkOperations – Проверка при запуске: у каждой точки входа есть описание для OpenAPI, а у каждого описания – точка входа
+func checkOperations(routes []Route) error {
+	seen := map[string]bool{}
+	for _, route := range routes {
+		if route.Method == http.MethodOptions {
+			continue
+		}
+		key, ok := operationKey(route)
+		if !ok {
+			return fmt.Errorf("для %s %s нет описания в operations (openapi_controller.go)", route.Method, route.FullPath())
+		}
+		seen[key] = true
+	}
+	for key := range operations {
+		if !seen[key] {
+			return fmt.Errorf("описание %s в operations не соответствует ни одной точке входа", key)
+		}
+	}
+	return nil
+}
+
+// OpenAPI – Описание API в формате OpenAPI 3, построенное по таблице точек входа и моделям
+func (server *Server) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, server.openAPI)
+}
+
+//go:embed swagger.html
+var swaggerPage []byte
+
+// SwaggerUI – Страница Swagger UI для описания API (включается параметром OPENAPI_UI=true)
+func (server *Server) SwaggerUI(w http.ResponseWriter, r *http.Request) {
+	if !server.Config.OpenAPI.UI {
+		responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(swaggerPage)
+}
+
+// buildOpenAPI – Построение описания API по точкам входа
+func (server *Server) buildOpenAPI(routes []Route) map[string]interface{} {
+	schemas := schemaSet{}
+	paths := map[string]map[string]interface{}{}
+	for _, route := range routes {
+		if route.Method == http.MethodOptions {
+			continue
+		}
+		key, _ := operationKey(route)
+		op := operations[key]
+		path := pathParam.ReplaceAllString(route.FullPath(), "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(route.Method)] = schemas.operation(route, op)
+	}
+
+	info := map[string]interface{}{"title": server.Config.App.Name, "version": OpenAPIVersion}
+	if server.Config.App.Name == "" {
+		info["title"] = "Doka API"
+	}
+	spec := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    info,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey":     map[string]interface{}{"type": "apiKey", "in": "header", "name": auth.APIKeyHeader},
+			},
+		},
+	}
+	if server.Config.App.URL != "" {
+		spec["servers"] = []map[string]interface{}{{"url": server.Config.App.URL}}
+	}
+	return spec
+}
+
+// schemaSet – схемы моделей для components/schemas
+type schemaSet map[string]interface{}
+
+// operation – Описание одной точки входа
+func (s schemaSet) operation(route Route, op Operation) map[string]interface{} {
+	result := map[string]interface{}{
+		"summary":     op.Summary,
+		"tags":        []string{op.Tag},
+		"operationId": operationID(route),
+	}
+
+	params := []map[string]interface{}{}
+	for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+		schema := map[string]interface{}{"type": "string"}
+		if match[1] == "id" || strings.HasSuffix(match[1], "_id") {
+			schema = map[string]interface{}{"type": "integer", "format": "int64"}
+		}
+		params = append(params, map[string]interface{}{"name": match[1], "in": "path", "required": true, "schema": schema})
+	}
+	if op.List != nil {
+		params = append(params, listParameters(*op.List)...)
+	}
+	if len(params) > 0 {
+		result["parameters"] = params
+	}
+
+	if op.Request != nil {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": s.schema(reflect.TypeOf(op.Request))}},
+		}
+	}
+	if op.Upload != "" {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{"multipart/form-data": map[string]interface{}{"schema": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{op.Upload: map[string]interface{}{"type": "string", "format": "binary"}},
+				"required":   []string{op.Upload},
+			}}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if op.Response != nil {
+		success["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": s.response(op.Response)}}
+	}
+	errorResponse := map[string]interface{}{
+		"description": "Ошибка",
+		"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": s.schema(reflect.TypeOf(responses.ErrorResponse{}))}},
+	}
+	if op.List != nil {
+		success["headers"] = map[string]interface{}{
+			NextCursorHeader: map[string]interface{}{"description": "Курсор следующей страницы; нет на последней странице", "schema": map[string]interface{}{"type": "string"}},
+			"Link":           map[string]interface{}{"description": "Ссылка на следующую страницу с rel=\"next\"", "schema": map[string]interface{}{"type": "string"}},
+		}
+	}
+	result["responses"] = map[string]interface{}{fmt.Sprint(status): success, "default": errorResponse}
+
+	switch route.Permission {
+	case AccessPublic:
+		result["security"] = []map[string][]string{}
+	default:
+		result["security"] = []map[string][]string{{"bearerAuth": {}}, {"apiKey": {}}}
+		if route.Permission != AccessAuthenticated {
+			result["x-permission"] = route.Permission
+		}
+	}
+	return result
+}
+
+// listParameters – Параметры запроса списка: limit, cursor, sort и фильтры
+func listParameters(spec models.ListSpec) []map[string]interface{} {
+	sorts := []string{}
+	for name := range spec.Sort {
+		sorts = append(sorts, name, "-"+name)
+	}
+	sort.Strings(sorts)
+	params := []map[string]interface{}{
+		{"name": "limit", "in": "query", "description": "Количество записей на странице (не больше GET_LIMIT)", "schema": map[string]interface{}{"type": "integer", "minimum": 1}},
+		{"name": "cursor", "in": "query", "description": "Курсор следующей страницы из заголовка " + NextCursorHeader, "schema": map[string]interface{}{"type": "string"}},
+		{"name": "sort", "in": "query", "description": "Поле для сортировки; \"-\" в начале – по убыванию", "schema": map[string]interface{}{"type": "string", "enum": sorts, "default": spec.DefaultSort}},
+	}
+	names := []string{}
+	for name := range spec.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		schema := map[string]interface{}{"type": "string"}
+		switch spec.Filters[name].Kind {
+		case models.FilterID:
+			schema = map[string]interface{}{"type": "integer", "format": "int64"}
+		case models.FilterAfter, models.FilterBefore:
+			schema = map[string]interface{}{"type": "string", "description": "Дата 2006-01-02 или время RFC 3339"}
+		}
+		params = append(params, map[string]interface{}{"name": name, "in": "query", "schema": schema})
+	}
+	return params
+}
+
+// operationID – Идентификатор операции: метод и путь без параметров, например getUserById;
+// для версий новее LegacyVersion добавляется версия, например getFormV2
+func operationID(route Route) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.Split(route.Path, "/") {
+		if part == "" {
+			continue
+		}
+		by := false
+		if match := pathParam.FindStringSubmatch(part); match != nil {
+			part = match[1]
+			by = true
+		}
+		for _, word := range strings.FieldsFunc(part, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			if by {
+				id += "By"
+				by = false
+			}
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	if route.Version != "" && route.Version != LegacyVersion {
+		id += strings.ToUpper(route.Version)
+	}
+	return id
+}
+
+// response – Схема ответа; для oneOf – один из вариантов
+func (s schemaSet) response(value interface{}) map[string]interface{} {
+	if variants, ok := value.(oneOf); ok {
+		list := []interface{}{}
+		for _, v := range variants {
+			list = append(list, s.schema(reflect.TypeOf(v)))
+		}
+		return map[string]interface{}{"oneOf": list}
+	}
+	return s.schema(reflect.TypeOf(value))
+}
+
+// timeType – тип времени, которое записывается в JSON строкой
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema – Схема типа; структуры добавляются в components/schemas и подставляются ссылкой
+func (s schemaSet) schema(t reflect.Type) map[string]interface{} {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+	var result map[string]interface{}
+	switch {
+	case t == timeType:
+		result = map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		name := t.Name()
+		if _, ok := s[name]; !ok {
+			s[name] = map[string]interface{}{}
+			s[name] = s.object(t)
+		}
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+		if nullable {
+			return map[string]interface{}{"allOf": []interface{}{ref}, "nullable": true}
+		}
+		return ref
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		result = map[string]interface{}{"type": "string", "format": "byte"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		result = map[string]interface{}{"type": "array", "items": s.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		result = map[string]interface{}{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case t.Kind() == reflect.Bool:
+		result = map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		result = map[string]interface{}{"type": "integer"}
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64 {
+			result["format"] = "int64"
+		}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		result = map[string]interface{}{"type": "number"}
+	case t.Kind() == reflect.String:
+		result = map[string]interface{}{"type": "string"}
+	default:
+		result = map[string]interface{}{}
+	}
+	if nullable {
+		result["nullable"] = true
+	}
+	return result
+}
+
+// object – Схема структуры по тегам json, как её записывает encoding/json
+func (s schemaSet) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	s.fields(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// fields – Поля структуры; поля встроенных структур без тега json поднимаются на уровень выше
+func (s schemaSet) fields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.fields(embedded, properties)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schema(field.Type)
+	}
+}
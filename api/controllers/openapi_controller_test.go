@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/doka-guide/api/api/config"
+)
+
+func testServer() *Server {
+	return &Server{Config: &config.Config{}}
+}
+
+// TestOperationsCoverRoutes – у каждой точки входа из таблицы routes есть описание для OpenAPI
+func TestOperationsCoverRoutes(t *testing.T) {
+	routes := testServer().routes()
+	if len(routes) == 0 {
+		t.Fatal("route table is empty")
+	}
+	seen := map[string]bool{}
+	for _, route := range routes {
+		if route.Method == http.MethodOptions {
+			continue
+		}
+		key, ok := operationKey(route)
+		if !ok {
+			t.Errorf("%s %s has no entry in operations (openapi_controller.go)", route.Method, route.FullPath())
+			continue
+		}
+		seen[key] = true
+	}
+	for key := range operations {
+		if !seen[key] {
+			t.Errorf("operations entry %q matches no route", key)
+		}
+	}
+	if err := checkOperations(routes); err != nil {
+		t.Errorf("checkOperations: %v", err)
+	}
+}
+
+// TestOpenAPIPaths – каждая точка входа попадает в описание по полному пути со своим методом
+func TestOpenAPIPaths(t *testing.T) {
+	server := testServer()
+	routes := server.routes()
+	paths := server.buildOpenAPI(routes)["paths"].(map[string]map[string]interface{})
+	for _, route := range routes {
+		if route.Method == http.MethodOptions {
+			continue
+		}
+		path := pathParam.ReplaceAllString(route.FullPath(), "{$1}")
+		if _, ok := paths[path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s %s is missing from openapi.json", route.Method, path)
+		}
+	}
+}
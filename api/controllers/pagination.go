@@ -0,0 +1,35 @@
+// Package controllers - пакет для обработки данных запросов
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/responses"
+)
+
+// NextCursorHeader – заголовок с курсором следующей страницы списка
+const NextCursorHeader = "X-Next-Cursor"
+
+// listQuery – Разбор параметров limit, cursor, sort и фильтров запроса списка; при ошибке отправляет ответ 422
+func listQuery(w http.ResponseWriter, r *http.Request, spec models.ListSpec) (models.ListQuery, bool) {
+	query, err := models.ParseListQuery(r.URL.Query(), spec)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return query, false
+	}
+	return query, true
+}
+
+// setNextPage – Ссылка на следующую страницу списка в заголовках X-Next-Cursor и Link; на последней странице
+// заголовки не добавляются. Остальные параметры запроса сохраняются в ссылке
+func setNextPage(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+	values := r.URL.Query()
+	values.Set("cursor", next)
+	w.Header().Set(NextCursorHeader, next)
+	w.Header().Add("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, values.Encode()))
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/doka-guide/api/api/models"
+)
+
+func TestSetNextPage(t *testing.T) {
+	tests := []struct {
+		title  string
+		target string
+		next   string
+		want   url.Values
+	}{
+		{"first page", "/v1/forms", "abc",
+			url.Values{"cursor": {"abc"}}},
+		{"other parameters kept", "/v1/forms?limit=10&sort=-created_at&author_id=7&type=feedback", "abc",
+			url.Values{"cursor": {"abc"}, "limit": {"10"}, "sort": {"-created_at"}, "author_id": {"7"}, "type": {"feedback"}}},
+		{"cursor replaced", "/v1/forms?cursor=old&limit=10", "new",
+			url.Values{"cursor": {"new"}, "limit": {"10"}}},
+		{"values escaped", "/v1/forms?created_after=2024-01-02T03:04:05%2B03:00", "a-b_c",
+			url.Values{"cursor": {"a-b_c"}, "created_after": {"2024-01-02T03:04:05+03:00"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			setNextPage(w, httptest.NewRequest(http.MethodGet, tt.target, nil), tt.next)
+
+			if got := w.Header().Get(NextCursorHeader); got != tt.next {
+				t.Errorf("%s = %q, want %q", NextCursorHeader, got, tt.next)
+			}
+			link := w.Header().Get("Link")
+			if !strings.HasPrefix(link, "</v1/forms?") || !strings.HasSuffix(link, `>; rel="next"`) {
+				t.Fatalf("Link = %q", link)
+			}
+			values, err := url.ParseQuery(strings.TrimSuffix(strings.TrimPrefix(link, "</v1/forms?"), `>; rel="next"`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if values.Encode() != tt.want.Encode() {
+				t.Errorf("Link parameters = %s, want %s", values.Encode(), tt.want.Encode())
+			}
+		})
+	}
+
+	t.Run("last page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		setNextPage(w, httptest.NewRequest(http.MethodGet, "/v1/forms?limit=10", nil), "")
+		if len(w.Header()) != 0 {
+			t.Errorf("headers = %v, want none", w.Header())
+		}
+	})
+}
+
+func TestListQueryInvalid(t *testing.T) {
+	w := httptest.NewRecorder()
+	_, ok := listQuery(w, httptest.NewRequest(http.MethodGet, "/v1/forms?limit=0&sort=password", nil), models.FormListSpec)
+	if ok {
+		t.Fatal("listQuery() accepted invalid parameters")
+	}
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	for _, field := range []string{`"limit"`, `"sort"`} {
+		if !strings.Contains(w.Body.String(), field) {
+			t.Errorf("body = %s, want field %s", w.Body.String(), field)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package controllers - пакет для обработки данных запросов
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/doka-guide/api/api/auth"
+	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/responses"
+	"github.com/doka-guide/api/api/utils/logger"
+	"github.com/doka-guide/api/api/utils/mail"
+	"github.com/jinzhu/gorm"
+)
+
+// PasswordResetLifetime – Время жизни токена для сброса пароля
+const PasswordResetLifetime = time.Hour * 1
+
+// PasswordRequest – Тело запросов на сброс пароля
+type PasswordRequest struct {
+	Email    string `json:"email"`
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// OptionsPassword – Используется для подготовки соединения
+func (server *Server) OptionsPassword(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, []byte("Запрос OPTIONS обработан"))
+}
+
+// ForgotPassword – Отправка письма со ссылкой для сброса пароля
+func (server *Server) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := PasswordRequest{}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	user := models.User{Email: request.Email}
+	user.Prepare()
+	if user.Email == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apierror.Required("email"))
+		return
+	}
+
+	// Ответ не зависит от того, существует ли пользователь, чтобы нельзя было перебирать адреса
+	accepted := "Если пользователь с такой почтой существует, на неё отправлено письмо для сброса пароля"
+	err = server.DB.Model(models.User{}).Where("email = ?", user.Email).Take(&user).Error
+	if err != nil {
+		responses.JSON(w, http.StatusAccepted, accepted)
+		return
+	}
+
+	token, hash, err := auth.CreateRefreshToken()
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	reset := models.PasswordReset{
+		Hash:      hash,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(PasswordResetLifetime),
+	}
+	reset.Prepare()
+	err = reset.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	_, err = reset.SavePasswordReset(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	vars := map[string]string{"token": token, "lifetime": "один час"}
+	resetTxt, err := mail.RenderTemplate(server.Config.Mail.BodyResetText, vars)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	resetHTML, err := mail.RenderTemplate(server.Config.Mail.BodyResetHTML, vars)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	err = server.Mailer.Send(user.Nickname, user.Email, server.Config.Mail.TitleReset, resetTxt, resetHTML, false)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("Не удалось отправить письмо для сброса пароля", "user_id", user.ID, "error", err)
+	}
+
+	responses.JSON(w, http.StatusAccepted, accepted)
+}
+
+// ResetPassword – Установка нового пароля по токену из письма
+func (server *Server) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := PasswordRequest{}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if request.Token == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apierror.Required("token"))
+		return
+	}
+	if request.Password == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apierror.Required("password"))
+		return
+	}
+
+	reset := models.PasswordReset{}
+	_, err = reset.FindPasswordResetByHash(server.DB, auth.HashToken(request.Token))
+	if err != nil || !reset.IsActive() {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apierror.ErrTokenInvalid)
+		return
+	}
+
+	user := models.User{}
+	_, err = user.FindUserByID(server.DB, reset.UserID)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
+		return
+	}
+	user.Password = request.Password
+	err = user.Validate("update")
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	// Пароль, отметка об использовании токена и завершение всех сессий пользователя сохраняются вместе:
+	// если что-то не удалось, токен остаётся действительным
+	err = server.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := user.UpdateAUser(tx, reset.UserID)
+		if err != nil {
+			return err
+		}
+		err = reset.UseAPasswordReset(tx)
+		if err != nil {
+			return err
+		}
+		refresh := models.RefreshToken{}
+		_, err = refresh.RevokeAllUserRefreshTokens(tx, reset.UserID)
+		return err
+	})
+	if err == apierror.ErrTokenInvalid {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, "Пароль изменён")
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectActiveReset – в базе есть действующий токен сброса пароля пользователя 7
+func expectActiveReset(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "password_resets" WHERE \(hash = \$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "hash", "user_id", "expires_at", "used_at"}).
+			AddRow(1, "hash", 7, time.Now().Add(time.Hour), nil))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(id = \$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "email", "password"}).
+			AddRow(7, "user", "user@example.com", "old-hash"))
+}
+
+func resetRequest() *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/v1/password/reset", strings.NewReader(`{"token":"token","password":"new-password"}`))
+}
+
+func TestResetPassword(t *testing.T) {
+	server, mock := mockServer(t)
+	expectActiveReset(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "user@example.com"))
+	mock.ExpectExec(`UPDATE "users" SET "email" = \$1, "nickname" = \$2, "password" = \$3, "updated_at" = \$4 WHERE`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`UPDATE "password_resets" SET "updated_at" = \$1, "used_at" = \$2 WHERE \(id = \$3 AND used_at IS NULL\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	server.ResetPassword(w, resetRequest())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+}
+
+// TestResetPasswordFailureKeepsToken – если пароль не сохранился, отметка об использовании токена откатывается
+func TestResetPasswordFailureKeepsToken(t *testing.T) {
+	server, mock := mockServer(t)
+	expectActiveReset(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "user@example.com"))
+	mock.ExpectExec(`UPDATE "users" SET`).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	server.ResetPassword(w, resetRequest())
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusInternalServerError, w.Body.String())
+	}
+}
+
+// TestResetPasswordInvalidKeepsToken – пароль, не прошедший проверку, не расходует токен
+func TestResetPasswordInvalidKeepsToken(t *testing.T) {
+	server, mock := mockServer(t)
+	mock.ExpectQuery(`SELECT \* FROM "password_resets" WHERE \(hash = \$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "hash", "user_id", "expires_at", "used_at"}).
+			AddRow(1, "hash", 7, time.Now().Add(time.Hour), nil))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(id = \$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "email", "password"}).
+			AddRow(7, "user", "not-an-email", "old-hash"))
+
+	w := httptest.NewRecorder()
+	server.ResetPassword(w, resetRequest())
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusUnprocessableEntity, w.Body.String())
+	}
+}
@@ -0,0 +1,155 @@
+// Package controllers - пакет для обработки данных запросов
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/responses"
+	"github.com/gorilla/mux"
+)
+
+// OptionsPermissions – Для предварительной загрузки (prefetch)
+func (server *Server) OptionsPermissions(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, []byte("Запрос OPTIONS обработан"))
+}
+
+// permissionNameTaken – Проверка, занято ли название разрешения другой записью
+func (server *Server) permissionNameTaken(name string, exceptID uint64) bool {
+	var count int
+	server.DB.Model(&models.Permission{}).Where("name = ? AND id <> ?", name, exceptID).Count(&count)
+	return count > 0
+}
+
+// CreatePermission – Создание разрешения
+func (server *Server) CreatePermission(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	permission := models.Permission{}
+	err = json.Unmarshal(body, &permission)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	permission.Prepare()
+	err = permission.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if server.permissionNameTaken(permission.Name, 0) {
+		responses.ERROR(w, http.StatusConflict, apierror.Validation(apierror.Field("name", apierror.FieldTaken)))
+		return
+	}
+
+	permissionCreated, err := permission.SavePermission(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.URL.Path, permissionCreated.ID))
+	responses.JSON(w, http.StatusCreated, permissionCreated)
+}
+
+// GetPermissions – Вывод всех разрешений
+func (server *Server) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	permission := models.Permission{}
+	query, ok := listQuery(w, r, models.PermissionListSpec)
+	if !ok {
+		return
+	}
+	permissions, next, err := permission.FindAllPermissions(server.DB, query)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	setNextPage(w, r, next)
+	responses.JSON(w, http.StatusOK, permissions)
+}
+
+// GetPermission – Вывод разрешения по ID
+func (server *Server) GetPermission(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	permission := models.Permission{}
+	permissionReceived, err := permission.FindPermissionByID(server.DB, pid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, permissionReceived)
+}
+
+// UpdatePermission – Переименование разрешения
+func (server *Server) UpdatePermission(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	permission := models.Permission{}
+	err = json.Unmarshal(body, &permission)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	permission.Prepare()
+	err = permission.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if server.permissionNameTaken(permission.Name, pid) {
+		responses.ERROR(w, http.StatusConflict, apierror.Validation(apierror.Field("name", apierror.FieldTaken)))
+		return
+	}
+
+	permissionUpdated, err := permission.UpdateAPermission(server.DB, pid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, permissionUpdated)
+}
+
+// DeletePermission – Удаление разрешения
+func (server *Server) DeletePermission(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	var count int
+	server.DB.Model(&models.GroupPermission{}).Where("perms_id = ?", pid).Count(&count)
+	if count > 0 {
+		responses.ERROR(w, http.StatusConflict, apierror.New(apierror.CodePermissionInUse, http.StatusConflict))
+		return
+	}
+	permission := models.Permission{}
+	_, err = permission.DeleteAPermission(server.DB, pid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Entity", fmt.Sprintf("%d", pid))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+func TestUpdatePermission(t *testing.T) {
+	server, mock := mockServer(t)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "permissions" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT \* FROM "permissions" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "permissions" SET "name" = \$1, "updated_at" = \$2 WHERE`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`INSERT INTO permission_versions`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "permissions" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(5, "FORM-EXPORT"))
+
+	r := httptest.NewRequest(http.MethodPut, "/v1/permission/5", strings.NewReader(`{"name":"FORM-EXPORT"}`))
+	r = mux.SetURLVars(r, map[string]string{"id": "5"})
+	w := httptest.NewRecorder()
+	server.UpdatePermission(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"name":"FORM-EXPORT"`) {
+		t.Errorf("body = %s, want updated permission", w.Body.String())
+	}
+}
@@ -3,25 +3,19 @@ package controllers
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/doka-guide/api/api/models"
 	"github.com/doka-guide/api/api/responses"
-	"github.com/doka-guide/api/api/utils/formaterror"
 	"github.com/gorilla/mux"
 )
 
 // CreateProfileLink – Создание ссылки
 func (server *Server) CreateProfileLink(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "PROFILE-LINK-POST") {
-		return
-	}
-
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
@@ -42,8 +36,7 @@ func (server *Server) CreateProfileLink(w http.ResponseWriter, r *http.Request)
 
 	linkCreated, err := link.SaveProfileLink(server.DB)
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -58,27 +51,24 @@ func (server *Server) OptionsProfileLinks(w http.ResponseWriter, r *http.Request
 
 // GetProfileLinks – Вывод всех ссылок
 func (server *Server) GetProfileLinks(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "PROFILE-LINK-GET") {
+	link := models.ProfileLink{}
+	query, ok := listQuery(w, r, models.ProfileLinkListSpec)
+	if !ok {
 		return
 	}
-
-	link := models.ProfileLink{}
-	links, err := link.FindAllProfileLinks(server.DB)
+	// Чужие ссылки выводятся только с правом PROFILE-LINK-GET-ANY
+	ScopeRecords(r, &query, "PROFILE-LINK-GET")
+	links, next, err := link.FindAllProfileLinks(server.DB, query)
 	if err != nil {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	setNextPage(w, r, next)
 	responses.JSON(w, http.StatusOK, links)
 }
 
 // GetProfileLink – Вывод ссылки по Hash
 func (server *Server) GetProfileLink(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "PROFILE-LINK-GET") {
-		return
-	}
-
 	vars := mux.Vars(r)
 	hash := vars["id"]
 	link := models.ProfileLink{}
@@ -88,17 +78,15 @@ func (server *Server) GetProfileLink(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	// Чужую ссылку можно получить только с правом PROFILE-LINK-GET-ANY
+	if !AuthorizeRecord(w, r, "PROFILE-LINK-GET", linkReceived.AuthorID) {
+		return
+	}
 	responses.JSON(w, http.StatusOK, linkReceived)
 }
 
 // DeleteProfileLink – Удаляет данные о ссылке из базы данных
 func (server *Server) DeleteProfileLink(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	uid := GetUserIDByToken(w, r)
-	if !CheckPermission(server.DB, uid, "PROFILE-LINK-DELETE") {
-		return
-	}
-
 	vars := mux.Vars(r)
 
 	// Валидация подписки
@@ -110,22 +98,21 @@ func (server *Server) DeleteProfileLink(w http.ResponseWriter, r *http.Request)
 
 	// Проверка наличия подписки
 	link := models.ProfileLink{}
-	err = server.DB.Debug().Model(models.ProfileLink{}).Where("id = ?", pid).Take(&link).Error
+	err = server.DB.Model(models.ProfileLink{}).Where("id = ?", pid).Take(&link).Error
 	if err != nil {
-		responses.ERROR(w, http.StatusNotFound, errors.New("Unauthorized"))
+		responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
 		return
 	}
 
-	// Проверка принадлежности подписки пользователю
-	if uid != link.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+	// Чужую ссылку можно удалить только с правом PROFILE-LINK-DELETE-ANY
+	if !AuthorizeRecord(w, r, "PROFILE-LINK-DELETE", link.AuthorID) {
 		return
 	}
-	_, err = link.DeleteAProfileLink(server.DB, pid, uid)
+	_, err = link.DeleteAProfileLink(server.DB, pid, link.AuthorID)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 	w.Header().Set("Entity", fmt.Sprintf("%d", pid))
-	responses.JSON(w, http.StatusNoContent, "")
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -1,58 +1,319 @@
 // Package controllers - пакет для обработки данных запросов
 package controllers
 
-import "github.com/doka-guide/api/api/middlewares"
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/doka-guide/api/api/middlewares"
+	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/responses"
+	"github.com/doka-guide/api/api/utils/logger"
+	"github.com/doka-guide/api/api/utils/rbac"
+)
+
+// Уровни доступа к точкам входа, которые не являются названиями разрешений
+const (
+	// AccessPublic – точка входа доступна без авторизации
+	AccessPublic = "PUBLIC"
+	// AccessAuthenticated – достаточно токена доступа, отдельное разрешение не нужно
+	AccessAuthenticated = "AUTHENTICATED"
+)
+
+// Версии API
+const (
+	// CurrentVersion – актуальная версия, на которую ведут ссылки в письмах
+	CurrentVersion = "v1"
+	// LegacyVersion – версия, точки входа которой также доступны без префикса, как до появления версий
+	LegacyVersion = "v1"
+)
+
+// Route – Точка входа API и разрешение, необходимое для запроса к ней
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Версия API (префикс пути); пустая у служебных точек входа вне версий
+	Version    string           `json:"version,omitempty"`
+	Permission string           `json:"permission"`
+	Handler    http.HandlerFunc `json:"-"`
+}
+
+// FullPath – Путь точки входа с префиксом версии, например /v1/form/{id}
+func (route Route) FullPath() string {
+	if route.Version == "" {
+		return route.Path
+	}
+	return "/" + route.Version + route.Path
+}
+
+// APIVersion – Версия API: префикс пути и таблица точек входа. Модели общие для всех версий,
+// поэтому новая версия (v2) объявляет свою таблицу, в которой меняет только нужные обработчики
+type APIVersion struct {
+	Name   string
+	Routes []Route
+}
+
+// apiVersions – Все версии API
+func (server *Server) apiVersions() []APIVersion {
+	return []APIVersion{
+		{Name: "v1", Routes: server.routesV1()},
+	}
+}
+
+// routes – Все точки входа API: служебные и точки входа всех версий. У каждой точки входа должно быть
+// указано разрешение, AccessAuthenticated или явно AccessPublic, иначе сервер не запустится
+func (server *Server) routes() []Route {
+	routes := server.systemRoutes()
+	for _, version := range server.apiVersions() {
+		for _, route := range version.Routes {
+			route.Version = version.Name
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// systemRoutes – Служебные точки входа вне версий API: их адреса используют балансировщики и другие сервисы
+func (server *Server) systemRoutes() []Route {
+	return []Route{
+		// Точки входа для сущности Home
+		{Method: "OPTIONS", Path: "/", Permission: AccessPublic, Handler: server.OptionsHome},
+		{Method: "GET", Path: "/", Permission: AccessPublic, Handler: server.Home},
+
+		// Проверка работы сервиса и готовности принимать запросы
+		{Method: "GET", Path: "/healthz", Permission: AccessPublic, Handler: server.Healthz},
+		{Method: "GET", Path: "/readyz", Permission: AccessPublic, Handler: server.Readyz},
+
+		// Описание API
+		{Method: "GET", Path: "/openapi.json", Permission: AccessPublic, Handler: server.OpenAPI},
+		{Method: "GET", Path: "/docs", Permission: AccessPublic, Handler: server.SwaggerUI},
+
+		// Открытые ключи для проверки токенов другими сервисами
+		{Method: "OPTIONS", Path: "/.well-known/jwks.json", Permission: AccessPublic, Handler: server.OptionsHome},
+		{Method: "GET", Path: "/.well-known/jwks.json", Permission: AccessPublic, Handler: server.JWKS},
+	}
+}
+
+// routesV1 – Точки входа версии v1 (пути без префикса /v1)
+func (server *Server) routesV1() []Route {
+	return []Route{
+		// Точки входа для сущности Login
+		{Method: "OPTIONS", Path: "/login", Permission: AccessPublic, Handler: server.OptionsLogin},
+		{Method: "POST", Path: "/login", Permission: AccessPublic, Handler: server.Login},
+
+		// Точки входа для двухфакторной аутентификации
+		{Method: "OPTIONS", Path: "/login/2fa", Permission: AccessPublic, Handler: server.OptionsMFA},
+		{Method: "POST", Path: "/login/2fa", Permission: AccessPublic, Handler: server.LoginMFA},
+		{Method: "OPTIONS", Path: "/login/2fa/enroll", Permission: AccessPublic, Handler: server.OptionsMFA},
+		{Method: "POST", Path: "/login/2fa/enroll", Permission: AccessPublic, Handler: server.LoginMFAEnroll},
+		{Method: "OPTIONS", Path: "/2fa", Permission: AccessPublic, Handler: server.OptionsMFA},
+		{Method: "DELETE", Path: "/2fa", Permission: AccessAuthenticated, Handler: server.DisableMFA},
+		{Method: "OPTIONS", Path: "/2fa/enroll", Permission: AccessPublic, Handler: server.OptionsMFA},
+		{Method: "POST", Path: "/2fa/enroll", Permission: AccessAuthenticated, Handler: server.EnrollMFA},
+		{Method: "OPTIONS", Path: "/2fa/confirm", Permission: AccessPublic, Handler: server.OptionsMFA},
+		{Method: "POST", Path: "/2fa/confirm", Permission: AccessAuthenticated, Handler: server.ConfirmMFA},
+		{Method: "OPTIONS", Path: "/2fa/recovery-codes", Permission: AccessPublic, Handler: server.OptionsMFA},
+		{Method: "POST", Path: "/2fa/recovery-codes", Permission: AccessAuthenticated, Handler: server.RegenerateRecoveryCodes},
+
+		// Точки входа для сущности Token
+		{Method: "OPTIONS", Path: "/token/refresh", Permission: AccessPublic, Handler: server.OptionsToken},
+		{Method: "POST", Path: "/token/refresh", Permission: AccessPublic, Handler: server.RefreshToken},
+		{Method: "OPTIONS", Path: "/logout", Permission: AccessPublic, Handler: server.OptionsToken},
+		{Method: "POST", Path: "/logout", Permission: AccessAuthenticated, Handler: server.Logout},
+
+		// Точки входа для сброса пароля
+		{Method: "OPTIONS", Path: "/password/forgot", Permission: AccessPublic, Handler: server.OptionsPassword},
+		{Method: "POST", Path: "/password/forgot", Permission: AccessPublic, Handler: server.ForgotPassword},
+		{Method: "OPTIONS", Path: "/password/reset", Permission: AccessPublic, Handler: server.OptionsPassword},
+		{Method: "POST", Path: "/password/reset", Permission: AccessPublic, Handler: server.ResetPassword},
+
+		// Точки входа для сущности User
+		{Method: "OPTIONS", Path: "/user", Permission: "USER-OPTIONS", Handler: server.OptionsUsers},
+		{Method: "POST", Path: "/user", Permission: "USER-POST", Handler: server.CreateUser},
+		{Method: "GET", Path: "/user", Permission: "USER-GET", Handler: server.GetUsers},
+		{Method: "GET", Path: "/user/verify/{token}", Permission: AccessPublic, Handler: server.VerifyUser},
+		{Method: "GET", Path: "/user/{id}", Permission: "USER-GET", Handler: server.GetUser},
+		{Method: "PUT", Path: "/user/{id}", Permission: "USER-PUT", Handler: server.UpdateUser},
+		{Method: "DELETE", Path: "/user/{id}", Permission: "USER-DELETE", Handler: server.DeleteUser},
+		{Method: "POST", Path: "/user/{id}/unlock", Permission: "USER-UNLOCK", Handler: server.UnlockUser},
+		{Method: "GET", Path: "/user/{id}/permissions", Permission: "PERMISSION-GET", Handler: server.GetUserPermissions},
+
+		// Точки входа для сущности Form
+		{Method: "OPTIONS", Path: "/form", Permission: AccessPublic, Handler: server.OptionsForms},
+		{Method: "POST", Path: "/form", Permission: "FORM-POST", Handler: server.CreateForm},
+		{Method: "GET", Path: "/form", Permission: "FORM-GET", Handler: server.GetForms},
+		{Method: "GET", Path: "/form/{id}", Permission: "FORM-GET", Handler: server.GetForm},
+		{Method: "PUT", Path: "/form/{id}", Permission: "FORM-PUT", Handler: server.UpdateForm},
+		{Method: "DELETE", Path: "/form/{id}", Permission: "FORM-DELETE", Handler: server.DeleteForm},
+		{Method: "GET", Path: "/form/feedback/{start}/{end}", Permission: "FORM-GET", Handler: server.GetFeedbackForms},
+		{Method: "GET", Path: "/form/question/{start}/{end}", Permission: "FORM-GET", Handler: server.GetQuestionForms},
+
+		// Точки входа для сущности Subscription
+		{Method: "OPTIONS", Path: "/subscription", Permission: AccessPublic, Handler: server.OptionsSubscriptions},
+		{Method: "POST", Path: "/subscription", Permission: "SUBSCRIPTION-POST", Handler: server.CreateSubscription},
+		{Method: "GET", Path: "/subscription", Permission: "SUBSCRIPTION-GET", Handler: server.GetSubscriptions},
+		{Method: "GET", Path: "/subscription/{id}", Permission: "SUBSCRIPTION-GET", Handler: server.GetSubscription},
+		{Method: "PUT", Path: "/subscription/{id}", Permission: "SUBSCRIPTION-PUT", Handler: server.UpdateSubscription},
+		{Method: "DELETE", Path: "/subscription/{id}", Permission: "SUBSCRIPTION-DELETE", Handler: server.DeleteSubscription},
+		{Method: "GET", Path: "/subscription/report/{start}/{end}", Permission: "SUBSCRIPTION-GET", Handler: server.GetSubscriptionFormsWithHash},
+
+		// Точки входа для сущности ProfileLink
+		{Method: "OPTIONS", Path: "/profile-link", Permission: AccessPublic, Handler: server.OptionsProfileLinks},
+		{Method: "POST", Path: "/profile-link", Permission: "PROFILE-LINK-POST", Handler: server.CreateProfileLink},
+		{Method: "GET", Path: "/profile-link", Permission: "PROFILE-LINK-GET", Handler: server.GetProfileLinks},
+		{Method: "GET", Path: "/profile-link/{id}", Permission: "PROFILE-LINK-GET", Handler: server.GetProfileLink},
+		{Method: "DELETE", Path: "/profile-link/{id}", Permission: "PROFILE-LINK-DELETE", Handler: server.DeleteProfileLink},
+
+		// Точки входа для сущности SubscriptionReport
+		{Method: "OPTIONS", Path: "/subscription-report", Permission: AccessPublic, Handler: server.OptionsSubscriptionReports},
+		{Method: "POST", Path: "/subscription-report", Permission: "SUBSCRIPTION-REPORT-POST", Handler: server.CreateSubscriptionReport},
+		{Method: "GET", Path: "/subscription-report", Permission: "SUBSCRIPTION-REPORT-GET", Handler: server.GetSubscriptionReports},
+		{Method: "GET", Path: "/subscription-report/{id}", Permission: "SUBSCRIPTION-REPORT-GET", Handler: server.GetSubscriptionReport},
+		{Method: "DELETE", Path: "/subscription-report/{id}", Permission: "SUBSCRIPTION-REPORT-DELETE", Handler: server.DeleteSubscriptionReport},
+
+		// Точки входа для сущности APIKey
+		{Method: "OPTIONS", Path: "/api-key", Permission: AccessPublic, Handler: server.OptionsAPIKeys},
+		{Method: "POST", Path: "/api-key", Permission: "API-KEY-POST", Handler: server.CreateAPIKey},
+		{Method: "GET", Path: "/api-key", Permission: "API-KEY-GET", Handler: server.GetAPIKeys},
+		{Method: "GET", Path: "/api-key/{id}", Permission: "API-KEY-GET", Handler: server.GetAPIKey},
+		{Method: "DELETE", Path: "/api-key/{id}", Permission: "API-KEY-DELETE", Handler: server.RevokeAPIKey},
+
+		// Точки входа для сущности UserGroup
+		{Method: "OPTIONS", Path: "/group", Permission: AccessPublic, Handler: server.OptionsGroups},
+		{Method: "POST", Path: "/group", Permission: "GROUP-POST", Handler: server.CreateGroup},
+		{Method: "GET", Path: "/group", Permission: "GROUP-GET", Handler: server.GetGroups},
+		{Method: "OPTIONS", Path: "/group/{id}", Permission: AccessPublic, Handler: server.OptionsGroups},
+		{Method: "GET", Path: "/group/{id}", Permission: "GROUP-GET", Handler: server.GetGroup},
+		{Method: "PUT", Path: "/group/{id}", Permission: "GROUP-PUT", Handler: server.UpdateGroup},
+		{Method: "DELETE", Path: "/group/{id}", Permission: "GROUP-DELETE", Handler: server.DeleteGroup},
+		{Method: "OPTIONS", Path: "/group/{id}/users", Permission: AccessPublic, Handler: server.OptionsGroups},
+		{Method: "GET", Path: "/group/{id}/users", Permission: "GROUP-GET", Handler: server.GetGroupUsers},
+		{Method: "POST", Path: "/group/{id}/users", Permission: "GROUP-PUT", Handler: server.AddGroupUser},
+		{Method: "OPTIONS", Path: "/group/{id}/users/{user_id}", Permission: AccessPublic, Handler: server.OptionsGroups},
+		{Method: "DELETE", Path: "/group/{id}/users/{user_id}", Permission: "GROUP-PUT", Handler: server.RemoveGroupUser},
+		{Method: "OPTIONS", Path: "/group/{id}/permissions", Permission: AccessPublic, Handler: server.OptionsGroups},
+		{Method: "GET", Path: "/group/{id}/permissions", Permission: "GROUP-GET", Handler: server.GetGroupPermissions},
+		{Method: "POST", Path: "/group/{id}/permissions", Permission: "GROUP-PUT", Handler: server.AddGroupPermission},
+		{Method: "OPTIONS", Path: "/group/{id}/permissions/{perms_id}", Permission: AccessPublic, Handler: server.OptionsGroups},
+		{Method: "DELETE", Path: "/group/{id}/permissions/{perms_id}", Permission: "GROUP-PUT", Handler: server.RemoveGroupPermission},
+
+		// Точки входа для сущности Permission
+		{Method: "OPTIONS", Path: "/permission", Permission: AccessPublic, Handler: server.OptionsPermissions},
+		{Method: "POST", Path: "/permission", Permission: "PERMISSION-POST", Handler: server.CreatePermission},
+		{Method: "GET", Path: "/permission", Permission: "PERMISSION-GET", Handler: server.GetPermissions},
+		{Method: "OPTIONS", Path: "/permission/{id}", Permission: AccessPublic, Handler: server.OptionsPermissions},
+		{Method: "GET", Path: "/permission/{id}", Permission: "PERMISSION-GET", Handler: server.GetPermission},
+		{Method: "PUT", Path: "/permission/{id}", Permission: "PERMISSION-PUT", Handler: server.UpdatePermission},
+		{Method: "DELETE", Path: "/permission/{id}", Permission: "PERMISSION-DELETE", Handler: server.DeletePermission},
+
+		// Точки входа для сущности File
+		{Method: "POST", Path: "/file", Permission: AccessPublic, Handler: server.UploadFile},
+
+		// Точки входа для администрирования
+		{Method: "GET", Path: "/admin/routes", Permission: "PERMISSION-GET", Handler: server.GetRoutes},
+	}
+}
 
 func (server *Server) initializeRoutes() {
-	// Точки входа для сущности Home
-	server.Router.HandleFunc("/", middlewares.SetMiddlewareJSON(server.OptionsHome)).Methods("OPTIONS")
-	server.Router.HandleFunc("/", middlewares.SetMiddlewareJSON(server.Home)).Methods("GET")
-
-	// Точки входа для сущности Login
-	server.Router.HandleFunc("/login", middlewares.SetMiddlewareJSON(server.OptionsLogin)).Methods("OPTIONS")
-	server.Router.HandleFunc("/login", middlewares.SetMiddlewareJSON(server.Login)).Methods("POST")
-
-	// Точки входа для сущности User
-	server.Router.HandleFunc("/user", middlewares.SetMiddlewareJSON(server.OptionsUsers)).Methods("OPTIONS")
-	server.Router.HandleFunc("/user", middlewares.SetMiddlewareJSON(server.CreateUser)).Methods("POST")
-	server.Router.HandleFunc("/user", middlewares.SetMiddlewareJSON(server.GetUsers)).Methods("GET")
-	server.Router.HandleFunc("/user/{id}", middlewares.SetMiddlewareJSON(server.GetUser)).Methods("GET")
-	server.Router.HandleFunc("/user/{id}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(server.UpdateUser))).Methods("PUT")
-	server.Router.HandleFunc("/user/{id}", middlewares.SetMiddlewareAuthentication(server.DeleteUser)).Methods("DELETE")
-
-	// Точки входа для сущности Form
-	server.Router.HandleFunc("/form", middlewares.SetMiddlewareJSON(server.OptionsForms)).Methods("OPTIONS")
-	server.Router.HandleFunc("/form", middlewares.SetMiddlewareJSON(server.CreateForm)).Methods("POST")
-	server.Router.HandleFunc("/form", middlewares.SetMiddlewareJSON(server.GetForms)).Methods("GET")
-	server.Router.HandleFunc("/form/{id}", middlewares.SetMiddlewareJSON(server.GetForm)).Methods("GET")
-	server.Router.HandleFunc("/form/{id}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(server.UpdateForm))).Methods("PUT")
-	server.Router.HandleFunc("/form/{id}", middlewares.SetMiddlewareAuthentication(server.DeleteForm)).Methods("DELETE")
-	server.Router.HandleFunc("/form/feedback/{start}/{end}", middlewares.SetMiddlewareJSON(server.GetFeedbackForms)).Methods("GET")
-	server.Router.HandleFunc("/form/question/{start}/{end}", middlewares.SetMiddlewareJSON(server.GetQuestionForms)).Methods("GET")
-
-	// Точки входа для сущности Subscription
-	server.Router.HandleFunc("/subscription", middlewares.SetMiddlewareJSON(server.OptionsSubscriptions)).Methods("OPTIONS")
-	server.Router.HandleFunc("/subscription", middlewares.SetMiddlewareJSON(server.CreateSubscription)).Methods("POST")
-	server.Router.HandleFunc("/subscription", middlewares.SetMiddlewareJSON(server.GetSubscriptions)).Methods("GET")
-	server.Router.HandleFunc("/subscription/{id}", middlewares.SetMiddlewareJSON(server.GetSubscription)).Methods("GET")
-	server.Router.HandleFunc("/subscription/{id}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(server.UpdateSubscription))).Methods("PUT")
-	server.Router.HandleFunc("/subscription/{id}", middlewares.SetMiddlewareAuthentication(server.DeleteSubscription)).Methods("DELETE")
-	server.Router.HandleFunc("/subscription/report/{start}/{end}", middlewares.SetMiddlewareJSON(server.GetSubscriptionFormsWithHash)).Methods("GET")
-
-	// Точки входа для сущности ProfileLink
-	server.Router.HandleFunc("/profile-link", middlewares.SetMiddlewareJSON(server.OptionsProfileLinks)).Methods("OPTIONS")
-	server.Router.HandleFunc("/profile-link", middlewares.SetMiddlewareJSON(server.CreateProfileLink)).Methods("POST")
-	server.Router.HandleFunc("/profile-link", middlewares.SetMiddlewareJSON(server.GetProfileLinks)).Methods("GET")
-	server.Router.HandleFunc("/profile-link/{id}", middlewares.SetMiddlewareJSON(server.GetProfileLink)).Methods("GET")
-	server.Router.HandleFunc("/profile-link/{id}", middlewares.SetMiddlewareAuthentication(server.DeleteProfileLink)).Methods("DELETE")
-
-	// Точки входа для сущности SubscriptionReport
-	server.Router.HandleFunc("/subscription-report", middlewares.SetMiddlewareJSON(server.OptionsProfileLinks)).Methods("OPTIONS")
-	server.Router.HandleFunc("/subscription-report", middlewares.SetMiddlewareJSON(server.CreateProfileLink)).Methods("POST")
-	server.Router.HandleFunc("/subscription-report", middlewares.SetMiddlewareJSON(server.GetProfileLinks)).Methods("GET")
-	server.Router.HandleFunc("/subscription-report/{id}", middlewares.SetMiddlewareJSON(server.GetProfileLink)).Methods("GET")
-	server.Router.HandleFunc("/subscription-report/{id}", middlewares.SetMiddlewareAuthentication(server.DeleteProfileLink)).Methods("DELETE")
-
-	// Точки входа для сущности File
-	server.Router.HandleFunc("/file", middlewares.SetMiddlewareJSON(server.UploadFile)).Methods("POST")
+	server.Routes = server.routes()
+	err := checkRoutes(server.Routes)
+	if err != nil {
+		logger.Fatal("Ошибка в описании точек входа", "error", err)
+	}
+	err = checkOperations(server.Routes)
+	if err != nil {
+		logger.Fatal("Ошибка в описании API", "error", err)
+	}
+	server.openAPI = server.buildOpenAPI(server.Routes)
+	server.warnUnknownPermissions()
+	server.Router.Use(middlewares.SetMiddlewareRoute)
+	server.Router.NotFoundHandler = http.HandlerFunc(middlewares.NotFound)
+	server.Router.MethodNotAllowedHandler = http.HandlerFunc(middlewares.MethodNotAllowed)
+	// Метрики на основном адресе публикуются только под токеном
+	if server.Config.Metrics.Token != "" && server.Config.Metrics.Addr == "" {
+		server.Router.HandleFunc("/metrics", server.Metrics).Methods("GET")
+	}
+	routers := map[string]*mux.Router{"": server.Router}
+	for _, route := range server.Routes {
+		router, ok := routers[route.Version]
+		if !ok {
+			router = server.Router.PathPrefix("/" + route.Version).Subrouter()
+			routers[route.Version] = router
+		}
+		router.HandleFunc(route.Path, route.handler()).Methods(route.Method)
+	}
+	// Пути без префикса остаются на время перехода на версии и отвечают с заголовками об устаревании
+	if server.Config.API.RootAliases {
+		api := server.Config.API
+		for _, route := range server.Routes {
+			if route.Version == LegacyVersion {
+				handler := middlewares.SetMiddlewareDeprecated("/"+route.Version, api.DeprecatedAt, api.SunsetAt, route.handler())
+				server.Router.HandleFunc(route.Path, handler).Methods(route.Method)
+			}
+		}
+	}
+}
+
+// handler – Обработчик точки входа с посредниками, которые проверяют права на запрос
+func (route Route) handler() http.HandlerFunc {
+	switch route.Permission {
+	case AccessPublic:
+		return middlewares.SetMiddlewareJSON(route.Handler)
+	case AccessAuthenticated:
+		return middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(route.Handler))
+	}
+	return middlewares.SetMiddlewareJSON(middlewares.SetMiddlewarePermission(route.Permission, route.Handler))
+}
+
+// checkRoutes – Проверка при запуске: у каждой точки входа объявлено разрешение и обработчик, точки входа
+// не повторяются, а пути версии LegacyVersion без префикса не совпадают со служебными
+func checkRoutes(routes []Route) error {
+	seen := map[string]bool{}
+	for _, route := range routes {
+		if route.Version == LegacyVersion && seen[route.Method+" "+route.Path] {
+			return fmt.Errorf("путь %s %s без префикса версии совпадает со служебной точкой входа", route.Method, route.Path)
+		}
+		key := route.Method + " " + route.FullPath()
+		if route.Permission == "" {
+			return fmt.Errorf("для %s не указано разрешение (для открытой точки входа нужно указать AccessPublic)", key)
+		}
+		if route.Handler == nil {
+			return fmt.Errorf("для %s не указан обработчик", key)
+		}
+		if seen[key] {
+			return fmt.Errorf("точка входа %s объявлена дважды", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// warnUnknownPermissions – Предупреждение о разрешениях, которые не подходят ни под одно разрешение
+// или шаблон в базе данных: такие точки входа никому не доступны
+func (server *Server) warnUnknownPermissions() {
+	patterns := []string{}
+	err := server.DB.Model(&models.Permission{}).Pluck("name", &patterns).Error
+	if err != nil {
+		return
+	}
+	known := func(name string) bool {
+		for _, pattern := range patterns {
+			if rbac.Match(pattern, name) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, route := range server.Routes {
+		if route.Permission != AccessPublic && route.Permission != AccessAuthenticated && !known(route.Permission) {
+			logger.Warn("Разрешения для точки входа нет в базе данных", "permission", route.Permission, "method", route.Method, "route", route.FullPath())
+		}
+	}
+}
+
+// GetRoutes – Вывод всех точек входа и разрешений, которые для них нужны
+func (server *Server) GetRoutes(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, server.Routes)
 }
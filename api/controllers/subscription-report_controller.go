@@ -3,25 +3,19 @@ package controllers
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/doka-guide/api/api/models"
 	"github.com/doka-guide/api/api/responses"
-	"github.com/doka-guide/api/api/utils/formaterror"
 	"github.com/gorilla/mux"
 )
 
 // CreateSubscriptionReport – Создание отчёта о загрузке ссылки
 func (server *Server) CreateSubscriptionReport(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "SUBSCRIPTION-REPORT-POST") {
-		return
-	}
-
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
@@ -41,8 +35,7 @@ func (server *Server) CreateSubscriptionReport(w http.ResponseWriter, r *http.Re
 	}
 	reportCreated, err := report.SaveSubscriptionReport(server.DB)
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -57,27 +50,24 @@ func (server *Server) OptionsSubscriptionReports(w http.ResponseWriter, r *http.
 
 // GetSubscriptionReports – Вывод всех отчёта о загрузке ссылок
 func (server *Server) GetSubscriptionReports(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "SUBSCRIPTION-REPORT-GET") {
+	report := models.SubscriptionReport{}
+	query, ok := listQuery(w, r, models.SubscriptionReportListSpec)
+	if !ok {
 		return
 	}
-
-	report := models.SubscriptionReport{}
-	reports, err := report.FindAllSubscriptionReports(server.DB)
+	// Отчёты о чужих подписках выводятся только с правом SUBSCRIPTION-REPORT-GET-ANY
+	ScopeRecords(r, &query, "SUBSCRIPTION-REPORT-GET")
+	reports, next, err := report.FindAllSubscriptionReports(server.DB, query)
 	if err != nil {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	setNextPage(w, r, next)
 	responses.JSON(w, http.StatusOK, reports)
 }
 
 // GetSubscriptionReport – Вывод отчёта о загрузке ссылки по Hash
 func (server *Server) GetSubscriptionReport(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "SUBSCRIPTION-REPORT-GET") {
-		return
-	}
-
 	vars := mux.Vars(r)
 	path := vars["id"]
 	report := models.SubscriptionReport{}
@@ -87,16 +77,15 @@ func (server *Server) GetSubscriptionReport(w http.ResponseWriter, r *http.Reque
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	// Отчёт о чужой подписке можно получить только с правом SUBSCRIPTION-REPORT-GET-ANY
+	if !AuthorizeRecord(w, r, "SUBSCRIPTION-REPORT-GET", reportReceived.Profile.AuthorID) {
+		return
+	}
 	responses.JSON(w, http.StatusOK, reportReceived)
 }
 
 // DeleteSubscriptionReport – Удаляет данные о отчёта о загрузке ссылке из базы данных
 func (server *Server) DeleteSubscriptionReport(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "SUBSCRIPTION-REPORT-DELETE") {
-		return
-	}
-
 	vars := mux.Vars(r)
 
 	// Валидация подписки
@@ -106,30 +95,28 @@ func (server *Server) DeleteSubscriptionReport(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Проверка авторизации
-	uid := GetUserIDByToken(w, r)
-	if !CheckPermission(server.DB, uid, "SUBSCRIPTION-REPORT-DELETE") {
+	// Проверка наличия отчёта и подписки, к которой он относится
+	report := models.SubscriptionReport{}
+	err = server.DB.Model(models.SubscriptionReport{}).Where("id = ?", pid).Take(&report).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
 		return
 	}
-
-	// Проверка наличия подписки
-	report := models.SubscriptionReport{}
-	err = server.DB.Debug().Model(models.SubscriptionReport{}).Where("id = ?", pid).Take(&report).Error
+	err = server.DB.Model(models.Subscription{}).Where("id = ?", report.ProfileID).Take(&report.Profile).Error
 	if err != nil {
-		responses.ERROR(w, http.StatusNotFound, errors.New("Unauthorized"))
+		responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
 		return
 	}
 
-	// Проверка принадлежности подписки пользователю
-	if uid != report.Profile.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+	// Отчёт о чужой подписке можно удалить только с правом SUBSCRIPTION-REPORT-DELETE-ANY
+	if !AuthorizeRecord(w, r, "SUBSCRIPTION-REPORT-DELETE", report.Profile.AuthorID) {
 		return
 	}
-	_, err = report.DeleteASubscriptionReport(server.DB, pid, uid)
+	_, err = report.DeleteASubscriptionReport(server.DB, pid, report.Profile.AuthorID)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 	w.Header().Set("Entity", fmt.Sprintf("%d", pid))
-	responses.JSON(w, http.StatusNoContent, "")
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -3,29 +3,20 @@ package controllers
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"regexp"
 	"strconv"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/doka-guide/api/api/models"
 	"github.com/doka-guide/api/api/responses"
-	"github.com/doka-guide/api/api/utils/formaterror"
-	"github.com/doka-guide/api/api/utils/mail"
 	"github.com/gorilla/mux"
 )
 
 // CreateSubscription – Создание записи о новой отправленной подписке
 func (server *Server) CreateSubscription(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	uid := GetUserIDByToken(w, r)
-	if !CheckPermission(server.DB, uid, "SUBSCRIPTION-POST") {
-		return
-	}
-
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
@@ -44,16 +35,16 @@ func (server *Server) CreateSubscription(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if uid != subForm.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
+	// Создать подписку от имени другого пользователя можно только с правом SUBSCRIPTION-POST-ANY
+	if !AuthorizeRecord(w, r, "SUBSCRIPTION-POST", subForm.AuthorID) {
 		return
 	}
 	subscription, err := subForm.SaveSubscription(server.DB)
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	subscriptionsCreated.Inc()
 	profileLinkForm := models.ProfileLink{}
 	profileLinkForm.Prepare()
 	profileLinkForm.AuthorID = subForm.AuthorID
@@ -63,37 +54,32 @@ func (server *Server) CreateSubscription(w http.ResponseWriter, r *http.Request)
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
-	if uid != profileLinkForm.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
-		return
-	}
 	_, err = profileLinkForm.SaveProfileLink(server.DB)
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	hiImages := os.Getenv("MAIL_IMAGES_HI_HTML")
+	hiImages := server.Config.Mail.ImagesHiHTML
 	imagesRegex := regexp.MustCompile(`\.\/images`)
 
-	hiTxt, err := ioutil.ReadFile(os.Getenv("MAIL_BODY_HI_TEXT"))
+	hiTxt, err := ioutil.ReadFile(server.Config.Mail.BodyHiText)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	hiHTML, err := ioutil.ReadFile(os.Getenv("MAIL_BODY_HI_HTML"))
+	hiHTML, err := ioutil.ReadFile(server.Config.Mail.BodyHiHTML)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
 	varRegex := regexp.MustCompile(`{{ hash }}`)
 
-	mail.SendMail(
+	server.Mailer.SendAsync(
 		"Дорогой участник",
 		subForm.Email,
-		os.Getenv("MAIL_TITLE"),
+		server.Config.Mail.TitleHi,
 		string(varRegex.ReplaceAllString(string(hiTxt), profileLinkForm.Hash)),
 		string(imagesRegex.ReplaceAllString(
 			string(varRegex.ReplaceAllString(string(hiHTML), profileLinkForm.Hash)),
@@ -113,27 +99,24 @@ func (server *Server) OptionsSubscriptions(w http.ResponseWriter, r *http.Reques
 
 // GetSubscriptions – Вывод всех форм
 func (server *Server) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "SUBSCRIPTION-GET") {
+	form := models.Subscription{}
+	query, ok := listQuery(w, r, models.SubscriptionListSpec)
+	if !ok {
 		return
 	}
-
-	form := models.Subscription{}
-	forms, err := form.FindAllSubscriptions(server.DB)
+	// Чужие подписки выводятся только с правом SUBSCRIPTION-GET-ANY
+	ScopeRecords(r, &query, "SUBSCRIPTION-GET")
+	forms, next, err := form.FindAllSubscriptions(server.DB, query)
 	if err != nil {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	setNextPage(w, r, next)
 	responses.JSON(w, http.StatusOK, forms)
 }
 
 // GetSubscription – Вывод подписки по ID
 func (server *Server) GetSubscription(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "SUBSCRIPTION-GET") {
-		return
-	}
-
 	vars := mux.Vars(r)
 	pid, err := strconv.ParseUint(vars["id"], 10, 64)
 	if err != nil {
@@ -147,17 +130,15 @@ func (server *Server) GetSubscription(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	// Чужую подписку можно получить только с правом SUBSCRIPTION-GET-ANY
+	if !AuthorizeRecord(w, r, "SUBSCRIPTION-GET", formReceived.AuthorID) {
+		return
+	}
 	responses.JSON(w, http.StatusOK, formReceived)
 }
 
 // UpdateSubscription – Обновление информации в подписке
 func (server *Server) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	uid := GetUserIDByToken(w, r)
-	if !CheckPermission(server.DB, uid, "SUBSCRIPTION-PUT") {
-		return
-	}
-
 	vars := mux.Vars(r)
 
 	// Валидация информации о подписке
@@ -169,15 +150,14 @@ func (server *Server) UpdateSubscription(w http.ResponseWriter, r *http.Request)
 
 	// Проверка существования подписки
 	form := models.Subscription{}
-	err = server.DB.Debug().Model(models.Subscription{}).Where("id = ?", pid).Take(&form).Error
+	err = server.DB.Model(models.Subscription{}).Where("id = ?", pid).Take(&form).Error
 	if err != nil {
-		responses.ERROR(w, http.StatusNotFound, errors.New("Subscription not found"))
+		responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
 		return
 	}
 
-	// Если пользователь захочет обновить форму, которая отправлена не от него
-	if uid != form.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+	// Чужую подписку можно обновить только с правом SUBSCRIPTION-PUT-ANY
+	if !AuthorizeRecord(w, r, "SUBSCRIPTION-PUT", form.AuthorID) {
 		return
 	}
 	// Чтение данных подписки
@@ -195,13 +175,9 @@ func (server *Server) UpdateSubscription(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Проверка авторизации
-	if uid != formUpdate.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
-		return
-	}
-
+	// Автор подписки не меняется
 	formUpdate.Prepare()
+	formUpdate.AuthorID = form.AuthorID
 	err = formUpdate.Validate()
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
@@ -212,8 +188,7 @@ func (server *Server) UpdateSubscription(w http.ResponseWriter, r *http.Request)
 	formUpdated, err := formUpdate.UpdateASubscription(server.DB)
 
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 	responses.JSON(w, http.StatusOK, formUpdated)
@@ -221,12 +196,6 @@ func (server *Server) UpdateSubscription(w http.ResponseWriter, r *http.Request)
 
 // DeleteSubscription – Удаляет данные подписки из базы данных
 func (server *Server) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	uid := GetUserIDByToken(w, r)
-	if !CheckPermission(server.DB, uid, "SUBSCRIPTION-DELETE") {
-		return
-	}
-
 	vars := mux.Vars(r)
 
 	// Валидация подписки
@@ -238,33 +207,31 @@ func (server *Server) DeleteSubscription(w http.ResponseWriter, r *http.Request)
 
 	// Проверка наличия подписки
 	form := models.Subscription{}
-	err = server.DB.Debug().Model(models.Subscription{}).Where("id = ?", pid).Take(&form).Error
+	err = server.DB.Model(models.Subscription{}).Where("id = ?", pid).Take(&form).Error
 	if err != nil {
-		responses.ERROR(w, http.StatusNotFound, errors.New("Unauthorized"))
+		responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
 		return
 	}
 
-	// Проверка принадлежности подписки пользователю
-	if uid != form.AuthorID {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+	// Чужую подписку можно удалить только с правом SUBSCRIPTION-DELETE-ANY
+	if !AuthorizeRecord(w, r, "SUBSCRIPTION-DELETE", form.AuthorID) {
 		return
 	}
-	_, err = form.DeleteASubscription(server.DB, pid, uid)
+	_, err = form.DeleteASubscription(server.DB, pid, form.AuthorID)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 	w.Header().Set("Entity", fmt.Sprintf("%d", pid))
-	responses.JSON(w, http.StatusNoContent, "")
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // GetSubscriptionFormsWithHash – Вывод адресов электронной почты и настроек с указанием хэша
 func (server *Server) GetSubscriptionFormsWithHash(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "SUBSCRIPTION-GET") {
+	// Выгрузка подписок всех авторов доступна только с правом SUBSCRIPTION-GET-ANY
+	if !AuthorizeAllRecords(w, r, "SUBSCRIPTION-GET") {
 		return
 	}
-
 	vars := mux.Vars(r)
 	start := vars["start"]
 	end := vars["end"]
@@ -0,0 +1,209 @@
+// Package controllers - пакет для обработки данных запросов
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/doka-guide/api/api/auth"
+	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/responses"
+	"github.com/doka-guide/api/api/utils/logger"
+	"github.com/jinzhu/gorm"
+)
+
+// TokenRequest – Тело запросов на обновление токена и выход
+type TokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+}
+
+// OptionsToken – Используется для подготовки соединения
+func (server *Server) OptionsToken(w http.ResponseWriter, r *http.Request) {
+	responses.JSON(w, http.StatusOK, []byte("Запрос OPTIONS обработан"))
+}
+
+// IssueTokens – Выдача пары из токена доступа и токена обновления для пользователя
+func (server *Server) IssueTokens(userID uint64) (*models.RefreshToken, *auth.TokenPair, error) {
+	return issueTokens(server.DB, userID)
+}
+
+// issueTokens – Выдача пары токенов с сохранением токена обновления через db (например, в транзакции)
+func issueTokens(db *gorm.DB, userID uint64) (*models.RefreshToken, *auth.TokenPair, error) {
+	token, err := auth.CreateToken(userID)
+	if err != nil {
+		return &models.RefreshToken{}, &auth.TokenPair{}, err
+	}
+	refreshToken, refreshHash, err := auth.CreateRefreshToken()
+	if err != nil {
+		return &models.RefreshToken{}, &auth.TokenPair{}, err
+	}
+	record := models.RefreshToken{
+		Hash:      refreshHash,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(auth.RefreshTokenLifetime),
+	}
+	record.Prepare()
+	err = record.Validate()
+	if err != nil {
+		return &models.RefreshToken{}, &auth.TokenPair{}, err
+	}
+	saved, err := record.SaveRefreshToken(db)
+	if err != nil {
+		return &models.RefreshToken{}, &auth.TokenPair{}, err
+	}
+	return saved, &auth.TokenPair{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresAt:    time.Now().Add(auth.AccessTokenLifetime).Unix(),
+	}, nil
+}
+
+// RefreshToken – Обмен токена обновления на новую пару токенов (старый токен обновления отзывается)
+func (server *Server) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := TokenRequest{}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if request.RefreshToken == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apierror.Required("refresh_token"))
+		return
+	}
+
+	current := models.RefreshToken{}
+	_, err = current.FindRefreshTokenByHash(server.DB, auth.HashToken(request.RefreshToken))
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
+		return
+	}
+
+	// Повторное использование уже заменённого токена означает его утечку: отзываются все токены пользователя
+	if current.RevokedAt != nil && current.ReplacedByID != 0 {
+		server.revokeReusedRefreshToken(w, r, &current)
+		return
+	}
+	if !current.IsActive() {
+		responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
+		return
+	}
+
+	// Отзыв старого токена и выдача нового в одной транзакции. Токен отзывается первым и только если он
+	// ещё действует, поэтому из нескольких одновременных запросов с одним токеном новую пару получает один
+	var issued *models.RefreshToken
+	var tokens *auth.TokenPair
+	err = server.DB.Transaction(func(tx *gorm.DB) error {
+		revoked, err := current.RevokeARefreshToken(tx, 0)
+		if err != nil {
+			return err
+		}
+		if revoked != 1 {
+			return errRefreshTokenReused
+		}
+		issued, tokens, err = issueTokens(tx, current.UserID)
+		if err != nil {
+			return err
+		}
+		return current.SetReplacedBy(tx, issued.ID)
+	})
+	if err == errRefreshTokenReused {
+		server.revokeReusedRefreshToken(w, r, &current)
+		return
+	}
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, tokens)
+}
+
+// errRefreshTokenReused – Токен обновления отозван другим запросом, пока обрабатывался этот
+var errRefreshTokenReused = errors.New("токен обновления уже отозван")
+
+// revokeReusedRefreshToken – Ответ на повторное использование токена обновления: токен мог утечь,
+// поэтому отзываются все токены обновления пользователя
+func (server *Server) revokeReusedRefreshToken(w http.ResponseWriter, r *http.Request, current *models.RefreshToken) {
+	logger.FromContext(r.Context()).Warn("Повторное использование токена обновления, все сессии пользователя завершены", "user_id", current.UserID)
+	_, err := current.RevokeAllUserRefreshTokens(server.DB, current.UserID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
+}
+
+// Logout – Выход пользователя: отзыв текущего токена доступа и токенов обновления
+func (server *Server) Logout(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.ExtractTokenID(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
+		return
+	}
+	jti, expiresAt, err := auth.ExtractTokenJTI(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	request := TokenRequest{}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &request)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+
+	revoked := models.RevokedToken{JTI: jti, UserID: uid, ExpiresAt: expiresAt}
+	err = revoked.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	_, err = revoked.SaveRevokedToken(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	refresh := models.RefreshToken{}
+	if request.All {
+		_, err = refresh.RevokeAllUserRefreshTokens(server.DB, uid)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+	} else if request.RefreshToken != "" {
+		_, err = refresh.FindRefreshTokenByHash(server.DB, auth.HashToken(request.RefreshToken))
+		if err == nil && refresh.UserID == uid {
+			_, err = refresh.RevokeARefreshToken(server.DB, 0)
+			if err != nil {
+				responses.ERROR(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JWKS – Открытые ключи, которыми подписываются токены доступа
+func (server *Server) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	responses.JSON(w, http.StatusOK, auth.PublicKeys())
+}
@@ -3,25 +3,20 @@ package controllers
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/doka-guide/api/api/models"
 	"github.com/doka-guide/api/api/responses"
-	"github.com/doka-guide/api/api/utils/formaterror"
+	"github.com/doka-guide/api/api/utils/logger"
 	"github.com/gorilla/mux"
 )
 
 // CreateUser - Создание пользователя
 func (server *Server) CreateUser(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "USER-POST") {
-		return
-	}
-
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
@@ -42,49 +37,41 @@ func (server *Server) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 
-		formattedError := formaterror.FormatError(err.Error())
-
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	err = server.SendVerificationMail(userCreated)
+	if err != nil {
+		logger.FromContext(r.Context()).Error("Не удалось отправить письмо для подтверждения почты", "user_id", userCreated.ID, "error", err)
+	}
 	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.RequestURI, userCreated.ID))
 	responses.JSON(w, http.StatusCreated, userCreated)
 }
 
 // OptionsUsers – Используется для подготовки соединения
 func (server *Server) OptionsUsers(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "USER-OPTIONS") {
-		return
-	}
-
 	responses.JSON(w, http.StatusOK, []byte("Запрос OPTIONS обработан"))
 }
 
 // GetUsers - all users
 func (server *Server) GetUsers(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "USER-GET") {
-		return
-	}
-
 	user := models.User{}
 
-	users, err := user.FindAllUsers(server.DB)
+	query, ok := listQuery(w, r, models.UserListSpec)
+	if !ok {
+		return
+	}
+	users, next, err := user.FindAllUsers(server.DB, query)
 	if err != nil {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	setNextPage(w, r, next)
 	responses.JSON(w, http.StatusOK, users)
 }
 
 // GetUser - Получение информации о пользователе
 func (server *Server) GetUser(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	if !CheckPermission(server.DB, GetUserIDByToken(w, r), "USER-GET") {
-		return
-	}
-
 	vars := mux.Vars(r)
 	uid, err := strconv.ParseUint(vars["id"], 10, 64)
 	if err != nil {
@@ -94,7 +81,7 @@ func (server *Server) GetUser(w http.ResponseWriter, r *http.Request) {
 	user := models.User{}
 	userGotten, err := user.FindUserByID(server.DB, uid)
 	if err != nil {
-		responses.ERROR(w, http.StatusBadRequest, err)
+		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
 	responses.JSON(w, http.StatusOK, userGotten)
@@ -120,13 +107,10 @@ func (server *Server) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Проверка авторизации
-	tokenID := GetUserIDByToken(w, r)
-	if !CheckPermission(server.DB, tokenID, "USER-PUT") {
-		return
-	}
+	// Пользователь, от имени которого выполняется запрос
+	tokenID := RequestUserID(r)
 	if tokenID != uid {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
+		responses.ERROR(w, http.StatusForbidden, apierror.ErrForbidden)
 		return
 	}
 
@@ -136,22 +120,31 @@ func (server *Server) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
+	current := models.User{}
+	_, err = current.FindUserByID(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
 	updatedUser, err := user.UpdateAUser(server.DB, uid)
 	if err != nil {
-		formattedError := formaterror.FormatError(err.Error())
-		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	// После смены адреса подтверждение сбрасывается, и письмо отправляется на новый адрес
+	if updatedUser.Email != current.Email {
+		err = server.SendVerificationMail(updatedUser)
+		if err != nil {
+			logger.FromContext(r.Context()).Error("Не удалось отправить письмо для подтверждения почты", "user_id", updatedUser.ID, "error", err)
+		}
+	}
 	responses.JSON(w, http.StatusOK, updatedUser)
 }
 
 // DeleteUser - Удаление пользователя
 func (server *Server) DeleteUser(w http.ResponseWriter, r *http.Request) {
-	// Проверка авторизации
-	tokenID := GetUserIDByToken(w, r)
-	if !CheckPermission(server.DB, tokenID, "USER-DELETE") {
-		return
-	}
+	// Пользователь, от имени которого выполняется запрос
+	tokenID := RequestUserID(r)
 
 	vars := mux.Vars(r)
 	user := models.User{}
@@ -162,20 +155,68 @@ func (server *Server) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Удалить можно только свою учётную запись
 	if tokenID != uid {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
+		responses.ERROR(w, http.StatusForbidden, apierror.ErrForbidden)
 		return
 	}
+	_, err = user.DeleteAUser(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Entity", fmt.Sprintf("%d", uid))
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	if tokenID != 0 && tokenID != uid {
-		responses.ERROR(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
+// UnlockUser - Снятие блокировки входа для пользователя (и, если передан параметр ip, для IP-адреса)
+func (server *Server) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
-	_, err = user.DeleteAUser(server.DB, uid)
+	user := models.User{}
+	_, err = user.FindUserByID(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
+		return
+	}
+	err = server.AccountLimiter.Succeed("account:" + user.Email)
 	if err != nil {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	if ip := r.URL.Query().Get("ip"); ip != "" {
+		err = server.IPLimiter.Succeed("ip:" + ip)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
 	w.Header().Set("Entity", fmt.Sprintf("%d", uid))
-	responses.JSON(w, http.StatusNoContent, "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserPermissions - Действующие разрешения и запреты пользователя с путём групп, через который они получены
+func (server *Server) GetUserPermissions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	user := models.User{}
+	_, err = user.FindUserByID(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	permissions, err := models.FindUserEffectivePermissions(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, permissions)
 }
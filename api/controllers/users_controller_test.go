@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/doka-guide/api/api/auth"
+	"github.com/doka-guide/api/api/utils/mail"
+)
+
+// fakeSMTP – SMTP-сервер с TLS, который принимает письма и передаёт адреса получателей в канал
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	tlsServer := httptest.NewUnstartedServer(nil)
+	tlsServer.StartTLS()
+	certificates := tlsServer.TLS.Certificates
+	tlsServer.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	recipients := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, recipients)
+		}
+	}()
+	return listener.Addr().String(), recipients
+}
+
+func serveSMTP(conn net.Conn, recipients chan<- string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "AUTH"):
+			reply("235 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			recipients <- strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>")
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 OK")
+			for {
+				data, err := reader.ReadString('\n')
+				if err != nil || data == ".\r\n" {
+					break
+				}
+			}
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 OK")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// mailServer – сервер с базой данных sqlmock и почтой, которая уходит на fakeSMTP
+func mailServer(t *testing.T) (*Server, sqlmock.Sqlmock, <-chan string) {
+	server, mock := mockServer(t)
+	auth.SetKeyRing(auth.NewSecretKeyRing("secret"))
+	t.Cleanup(func() { auth.SetKeyRing(nil) })
+	host, recipients := fakeSMTP(t)
+	server.Mailer = &mail.Mailer{Host: host, User: "api@example.com"}
+
+	dir := t.TempDir()
+	server.Config.Mail.BodyVerifyText = filepath.Join(dir, "verify.txt")
+	server.Config.Mail.BodyVerifyHTML = filepath.Join(dir, "verify.html")
+	for _, path := range []string{server.Config.Mail.BodyVerifyText, server.Config.Mail.BodyVerifyHTML} {
+		if err := ioutil.WriteFile(path, []byte("{{ link }}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return server, mock, recipients
+}
+
+func updateUserRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPut, "/v1/user/7", strings.NewReader(body))
+	r = mux.SetURLVars(r, map[string]string{"id": "7"})
+	return r.WithContext(auth.NewContext(r.Context(), &auth.Identity{UserID: 7}))
+}
+
+// TestUpdateUserEmailChange – новый адрес считается неподтверждённым, и на него уходит письмо
+func TestUpdateUserEmailChange(t *testing.T) {
+	server, mock, recipients := mailServer(t)
+	verifiedAt := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "email", "verified_at"}).AddRow(7, "user", "old@example.com", verifiedAt))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "email", "verified_at"}).AddRow(7, "user", "old@example.com", verifiedAt))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET "email" = \$1, "nickname" = \$2, "password" = \$3, "updated_at" = \$4, "verified_at" = \$5 WHERE`).
+		WithArgs("new@example.com", "user", sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "email", "verified_at"}).AddRow(7, "user", "new@example.com", nil))
+
+	w := httptest.NewRecorder()
+	server.UpdateUser(w, updateUserRequest(`{"nickname":"user","email":"new@example.com","password":"password"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"verified_at":null`) {
+		t.Errorf("body = %s, want verified_at null", w.Body.String())
+	}
+	select {
+	case recipient := <-recipients:
+		if recipient != "new@example.com" {
+			t.Errorf("verification mail sent to %q, want new@example.com", recipient)
+		}
+	default:
+		t.Error("verification mail was not sent")
+	}
+}
+
+// TestUpdateUserSameEmail – без смены адреса подтверждение сохраняется, письмо не отправляется
+func TestUpdateUserSameEmail(t *testing.T) {
+	server, mock, recipients := mailServer(t)
+	verifiedAt := time.Now().Add(-time.Hour)
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(`SELECT \* FROM "users" WHERE`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "email", "verified_at"}).AddRow(7, "user", "user@example.com", verifiedAt))
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET "email" = \$1, "nickname" = \$2, "password" = \$3, "updated_at" = \$4 WHERE`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nickname", "email", "verified_at"}).AddRow(7, "user", "user@example.com", verifiedAt))
+
+	w := httptest.NewRecorder()
+	server.UpdateUser(w, updateUserRequest(`{"nickname":"user","email":"user@example.com","password":"password"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	select {
+	case recipient := <-recipients:
+		t.Errorf("unexpected verification mail to %q", recipient)
+	default:
+	}
+}
+
+// TestOtherUserForbidden – изменить или удалить чужую учётную запись нельзя: ответ 403, база данных не затрагивается
+func TestOtherUserForbidden(t *testing.T) {
+	handlers := map[string]func(server *Server) http.HandlerFunc{
+		http.MethodPut:    func(s *Server) http.HandlerFunc { return s.UpdateUser },
+		http.MethodDelete: func(s *Server) http.HandlerFunc { return s.DeleteUser },
+	}
+	for method, handler := range handlers {
+		t.Run(method, func(t *testing.T) {
+			server, _ := mockServer(t)
+			r := httptest.NewRequest(method, "/v1/user/8", strings.NewReader(`{"nickname":"user","email":"user@example.com","password":"password"}`))
+			r = mux.SetURLVars(r, map[string]string{"id": "8"})
+			r = r.WithContext(auth.NewContext(r.Context(), &auth.Identity{UserID: 7}))
+			w := httptest.NewRecorder()
+			handler(server)(w, r)
+			if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"code":"FORBIDDEN"`) {
+				t.Errorf("%s /user/8 = %d %s, want 403 FORBIDDEN", method, w.Code, w.Body.String())
+			}
+		})
+	}
+}
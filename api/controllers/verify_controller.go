@@ -0,0 +1,84 @@
+// Package controllers - пакет для обработки данных запросов
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/doka-guide/api/api/auth"
+	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/responses"
+	"github.com/doka-guide/api/api/utils/mail"
+	"github.com/gorilla/mux"
+)
+
+// ErrEmailNotVerified – Вход запрещён, пока пользователь не подтвердил электронную почту
+var ErrEmailNotVerified = apierror.New(apierror.CodeEmailNotVerified, http.StatusForbidden)
+
+// RequireVerifiedEmail – Включено ли требование подтверждённой почты для входа (параметр AUTH_REQUIRE_VERIFIED)
+func (server *Server) RequireVerifiedEmail() bool {
+	return server.Config.Auth.RequireVerified
+}
+
+// SendVerificationMail – Отправка письма со ссылкой для подтверждения электронной почты
+func (server *Server) SendVerificationMail(user *models.User) error {
+	token, err := auth.CreateVerificationToken(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+	vars := map[string]string{
+		"link":     strings.TrimRight(server.Config.App.URL, "/") + "/" + CurrentVersion + "/user/verify/" + token,
+		"lifetime": "двое суток",
+	}
+	verifyTxt, err := mail.RenderTemplate(server.Config.Mail.BodyVerifyText, vars)
+	if err != nil {
+		return err
+	}
+	verifyHTML, err := mail.RenderTemplate(server.Config.Mail.BodyVerifyHTML, vars)
+	if err != nil {
+		return err
+	}
+	return server.Mailer.Send(user.Nickname, user.Email, server.Config.Mail.TitleVerify, verifyTxt, verifyHTML, false)
+}
+
+// VerificationResult – Ответ на переход по ссылке подтверждения: без данных пользователя, так как запрос не требует авторизации
+type VerificationResult struct {
+	Verified   bool       `json:"verified"`
+	VerifiedAt *time.Time `json:"verified_at"`
+}
+
+// VerifyUser – Подтверждение электронной почты по ссылке из письма
+func (server *Server) VerifyUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uid, email, err := auth.ParseVerificationToken(vars["token"])
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apierror.ErrTokenInvalid)
+		return
+	}
+
+	user := models.User{}
+	_, err = user.FindUserByID(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
+		return
+	}
+
+	// Ссылка, отправленная на прежний адрес, не подтверждает новый
+	if user.Email != email {
+		responses.ERROR(w, http.StatusUnprocessableEntity, apierror.ErrTokenInvalid)
+		return
+	}
+	if user.IsVerified() {
+		responses.JSON(w, http.StatusOK, VerificationResult{Verified: true, VerifiedAt: user.VerifiedAt})
+		return
+	}
+
+	verifiedUser, err := user.VerifyAUser(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, VerificationResult{Verified: true, VerifiedAt: verifiedUser.VerifiedAt})
+}
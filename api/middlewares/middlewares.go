@@ -2,13 +2,178 @@
 package middlewares
 
 import (
-	"errors"
+	"context"
 	"net/http"
+	"regexp"
+	"strconv"
+	"time"
 
+	"github.com/gorilla/mux"
+
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/doka-guide/api/api/auth"
 	"github.com/doka-guide/api/api/responses"
+	"github.com/doka-guide/api/api/utils/logger"
+	"github.com/doka-guide/api/api/utils/metrics"
+	"github.com/doka-guide/api/api/utils/randomize"
+)
+
+// RequestIDHeader – заголовок с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDFormat – допустимый идентификатор запроса от клиента или прокси; иначе создаётся новый
+var requestIDFormat = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Метрики запросов: точка входа записывается шаблоном (например, /user/{id}), а не путём запроса
+var (
+	requestsTotal = metrics.NewCounterVec("doka_http_requests_total",
+		"Количество запросов по методу, точке входа и коду ответа", "method", "route", "status").Limit(1000)
+	requestDuration = metrics.NewHistogramVec("doka_http_request_duration_seconds",
+		"Длительность обработки запросов", metrics.DefBuckets, "method", "route").Limit(500)
 )
 
+// requestInfo – сведения о запросе, которые посредники заполняют для журнала
+type requestInfo struct {
+	route  string
+	userID uint64
+}
+
+// requestInfoKey – ключ сведений о запросе в контексте
+type requestInfoKey struct{}
+
+// statusRecorder – запоминает код ответа для журнала
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader – Запись кода ответа
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write – Запись тела ответа (без WriteHeader код ответа 200)
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// SetMiddlewareRequestLog – Идентификатор запроса из заголовка X-Request-ID (или новый) и запись в журнал
+// метода, точки входа, кода ответа, длительности и пользователя. Журнал запроса доступен обработчикам
+// через logger.FromContext
+func SetMiddlewareRequestLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if !requestIDFormat.MatchString(id) {
+			id, _ = randomize.GetSecureToken(8)
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		log := logger.Default().With("request_id", id)
+		info := &requestInfo{}
+		ctx := context.WithValue(r.Context(), requestInfoKey{}, info)
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(logger.NewContext(ctx, log)))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		fields := []interface{}{"method", r.Method}
+		if info.route != "" {
+			fields = append(fields, "route", info.route)
+		} else {
+			fields = append(fields, "path", r.URL.Path)
+		}
+		fields = append(fields, "status", status, "duration_ms", float64(time.Since(start).Microseconds())/1000)
+		if info.userID != 0 {
+			fields = append(fields, "user_id", info.userID)
+		}
+		route := info.route
+		if route == "" {
+			route = "unmatched"
+		}
+		requestsTotal.Inc(r.Method, route, strconv.Itoa(status))
+		requestDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+
+		level := logger.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = logger.LevelError
+		}
+		log.Log(level, "Запрос", fields...)
+	})
+}
+
+// SetMiddlewareLanguage – Язык сообщений об ошибках по заголовку Accept-Language (ru или en, по умолчанию ru);
+// выбранный язык записывается в заголовок Content-Language, который читает responses.ERROR
+func SetMiddlewareLanguage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Language", apierror.Negotiate(r.Header.Get("Accept-Language")))
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NotFound – Ответ с ошибкой NOT_FOUND для путей без точки входа
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	responses.ERROR(w, http.StatusNotFound, apierror.ErrNotFound)
+}
+
+// MethodNotAllowed – Ответ с ошибкой METHOD_NOT_ALLOWED для неподдерживаемого метода
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	responses.ERROR(w, http.StatusMethodNotAllowed, apierror.ErrMethodNotAllowed)
+}
+
+// SetMiddlewareDeprecated – Заголовки устаревшего пути: Deprecation (RFC 9745; true, если дата не задана),
+// Sunset (RFC 8594), если задана дата отключения, и Link на тот же путь с префиксом актуальной версии
+func SetMiddlewareDeprecated(prefix string, deprecatedAt, sunsetAt time.Time, next http.HandlerFunc) http.HandlerFunc {
+	deprecation := "true"
+	if !deprecatedAt.IsZero() {
+		deprecation = "@" + strconv.FormatInt(deprecatedAt.Unix(), 10)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", deprecation)
+		if !sunsetAt.IsZero() {
+			w.Header().Set("Sunset", sunsetAt.UTC().Format(http.TimeFormat))
+		}
+		successor := prefix + r.URL.EscapedPath()
+		if r.URL.RawQuery != "" {
+			successor += "?" + r.URL.RawQuery
+		}
+		w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+		next(w, r)
+	}
+}
+
+// SetMiddlewareRoute – Запоминание шаблона точки входа (например, /user/{id}) для журнала запросов
+func SetMiddlewareRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo)
+		if route := mux.CurrentRoute(r); ok && route != nil {
+			info.route, _ = route.GetPathTemplate()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withIdentity – Контекст запроса с субъектом; пользователь попадает в журнал запроса
+func withIdentity(r *http.Request, identity *auth.Identity) *http.Request {
+	ctx := auth.NewContext(r.Context(), identity)
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.userID = identity.UserID
+	}
+	ctx = logger.NewContext(ctx, logger.FromContext(ctx).With("user_id", identity.UserID))
+	return r.WithContext(ctx)
+}
+
 // SetMiddlewareJSON – Настройка посредника для обработки запросов
 func SetMiddlewareJSON(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -16,21 +181,38 @@ func SetMiddlewareJSON(next http.HandlerFunc) http.HandlerFunc {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Access-Control-Allow-Headers, Accept-Encoding, Authorization, Content-Length, Content-Type, X-CSRF-Token, X-Requested-With")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Access-Control-Allow-Headers, Accept-Encoding, Authorization, Content-Length, Content-Type, X-API-Key, X-CSRF-Token, X-Request-ID, X-Requested-With")
+		w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader+", Content-Language, Deprecation, Sunset, Link, X-Next-Cursor")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		w.Header().Set("Content-Type", "application/json")
 		next(w, r)
 	}
 }
 
-// SetMiddlewareAuthentication - Настройки аутентификации пользователей
+// SetMiddlewareAuthentication - Настройки аутентификации пользователей; субъект запроса сохраняется в контексте
 func SetMiddlewareAuthentication(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		err := auth.TokenValid(r)
+		identity, err := auth.ExtractIdentity(r)
 		if err != nil {
-			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
 			return
 		}
-		next(w, r)
+		next(w, withIdentity(r, identity))
+	}
+}
+
+// SetMiddlewarePermission - Проверка права на операцию; субъект запроса сохраняется в контексте
+func SetMiddlewarePermission(permName string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, err := auth.Authorize(r, permName)
+		if err == auth.ErrForbidden {
+			responses.ERROR(w, http.StatusForbidden, apierror.ErrForbidden)
+			return
+		}
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, apierror.ErrUnauthorized)
+			return
+		}
+		next(w, withIdentity(r, identity))
 	}
 }
@@ -0,0 +1,263 @@
+// Package migrations - пакет для версионных миграций схемы базы данных
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// lockKey - ключ рекомендательной блокировки PostgreSQL: миграции выполняет только одна копия API
+const lockKey int64 = 7102024015
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// fileName - формат имени файла миграции: 0001_название.up.sql или 0001_название.down.sql
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration - миграция схемы базы данных
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status - состояние миграции в базе данных
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+	// Миграция применена, но её файла нет в сборке (база данных новее приложения)
+	Unknown bool `json:"unknown"`
+}
+
+// appliedMigration - запись таблицы schema_migrations
+type appliedMigration struct {
+	Version   int64 `gorm:"primary_key"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// TableName - Название таблицы с применёнными миграциями
+func (appliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// All - Все миграции из файлов сборки по возрастанию версии
+func All() ([]Migration, error) {
+	entries, err := files.ReadDir("sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("Неверное имя файла миграции: %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data, err := files.ReadFile(path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("У миграции %d разные названия: %s и %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("У миграции %d нет файла up", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up - Применение всех новых миграций; возвращает применённые миграции
+func Up(db *gorm.DB) ([]Migration, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+	done := []Migration{}
+	err = withLock(db, func() error {
+		applied, err := appliedVersions(db)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err = run(db, m.Up, func(tx *gorm.DB) error {
+				return tx.Create(&appliedMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("Миграция %d_%s: %v", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down - Откат последних steps применённых миграций; возвращает откаченные миграции
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]Migration{}
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+	done := []Migration{}
+	err = withLock(db, func() error {
+		applied := []appliedMigration{}
+		err := db.Order("version DESC").Limit(steps).Find(&applied).Error
+		if err != nil {
+			return err
+		}
+		for _, a := range applied {
+			m, ok := byVersion[a.Version]
+			if !ok {
+				return fmt.Errorf("Миграции %d_%s нет в этой сборке", a.Version, a.Name)
+			}
+			if m.Down == "" {
+				return fmt.Errorf("Миграцию %d_%s нельзя откатить: нет файла down", m.Version, m.Name)
+			}
+			err = run(db, m.Down, func(tx *gorm.DB) error {
+				return tx.Where("version = ?", m.Version).Delete(&appliedMigration{}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("Откат миграции %d_%s: %v", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// List - Состояние всех миграций: из файлов сборки и из таблицы schema_migrations
+func List(db *gorm.DB) ([]Status, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+	err = createTable(db)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := []Status{}
+	for _, m := range migrations {
+		status := Status{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			appliedAt := a.AppliedAt
+			status.AppliedAt = &appliedAt
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, a := range applied {
+		appliedAt := a.AppliedAt
+		statuses = append(statuses, Status{Version: a.Version, Name: a.Name, AppliedAt: &appliedAt, Unknown: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// run - Выполнение SQL миграции и изменение schema_migrations в одной транзакции
+func run(db *gorm.DB, script string, record func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Запрос без параметров: в одном файле может быть несколько команд
+		_, err := tx.CommonDB().Exec(script)
+		if err != nil {
+			return err
+		}
+		return record(tx)
+	})
+}
+
+// appliedVersions - Применённые миграции по версиям
+func appliedVersions(db *gorm.DB) (map[int64]appliedMigration, error) {
+	applied := []appliedMigration{}
+	err := db.Find(&applied).Error
+	if err != nil {
+		return nil, err
+	}
+	versions := map[int64]appliedMigration{}
+	for _, a := range applied {
+		versions[a.Version] = a
+	}
+	return versions, nil
+}
+
+// createTable - Создание таблицы schema_migrations
+func createTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`).Error
+}
+
+// withLock - Выполнение fn под рекомендательной блокировкой: блокировка держится на отдельном соединении,
+// поэтому вторая копия API ждёт, пока первая не закончит миграции
+func withLock(db *gorm.DB, fn func() error) error {
+	sqlDB := db.DB()
+	if sqlDB == nil {
+		return errors.New("Нет соединения с базой данных")
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+
+	err = createTable(db)
+	if err != nil {
+		return err
+	}
+	return fn()
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+
+	// Драйвер для работы с PostgreSQL
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// TestInitialMigrationIrreversible – первая миграция принимает таблицы, созданные до миграций, поэтому не откатывается
+func TestInitialMigrationIrreversible(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 {
+		t.Fatalf("first migration = %v, want version 1", migrations)
+	}
+	if migrations[0].Down != "" {
+		t.Error("0001_initial has a down step that would drop pre-migration tables")
+	}
+}
+
+// TestDownRefusesInitialMigration – откат до первой миграции останавливается с ошибкой и ничего не удаляет
+func TestDownRefusesInitialMigration(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open("postgres", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.LogMode(false)
+	defer db.Close()
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "schema_migrations"`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name"}).AddRow(1, "initial"))
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	done, err := Down(db, 1)
+	if err == nil || !strings.Contains(err.Error(), "нельзя откатить") {
+		t.Fatalf("Down(1) error = %v, want refusal for 0001_initial", err)
+	}
+	if len(done) != 0 {
+		t.Errorf("Down(1) rolled back %v", done)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,211 @@
+-- Исходная схема базы данных (раньше создавалась AutoMigrate и сидером).
+-- Таблицы и индексы создаются, только если их ещё нет, а столбцы, которых
+-- не было в первых версиях таблиц, добавляются отдельно. Поэтому миграция
+-- применяется и к базам данных, созданным до появления миграций: новая
+-- и обновлённая базы данных получают одинаковую схему.
+-- Файла down у миграции нет: откат удалил бы данные, которые были в базе
+-- до появления миграций.
+
+CREATE TABLE IF NOT EXISTS users (
+  id bigserial PRIMARY KEY,
+  nickname varchar(255) NOT NULL UNIQUE,
+  email varchar(100) NOT NULL UNIQUE,
+  password varchar(100) NOT NULL,
+  verified_at timestamp with time zone,
+  totp_secret varchar(64),
+  totp_enabled_at timestamp with time zone,
+  totp_last_step bigint NOT NULL DEFAULT 0,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_groups (
+  id bigserial PRIMARY KEY,
+  name varchar(255) NOT NULL UNIQUE,
+  email varchar(100) NOT NULL UNIQUE,
+  require_mfa boolean NOT NULL DEFAULT false,
+  parent_id bigint,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS grouped_users (
+  id bigserial PRIMARY KEY,
+  group_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_grouped_users_pair ON grouped_users (group_id, user_id);
+
+CREATE TABLE IF NOT EXISTS permissions (
+  id bigserial PRIMARY KEY,
+  name varchar(255) NOT NULL UNIQUE,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS group_permissions (
+  id bigserial PRIMARY KEY,
+  group_id bigint NOT NULL,
+  perms_id bigint NOT NULL,
+  deny boolean NOT NULL DEFAULT false,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_permissions_pair ON group_permissions (group_id, perms_id);
+
+CREATE TABLE IF NOT EXISTS permission_versions (
+  id bigint PRIMARY KEY,
+  version bigint NOT NULL DEFAULT 0,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+  id bigserial PRIMARY KEY,
+  email varchar(255) NOT NULL,
+  data jsonb NOT NULL,
+  author_id bigint NOT NULL,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS profile_links (
+  id bigserial PRIMARY KEY,
+  hash varchar(255) NOT NULL UNIQUE,
+  author_id bigint NOT NULL,
+  profile_id bigint NOT NULL,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS subscription_reports (
+  id bigserial PRIMARY KEY,
+  path varchar(255) NOT NULL,
+  profile_id bigint NOT NULL,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS forms (
+  id bigserial PRIMARY KEY,
+  type varchar(255) NOT NULL,
+  data jsonb NOT NULL,
+  author_id bigint NOT NULL,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id bigserial PRIMARY KEY,
+  hash varchar(64) NOT NULL UNIQUE,
+  user_id bigint NOT NULL,
+  expires_at timestamp with time zone NOT NULL,
+  revoked_at timestamp with time zone,
+  replaced_by_id bigint,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+  id bigserial PRIMARY KEY,
+  jti varchar(64) NOT NULL UNIQUE,
+  user_id bigint NOT NULL,
+  expires_at timestamp with time zone NOT NULL,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+  id bigserial PRIMARY KEY,
+  hash varchar(64) NOT NULL UNIQUE,
+  user_id bigint NOT NULL,
+  expires_at timestamp with time zone NOT NULL,
+  used_at timestamp with time zone,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets (user_id);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+  id bigserial PRIMARY KEY,
+  name varchar(255) NOT NULL UNIQUE,
+  prefix varchar(32) NOT NULL UNIQUE,
+  hash varchar(64) NOT NULL,
+  group_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  expires_at timestamp with time zone,
+  last_used_at timestamp with time zone,
+  revoked_at timestamp with time zone,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  id bigserial PRIMARY KEY,
+  hash varchar(64) NOT NULL,
+  user_id bigint NOT NULL,
+  used_at timestamp with time zone,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+  id bigserial PRIMARY KEY,
+  subject varchar(255) NOT NULL UNIQUE,
+  failures integer NOT NULL DEFAULT 0,
+  last_failure_at timestamp with time zone,
+  locked_until timestamp with time zone,
+  created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+  updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Столбцы, добавленные после первой версии таблиц: в старых базах данных
+-- таблицы уже есть, и CREATE TABLE IF NOT EXISTS их не меняет
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS verified_at timestamp with time zone,
+  ADD COLUMN IF NOT EXISTS totp_secret varchar(64),
+  ADD COLUMN IF NOT EXISTS totp_enabled_at timestamp with time zone,
+  ADD COLUMN IF NOT EXISTS totp_last_step bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE user_groups
+  ADD COLUMN IF NOT EXISTS require_mfa boolean NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS parent_id bigint;
+CREATE INDEX IF NOT EXISTS idx_user_groups_parent_id ON user_groups (parent_id);
+
+ALTER TABLE group_permissions
+  ADD COLUMN IF NOT EXISTS deny boolean NOT NULL DEFAULT false;
+
+-- Внешние ключи с именами, которые давал им AddForeignKey. В старых базах данных
+-- ключи могли не создаваться, поэтому они добавляются без проверки существующих
+-- записей (NOT VALID) и действуют для новых и изменяемых записей.
+DO $$
+DECLARE
+  fk record;
+BEGIN
+  FOR fk IN SELECT * FROM (VALUES
+    ('grouped_users', 'user_id', 'users', 'CASCADE'),
+    ('grouped_users', 'group_id', 'user_groups', 'CASCADE'),
+    ('group_permissions', 'perms_id', 'permissions', 'CASCADE'),
+    ('group_permissions', 'group_id', 'user_groups', 'CASCADE'),
+    ('user_groups', 'parent_id', 'user_groups', 'SET NULL'),
+    ('refresh_tokens', 'user_id', 'users', 'CASCADE'),
+    ('password_resets', 'user_id', 'users', 'CASCADE'),
+    ('api_keys', 'group_id', 'user_groups', 'CASCADE'),
+    ('api_keys', 'user_id', 'users', 'CASCADE'),
+    ('recovery_codes', 'user_id', 'users', 'CASCADE'),
+    ('forms', 'author_id', 'users', 'CASCADE'),
+    ('subscriptions', 'author_id', 'users', 'CASCADE'),
+    ('profile_links', 'author_id', 'users', 'CASCADE'),
+    ('profile_links', 'profile_id', 'subscriptions', 'CASCADE'),
+    ('subscription_reports', 'profile_id', 'subscriptions', 'CASCADE')
+  ) AS t (tbl, col, ref, on_delete) LOOP
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = fk.tbl || '_' || fk.col || '_' || fk.ref || '_id_foreign') THEN
+      EXECUTE format('ALTER TABLE %I ADD CONSTRAINT %I FOREIGN KEY (%I) REFERENCES %I (id) ON DELETE %s ON UPDATE CASCADE NOT VALID',
+        fk.tbl, fk.tbl || '_' || fk.col || '_' || fk.ref || '_id_foreign', fk.col, fk.ref, fk.on_delete);
+    END IF;
+  END LOOP;
+END
+$$;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_forms_author_id;
+DROP INDEX IF EXISTS idx_subscriptions_author_id;
+DROP INDEX IF EXISTS idx_profile_links_profile_id;
+DROP INDEX IF EXISTS idx_subscription_reports_profile_id;
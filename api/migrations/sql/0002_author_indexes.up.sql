@@ -0,0 +1,6 @@
+-- Индексы для выборок по автору и подписке: проверка своих записей,
+-- каскадное удаление пользователей и подписок
+CREATE INDEX IF NOT EXISTS idx_forms_author_id ON forms (author_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_author_id ON subscriptions (author_id);
+CREATE INDEX IF NOT EXISTS idx_profile_links_profile_id ON profile_links (profile_id);
+CREATE INDEX IF NOT EXISTS idx_subscription_reports_profile_id ON subscription_reports (profile_id);
@@ -0,0 +1,156 @@
+// Package models - пакет для описания моделей, которые используются для хранения данных
+package models
+
+import (
+	"crypto/subtle"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/jinzhu/gorm"
+)
+
+// APIKey - ключ доступа к API для сервисов (сборка сайта, рассылка), выдаёт права группы пользователей
+type APIKey struct {
+	ID         uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	Name       string     `gorm:"size:255;not null;unique" json:"name"`
+	Prefix     string     `gorm:"size:32;not null;unique" json:"prefix"`
+	Hash       string     `gorm:"size:64;not null" json:"-"`
+	Group      UserGroup  `json:"group"`
+	GroupID    uint64     `gorm:"not null" json:"group_id"`
+	UserID     uint64     `gorm:"not null" json:"user_id"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// Prepare - Подготовка ключа доступа
+func (k *APIKey) Prepare() {
+	k.ID = 0
+	k.Name = html.EscapeString(strings.TrimSpace(k.Name))
+	k.Group = UserGroup{}
+	k.LastUsedAt = nil
+	k.RevokedAt = nil
+	k.CreatedAt = time.Now()
+	k.UpdatedAt = time.Now()
+}
+
+// Validate - Валидация ключа доступа
+func (k *APIKey) Validate() error {
+	if k.Name == "" {
+		return apierror.Required("name")
+	}
+	if k.Prefix == "" || k.Hash == "" {
+		return apierror.Required("key")
+	}
+	if k.GroupID < 1 {
+		return apierror.Required("group_id")
+	}
+	if k.UserID < 1 {
+		return apierror.Required("user_id")
+	}
+	if k.ExpiresAt != nil && k.ExpiresAt.Before(time.Now()) {
+		return apierror.Validation(apierror.Field("expires_at", apierror.FieldExpired))
+	}
+	return nil
+}
+
+// IsActive - Проверка, что ключ доступа не отозван и не просрочен
+func (k *APIKey) IsActive() bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt)
+}
+
+// SaveAPIKey - Сохранение ключа доступа
+func (k *APIKey) SaveAPIKey(db *gorm.DB) (*APIKey, error) {
+	var err error
+	err = db.Model(&APIKey{}).Create(&k).Error
+	if err != nil {
+		return &APIKey{}, err
+	}
+	if k.ID != 0 {
+		err = db.Model(&UserGroup{}).Where("id = ?", k.GroupID).Take(&k.Group).Error
+		if err != nil {
+			return &APIKey{}, err
+		}
+	}
+	return k, nil
+}
+
+// FindAllAPIKeys - Вывод всех ключей доступа страницами с сортировкой и фильтрами из query
+func (k *APIKey) FindAllAPIKeys(db *gorm.DB, query ListQuery) (*[]APIKey, string, error) {
+	keys := []APIKey{}
+	next, err := Paginate(db.Model(&APIKey{}), APIKeyListSpec, query, &keys)
+	if err != nil {
+		return &[]APIKey{}, "", err
+	}
+	for i := range keys {
+		err := db.Model(&UserGroup{}).Where("id = ?", keys[i].GroupID).Take(&keys[i].Group).Error
+		if err != nil {
+			return &[]APIKey{}, "", err
+		}
+	}
+	return &keys, next, nil
+}
+
+// FindAPIKeyByID - Вывод данных ключа доступа с ID
+func (k *APIKey) FindAPIKeyByID(db *gorm.DB, kid uint64) (*APIKey, error) {
+	var err error
+	err = db.Model(&APIKey{}).Where("id = ?", kid).Take(&k).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return &APIKey{}, apierror.ErrNotFound
+		}
+		return &APIKey{}, err
+	}
+	err = db.Model(&UserGroup{}).Where("id = ?", k.GroupID).Take(&k.Group).Error
+	if err != nil {
+		return &APIKey{}, err
+	}
+	return k, nil
+}
+
+// RevokeAnAPIKey - Отзыв ключа доступа
+func (k *APIKey) RevokeAnAPIKey(db *gorm.DB, kid uint64) (int64, error) {
+	now := time.Now()
+	db = db.Model(&APIKey{}).Where("id = ?", kid).Take(&APIKey{}).UpdateColumns(
+		map[string]interface{}{
+			"revoked_at": now,
+			"updated_at": now,
+		},
+	)
+	if db.Error != nil {
+		if gorm.IsRecordNotFoundError(db.Error) {
+			return 0, apierror.ErrNotFound
+		}
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// APIKeyList - ключи доступа, хранящиеся в базе данных
+type APIKeyList struct {
+	DB *gorm.DB
+}
+
+// VerifyAPIKey - Проверка ключа доступа по префиксу и хэшу, возвращает ID пользователя и ID группы ключа
+func (l APIKeyList) VerifyAPIKey(prefix string, hash string) (uint64, uint64, error) {
+	key := APIKey{}
+	err := l.DB.Model(&APIKey{}).Where("prefix = ?", prefix).Take(&key).Error
+	if err != nil {
+		return 0, 0, apierror.ErrUnauthorized
+	}
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) != 1 || !key.IsActive() {
+		return 0, 0, apierror.ErrUnauthorized
+	}
+	err = l.DB.Model(&APIKey{}).Where("id = ?", key.ID).UpdateColumn("last_used_at", time.Now()).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	return key.UserID, key.GroupID, nil
+}
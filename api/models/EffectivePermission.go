@@ -0,0 +1,93 @@
+// Package models - пакет для описания моделей, которые используются для хранения данных
+package models
+
+import (
+	"github.com/doka-guide/api/api/utils/rbac"
+	"github.com/jinzhu/gorm"
+)
+
+// maxGroupDepth - ограничение глубины вложенности групп при поиске унаследованных разрешений
+const maxGroupDepth = 32
+
+// EffectivePermission - разрешение или запрет пользователя и путь групп, через который оно получено
+type EffectivePermission struct {
+	Name    string `json:"name"`
+	Deny    bool   `json:"deny"`
+	GroupID uint64 `json:"group_id"`
+	Path    string `json:"path"`
+}
+
+// findEffectivePermissions - Разрешения групп и всех их родительских групп. Путь начинается с группы,
+// в которую входит пользователь (или к которой привязан ключ доступа), и заканчивается группой, выдавшей разрешение
+func findEffectivePermissions(db *gorm.DB, start string, arg uint64) ([]EffectivePermission, error) {
+	result := []EffectivePermission{}
+	err := db.Raw(`WITH RECURSIVE group_tree AS (
+		SELECT user_groups.id, user_groups.parent_id, CAST(user_groups.name AS TEXT) AS path, 1 AS depth FROM user_groups WHERE `+start+`
+		UNION ALL
+		SELECT user_groups.id, user_groups.parent_id, group_tree.path || ' → ' || user_groups.name, group_tree.depth + 1
+		FROM user_groups JOIN group_tree ON user_groups.id = group_tree.parent_id WHERE group_tree.depth < ?
+	) SELECT permissions.name, group_permissions.deny, group_tree.id AS group_id, group_tree.path
+	FROM group_tree
+	JOIN group_permissions ON group_permissions.group_id = group_tree.id
+	JOIN permissions ON permissions.id = group_permissions.perms_id
+	ORDER BY permissions.name, group_tree.depth`, arg, maxGroupDepth).Scan(&result).Error
+	if err != nil {
+		return []EffectivePermission{}, err
+	}
+	return result, nil
+}
+
+// FindUserEffectivePermissions - Все разрешения и запреты пользователя с учётом наследования групп
+func FindUserEffectivePermissions(db *gorm.DB, uid uint64) ([]EffectivePermission, error) {
+	return findEffectivePermissions(db, "user_groups.id IN (SELECT group_id FROM grouped_users WHERE user_id = ?)", uid)
+}
+
+// toGrants - Правила проверки прав без сведений о группах
+func toGrants(permissions []EffectivePermission) []rbac.Grant {
+	grants := make([]rbac.Grant, 0, len(permissions))
+	seen := map[rbac.Grant]bool{}
+	for _, permission := range permissions {
+		grant := rbac.Grant{Pattern: permission.Name, Deny: permission.Deny}
+		if !seen[grant] {
+			seen[grant] = true
+			grants = append(grants, grant)
+		}
+	}
+	return grants
+}
+
+// FindUserGrants - Все разрешения и запреты пользователя через его группы и их родительские группы
+func FindUserGrants(db *gorm.DB, uid uint64) ([]rbac.Grant, error) {
+	permissions, err := FindUserEffectivePermissions(db, uid)
+	if err != nil {
+		return []rbac.Grant{}, err
+	}
+	return toGrants(permissions), nil
+}
+
+// FindGroupGrants - Все разрешения и запреты группы пользователей с учётом родительских групп
+func FindGroupGrants(db *gorm.DB, gid uint64) ([]rbac.Grant, error) {
+	permissions, err := findEffectivePermissions(db, "user_groups.id = ?", gid)
+	if err != nil {
+		return []rbac.Grant{}, err
+	}
+	return toGrants(permissions), nil
+}
+
+// UserHasPermission - Проверка, есть ли у пользователя разрешение через одну из его групп (запреты важнее разрешений)
+func UserHasPermission(db *gorm.DB, uid uint64, permName string) bool {
+	grants, err := FindUserGrants(db, uid)
+	if err != nil {
+		return false
+	}
+	return rbac.Allowed(grants, permName)
+}
+
+// GroupHasPermission - Проверка, есть ли разрешение у группы пользователей (запреты важнее разрешений)
+func GroupHasPermission(db *gorm.DB, gid uint64, permName string) bool {
+	grants, err := FindGroupGrants(db, gid)
+	if err != nil {
+		return false
+	}
+	return rbac.Allowed(grants, permName)
+}
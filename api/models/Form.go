@@ -2,12 +2,11 @@
 package models
 
 import (
-	"errors"
 	"html"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/jinzhu/gorm"
 )
 
@@ -35,13 +34,13 @@ func (p *Form) Prepare() {
 // Validate - Валидация формы
 func (p *Form) Validate() error {
 	if p.Type == "" {
-		return errors.New("Необходимо указать тип формы")
+		return apierror.Required("type")
 	}
 	if p.Data == "" {
-		return errors.New("Нужна дата и время отправки формы")
+		return apierror.Required("data")
 	}
 	if p.AuthorID < 1 {
-		return errors.New("Необходимо указать ID пользователя")
+		return apierror.Required("author_id")
 	}
 	return nil
 }
@@ -49,12 +48,12 @@ func (p *Form) Validate() error {
 // SaveForm - Сохранение формы
 func (p *Form) SaveForm(db *gorm.DB) (*Form, error) {
 	var err error
-	err = db.Debug().Model(&Form{}).Create(&p).Error
+	err = db.Model(&Form{}).Create(&p).Error
 	if err != nil {
 		return &Form{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
+		err = db.Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
 		if err != nil {
 			return &Form{}, err
 		}
@@ -62,34 +61,33 @@ func (p *Form) SaveForm(db *gorm.DB) (*Form, error) {
 	return p, nil
 }
 
-// FindAllForms - Вывод все формы (максимальное количество задаётся параметром GET_LIMIT)
-func (p *Form) FindAllForms(db *gorm.DB) (*[]Form, error) {
-	var err error
+// FindAllForms - Вывод все формы страницами с сортировкой и фильтрами из query
+func (p *Form) FindAllForms(db *gorm.DB, query ListQuery) (*[]Form, string, error) {
 	posts := []Form{}
-	err = db.Debug().Model(&Form{}).Order("id DESC").Limit(os.Getenv("GET_LIMIT")).Find(&posts).Error
+	next, err := Paginate(db.Model(&Form{}), FormListSpec, query, &posts)
 	if err != nil {
-		return &[]Form{}, err
+		return &[]Form{}, "", err
 	}
 	if len(posts) > 0 {
 		for i := range posts {
-			err := db.Debug().Model(&User{}).Where("id = ?", posts[i].AuthorID).Take(&posts[i].Author).Error
+			err := db.Model(&User{}).Where("id = ?", posts[i].AuthorID).Take(&posts[i].Author).Error
 			if err != nil {
-				return &[]Form{}, err
+				return &[]Form{}, "", err
 			}
 		}
 	}
-	return &posts, nil
+	return &posts, next, nil
 }
 
 // FindFormByID - Вывод данных формы с ID
 func (p *Form) FindFormByID(db *gorm.DB, pid uint64) (*Form, error) {
 	var err error
-	err = db.Debug().Model(&Form{}).Where("id = ?", pid).Take(&p).Error
+	err = db.Model(&Form{}).Where("id = ?", pid).Take(&p).Error
 	if err != nil {
 		return &Form{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
+		err = db.Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
 		if err != nil {
 			return &Form{}, err
 		}
@@ -100,12 +98,12 @@ func (p *Form) FindFormByID(db *gorm.DB, pid uint64) (*Form, error) {
 // UpdateAForm - Обновление формы
 func (p *Form) UpdateAForm(db *gorm.DB) (*Form, error) {
 	var err error
-	err = db.Debug().Model(&Form{}).Where("id = ?", p.ID).Updates(Form{Type: p.Type, Data: p.Data, UpdatedAt: time.Now()}).Error
+	err = db.Model(&Form{}).Where("id = ?", p.ID).Updates(Form{Type: p.Type, Data: p.Data, UpdatedAt: time.Now()}).Error
 	if err != nil {
 		return &Form{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
+		err = db.Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
 		if err != nil {
 			return &Form{}, err
 		}
@@ -115,10 +113,10 @@ func (p *Form) UpdateAForm(db *gorm.DB) (*Form, error) {
 
 // DeleteAForm - Удаление формы
 func (p *Form) DeleteAForm(db *gorm.DB, pid uint64, uid uint64) (int64, error) {
-	db = db.Debug().Model(&Form{}).Where("id = ? and author_id = ?", pid, uid).Take(&Form{}).Delete(&Form{})
+	db = db.Model(&Form{}).Where("id = ? and author_id = ?", pid, uid).Take(&Form{}).Delete(&Form{})
 	if db.Error != nil {
 		if gorm.IsRecordNotFoundError(db.Error) {
-			return 0, errors.New("Form not found")
+			return 0, apierror.ErrNotFound
 		}
 		return 0, db.Error
 	}
@@ -133,7 +131,7 @@ type FormsGroupedByDataResult struct {
 // FeedbackFormsGroupedByData - Вывод агрегированных данных по лайкам / замечаниям для материалов
 func (p *Form) FeedbackFormsGroupedByData(db *gorm.DB, start string, end string) *[]FormsGroupedByDataResult {
 	posts := []FormsGroupedByDataResult{}
-	db.Debug().Raw("SELECT data, count(data) FROM forms WHERE type = 'feedback' AND created_at >= ? AND created_at <= ? GROUP BY data", start, end).Scan(&posts)
+	db.Raw("SELECT data, count(data) FROM forms WHERE type = 'feedback' AND created_at >= ? AND created_at <= ? GROUP BY data", start, end).Scan(&posts)
 	return &posts
 }
 
@@ -144,6 +142,6 @@ type QuestionFormsResult struct {
 // QuestionForms - Вывод агрегированных данных по лайкам / замечаниям для материалов
 func (p *Form) QuestionForms(db *gorm.DB, start string, end string) *[]QuestionFormsResult {
 	posts := []QuestionFormsResult{}
-	db.Debug().Raw("SELECT data FROM forms WHERE type = 'question' AND created_at >= ? AND created_at <= ?", start, end).Scan(&posts)
+	db.Raw("SELECT data FROM forms WHERE type = 'question' AND created_at >= ? AND created_at <= ?", start, end).Scan(&posts)
 	return &posts
 }
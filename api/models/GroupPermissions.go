@@ -2,10 +2,9 @@
 package models
 
 import (
-	"errors"
-	"os"
 	"time"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/jinzhu/gorm"
 )
 
@@ -14,8 +13,9 @@ type GroupPermission struct {
 	ID        uint64     `gorm:"primary_key;auto_increment" json:"id"`
 	Group     UserGroup  `json:"group"`
 	Perms     Permission `json:"permission"`
-	GroupID   uint64     `gorm:"not null" json:"group_id"`
-	PermsID   uint64     `gorm:"not null" json:"perms_id"`
+	GroupID   uint64     `gorm:"not null;unique_index:idx_group_permissions_pair" json:"group_id"`
+	PermsID   uint64     `gorm:"not null;unique_index:idx_group_permissions_pair" json:"perms_id"`
+	Deny      bool       `gorm:"not null;default:false" json:"deny"`
 	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
@@ -32,7 +32,10 @@ func (p *GroupPermission) Prepare() {
 // Validate - Валидация на пару группа-разрешение
 func (p *GroupPermission) Validate() error {
 	if p.GroupID < 1 {
-		return errors.New("Необходимо указать ID пары группа-разрешение")
+		return apierror.Required("group_id")
+	}
+	if p.PermsID < 1 {
+		return apierror.Required("perms_id")
 	}
 	return nil
 }
@@ -40,12 +43,12 @@ func (p *GroupPermission) Validate() error {
 // SaveGroupPermission - Сохранение на пару группа-разрешение
 func (p *GroupPermission) SaveGroupPermission(db *gorm.DB) (*GroupPermission, error) {
 	var err error
-	err = db.Debug().Model(&GroupPermission{}).Create(&p).Error
+	err = db.Model(&GroupPermission{}).Create(&p).Error
 	if err != nil {
 		return &GroupPermission{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&UserGroup{}).Where("id = ?", p.GroupID).Take(&p.Group).Error
+		err = db.Model(&UserGroup{}).Where("id = ?", p.GroupID).Take(&p.Group).Error
 		if err != nil {
 			return &GroupPermission{}, err
 		}
@@ -57,13 +60,13 @@ func (p *GroupPermission) SaveGroupPermission(db *gorm.DB) (*GroupPermission, er
 func (p *GroupPermission) FindAllGroupPermission(db *gorm.DB) (*[]GroupPermission, error) {
 	var err error
 	posts := []GroupPermission{}
-	err = db.Debug().Model(&GroupPermission{}).Order("id DESC").Limit(os.Getenv("GET_LIMIT")).Find(&posts).Error
+	err = db.Model(&GroupPermission{}).Order("id DESC").Limit(getLimit).Find(&posts).Error
 	if err != nil {
 		return &[]GroupPermission{}, err
 	}
 	if len(posts) > 0 {
 		for i := range posts {
-			err := db.Debug().Model(&UserGroup{}).Where("id = ?", posts[i].GroupID).Take(&posts[i].Group).Error
+			err := db.Model(&UserGroup{}).Where("id = ?", posts[i].GroupID).Take(&posts[i].Group).Error
 			if err != nil {
 				return &[]GroupPermission{}, err
 			}
@@ -75,16 +78,16 @@ func (p *GroupPermission) FindAllGroupPermission(db *gorm.DB) (*[]GroupPermissio
 // FindGroupPermissionByID - Вывод данных пары группа-разрешение с ID
 func (p *GroupPermission) FindGroupPermissionByID(db *gorm.DB, id string) (*GroupPermission, error) {
 	var err error
-	err = db.Debug().Model(&GroupPermission{}).Where("id = ?", id).Take(&p).Error
+	err = db.Model(&GroupPermission{}).Where("id = ?", id).Take(&p).Error
 	if err != nil {
 		return &GroupPermission{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&UserGroup{}).Where("id = ?", p.GroupID).Take(&p.Group).Error
+		err = db.Model(&UserGroup{}).Where("id = ?", p.GroupID).Take(&p.Group).Error
 		if err != nil {
 			return &GroupPermission{}, err
 		}
-		err = db.Debug().Model(&Permission{}).Where("id = ?", p.PermsID).Take(&p.Perms).Error
+		err = db.Model(&Permission{}).Where("id = ?", p.PermsID).Take(&p.Perms).Error
 		if err != nil {
 			return &GroupPermission{}, err
 		}
@@ -94,12 +97,61 @@ func (p *GroupPermission) FindGroupPermissionByID(db *gorm.DB, id string) (*Grou
 
 // DeleteAGroupPermission - Удаление на пар группа-разрешение
 func (p *GroupPermission) DeleteAGroupPermission(db *gorm.DB, uid uint64) (int64, error) {
-	db = db.Debug().Model(&GroupPermission{}).Where("id = ?", uid).Take(&GroupPermission{}).Delete(&GroupPermission{})
+	db = db.Model(&GroupPermission{}).Where("id = ?", uid).Take(&GroupPermission{}).Delete(&GroupPermission{})
 	if db.Error != nil {
 		if gorm.IsRecordNotFoundError(db.Error) {
-			return 0, errors.New("GroupPermission not found")
+			return 0, apierror.ErrNotFound
+		}
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// FindAllGroupPermissionWithUserGroupID - Вывод всех разрешений группы пользователей
+func (p *GroupPermission) FindAllGroupPermissionWithUserGroupID(db *gorm.DB, id uint64, query ListQuery) (*[]GroupPermission, string, error) {
+	posts := []GroupPermission{}
+	next, err := Paginate(db.Model(&GroupPermission{}).Where("group_id = ?", id), GroupPermissionListSpec, query, &posts)
+	if err != nil {
+		return &[]GroupPermission{}, "", err
+	}
+	for i := range posts {
+		err = db.Model(&UserGroup{}).Where("id = ?", posts[i].GroupID).Take(&posts[i].Group).Error
+		if err != nil {
+			return &[]GroupPermission{}, "", err
+		}
+		err = db.Model(&Permission{}).Where("id = ?", posts[i].PermsID).Take(&posts[i].Perms).Error
+		if err != nil {
+			return &[]GroupPermission{}, "", err
 		}
+	}
+	return &posts, next, nil
+}
+
+// IsDuplicate - Проверка, есть ли уже у группы это разрешение
+func (p *GroupPermission) IsDuplicate(db *gorm.DB) bool {
+	var count int
+	db.Model(&GroupPermission{}).Where("group_id = ? AND perms_id = ?", p.GroupID, p.PermsID).Count(&count)
+	return count > 0
+}
+
+// DeleteAGroupPermissionByPair - Удаление разрешения у группы
+func (p *GroupPermission) DeleteAGroupPermissionByPair(db *gorm.DB, gid uint64, pid uint64) (int64, error) {
+	db = db.Where("group_id = ? AND perms_id = ?", gid, pid).Delete(&GroupPermission{})
+	if db.Error != nil {
 		return 0, db.Error
 	}
+	if db.RowsAffected == 0 {
+		return 0, apierror.ErrNotFound
+	}
 	return db.RowsAffected, nil
 }
+
+// AfterSave - Увеличение версии прав после сохранения пары группа-разрешение
+func (p *GroupPermission) AfterSave(tx *gorm.DB) error {
+	return BumpPermissionVersion(tx)
+}
+
+// AfterDelete - Увеличение версии прав после удаления пары группа-разрешение
+func (p *GroupPermission) AfterDelete(tx *gorm.DB) error {
+	return BumpPermissionVersion(tx)
+}
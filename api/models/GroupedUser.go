@@ -2,10 +2,9 @@
 package models
 
 import (
-	"errors"
-	"os"
 	"time"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/jinzhu/gorm"
 )
 
@@ -14,8 +13,8 @@ type GroupedUser struct {
 	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
 	Group     UserGroup `json:"group"`
 	User      User      `json:"user"`
-	GroupID   uint64    `gorm:"not null" json:"group_id"`
-	UserID    uint64    `gorm:"not null" json:"user_id"`
+	GroupID   uint64    `gorm:"not null;unique_index:idx_grouped_users_pair" json:"group_id"`
+	UserID    uint64    `gorm:"not null;unique_index:idx_grouped_users_pair" json:"user_id"`
 	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
@@ -32,7 +31,10 @@ func (p *GroupedUser) Prepare() {
 // Validate - Валидация пары группа-пользователей
 func (p *GroupedUser) Validate() error {
 	if p.GroupID < 1 {
-		return errors.New("Необходимо указать ID пары группа-пользователей")
+		return apierror.Required("group_id")
+	}
+	if p.UserID < 1 {
+		return apierror.Required("user_id")
 	}
 	return nil
 }
@@ -40,12 +42,12 @@ func (p *GroupedUser) Validate() error {
 // SaveGroupedUser - Сохранение пары группа-пользователей
 func (p *GroupedUser) SaveGroupedUser(db *gorm.DB) (*GroupedUser, error) {
 	var err error
-	err = db.Debug().Model(&GroupedUser{}).Create(&p).Error
+	err = db.Model(&GroupedUser{}).Create(&p).Error
 	if err != nil {
 		return &GroupedUser{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&UserGroup{}).Where("id = ?", p.GroupID).Take(&p.Group).Error
+		err = db.Model(&UserGroup{}).Where("id = ?", p.GroupID).Take(&p.Group).Error
 		if err != nil {
 			return &GroupedUser{}, err
 		}
@@ -57,13 +59,13 @@ func (p *GroupedUser) SaveGroupedUser(db *gorm.DB) (*GroupedUser, error) {
 func (p *GroupedUser) FindAllGroupedUser(db *gorm.DB) (*[]GroupedUser, error) {
 	var err error
 	posts := []GroupedUser{}
-	err = db.Debug().Model(&GroupedUser{}).Order("id DESC").Limit(os.Getenv("GET_LIMIT")).Find(&posts).Error
+	err = db.Model(&GroupedUser{}).Order("id DESC").Limit(getLimit).Find(&posts).Error
 	if err != nil {
 		return &[]GroupedUser{}, err
 	}
 	if len(posts) > 0 {
 		for i := range posts {
-			err := db.Debug().Model(&UserGroup{}).Where("id = ?", posts[i].GroupID).Take(&posts[i].Group).Error
+			err := db.Model(&UserGroup{}).Where("id = ?", posts[i].GroupID).Take(&posts[i].Group).Error
 			if err != nil {
 				return &[]GroupedUser{}, err
 			}
@@ -76,17 +78,17 @@ func (p *GroupedUser) FindAllGroupedUser(db *gorm.DB) (*[]GroupedUser, error) {
 func (p *GroupedUser) FindAllGroupedUserWithUserID(db *gorm.DB, id string) (*[]GroupedUser, error) {
 	var err error
 	posts := []GroupedUser{}
-	err = db.Debug().Model(&GroupedUser{}).Where("user_id = ?", id).Order("id DESC").Find(&posts).Error
+	err = db.Model(&GroupedUser{}).Where("user_id = ?", id).Order("id DESC").Find(&posts).Error
 	if err != nil {
 		return &[]GroupedUser{}, err
 	}
 	if len(posts) > 0 {
 		for i := range posts {
-			err := db.Debug().Model(&UserGroup{}).Where("id = ?", posts[i].GroupID).Take(&posts[i].Group).Error
+			err := db.Model(&UserGroup{}).Where("id = ?", posts[i].GroupID).Take(&posts[i].Group).Error
 			if err != nil {
 				return &[]GroupedUser{}, err
 			}
-			err = db.Debug().Model(&User{}).Where("id = ?", posts[i].UserID).Take(&posts[i].User).Error
+			err = db.Model(&User{}).Where("id = ?", posts[i].UserID).Take(&posts[i].User).Error
 			if err != nil {
 				return &[]GroupedUser{}, err
 			}
@@ -96,41 +98,40 @@ func (p *GroupedUser) FindAllGroupedUserWithUserID(db *gorm.DB, id string) (*[]G
 }
 
 // FindAllGroupedUserWithUserGroupID - Вывод всех пар группа-пользователей с определённым UserGroup ID
-func (p *GroupedUser) FindAllGroupedUserWithUserGroupID(db *gorm.DB, id string) (*[]GroupedUser, error) {
-	var err error
+func (p *GroupedUser) FindAllGroupedUserWithUserGroupID(db *gorm.DB, id string, query ListQuery) (*[]GroupedUser, string, error) {
 	posts := []GroupedUser{}
-	err = db.Debug().Model(&GroupedUser{}).Where("group_id = ?", id).Order("id DESC").Find(&posts).Error
+	next, err := Paginate(db.Model(&GroupedUser{}).Where("group_id = ?", id), GroupedUserListSpec, query, &posts)
 	if err != nil {
-		return &[]GroupedUser{}, err
+		return &[]GroupedUser{}, "", err
 	}
 	if len(posts) > 0 {
 		for i := range posts {
-			err := db.Debug().Model(&UserGroup{}).Where("id = ?", posts[i].GroupID).Take(&posts[i].Group).Error
+			err := db.Model(&UserGroup{}).Where("id = ?", posts[i].GroupID).Take(&posts[i].Group).Error
 			if err != nil {
-				return &[]GroupedUser{}, err
+				return &[]GroupedUser{}, "", err
 			}
-			err = db.Debug().Model(&User{}).Where("id = ?", posts[i].UserID).Take(&posts[i].User).Error
+			err = db.Model(&User{}).Where("id = ?", posts[i].UserID).Take(&posts[i].User).Error
 			if err != nil {
-				return &[]GroupedUser{}, err
+				return &[]GroupedUser{}, "", err
 			}
 		}
 	}
-	return &posts, nil
+	return &posts, next, nil
 }
 
 // FindGroupedUserByID - Вывод данных пары группа-пользователь с ID
 func (p *GroupedUser) FindGroupedUserByID(db *gorm.DB, id string) (*GroupedUser, error) {
 	var err error
-	err = db.Debug().Model(&GroupedUser{}).Where("id = ?", id).Take(&p).Error
+	err = db.Model(&GroupedUser{}).Where("id = ?", id).Take(&p).Error
 	if err != nil {
 		return &GroupedUser{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&UserGroup{}).Where("id = ?", p.GroupID).Take(&p.Group).Error
+		err = db.Model(&UserGroup{}).Where("id = ?", p.GroupID).Take(&p.Group).Error
 		if err != nil {
 			return &GroupedUser{}, err
 		}
-		err = db.Debug().Model(&User{}).Where("id = ?", p.UserID).Take(&p.User).Error
+		err = db.Model(&User{}).Where("id = ?", p.UserID).Take(&p.User).Error
 		if err != nil {
 			return &GroupedUser{}, err
 		}
@@ -140,12 +141,41 @@ func (p *GroupedUser) FindGroupedUserByID(db *gorm.DB, id string) (*GroupedUser,
 
 // DeleteAGroupedUser - Удаление пары группа-пользователей
 func (p *GroupedUser) DeleteAGroupedUser(db *gorm.DB, uid uint64) (int64, error) {
-	db = db.Debug().Model(&GroupedUser{}).Where("id = ?", uid).Take(&GroupedUser{}).Delete(&GroupedUser{})
+	db = db.Model(&GroupedUser{}).Where("id = ?", uid).Take(&GroupedUser{}).Delete(&GroupedUser{})
 	if db.Error != nil {
 		if gorm.IsRecordNotFoundError(db.Error) {
-			return 0, errors.New("GroupedUser not found")
+			return 0, apierror.ErrNotFound
 		}
 		return 0, db.Error
 	}
 	return db.RowsAffected, nil
 }
+
+// IsDuplicate - Проверка, состоит ли пользователь в группе
+func (p *GroupedUser) IsDuplicate(db *gorm.DB) bool {
+	var count int
+	db.Model(&GroupedUser{}).Where("group_id = ? AND user_id = ?", p.GroupID, p.UserID).Count(&count)
+	return count > 0
+}
+
+// DeleteAGroupedUserByPair - Удаление пользователя из группы
+func (p *GroupedUser) DeleteAGroupedUserByPair(db *gorm.DB, gid uint64, uid uint64) (int64, error) {
+	db = db.Where("group_id = ? AND user_id = ?", gid, uid).Delete(&GroupedUser{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	if db.RowsAffected == 0 {
+		return 0, apierror.ErrNotFound
+	}
+	return db.RowsAffected, nil
+}
+
+// AfterSave - Увеличение версии прав после сохранения пары группа-пользователей
+func (p *GroupedUser) AfterSave(tx *gorm.DB) error {
+	return BumpPermissionVersion(tx)
+}
+
+// AfterDelete - Увеличение версии прав после удаления пары группа-пользователей
+func (p *GroupedUser) AfterDelete(tx *gorm.DB) error {
+	return BumpPermissionVersion(tx)
+}
@@ -0,0 +1,12 @@
+// Package models - пакет для описания моделей, которые используются для хранения данных
+package models
+
+// getLimit - максимальное количество записей в выводе списков
+var getLimit = 1000
+
+// SetGetLimit - Установка максимального количества записей в выводе списков (параметр GET_LIMIT)
+func SetGetLimit(limit int) {
+	if limit > 0 {
+		getLimit = limit
+	}
+}
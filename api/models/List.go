@@ -0,0 +1,319 @@
+// Package models - пакет для описания моделей, которые используются для хранения данных
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/jinzhu/gorm"
+)
+
+// Виды фильтров списка
+const (
+	// FilterEqual - столбец равен строке из параметра
+	FilterEqual = "eq"
+	// FilterID - столбец равен целому числу из параметра
+	FilterID = "id"
+	// FilterAfter - время в столбце не раньше даты из параметра
+	FilterAfter = "after"
+	// FilterBefore - время в столбце раньше даты из параметра
+	FilterBefore = "before"
+)
+
+// Filter - фильтр списка по параметру запроса
+type Filter struct {
+	Column string
+	Kind   string
+	// Подзапрос с одним параметром: если задан, столбец ищется среди его результатов (column IN (подзапрос))
+	Subquery string
+}
+
+// ListSpec - поля для сортировки и фильтры списка модели; параметры вне этого списка не попадают в SQL
+type ListSpec struct {
+	// Поле для сортировки по умолчанию; "-" в начале - по убыванию
+	DefaultSort string
+	// Поля для сортировки (параметр sort) и их столбцы; столбцы не должны содержать NULL
+	Sort map[string]string
+	// Фильтры по названию параметра запроса
+	Filters map[string]Filter
+}
+
+// ListQuery - разобранные параметры запроса списка
+type ListQuery struct {
+	Limit   int
+	Sort    string
+	Filters map[string]interface{}
+	cursor  *listCursor
+}
+
+// listCursor - положение последней записи страницы; передаётся клиенту в base64
+type listCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    uint64 `json:"id"`
+}
+
+// Списки моделей
+var (
+	FormListSpec = ListSpec{
+		DefaultSort: "-id",
+		Sort:        map[string]string{"id": "id", "created_at": "created_at", "updated_at": "updated_at", "type": "type"},
+		Filters: map[string]Filter{
+			"type":           {Column: "type", Kind: FilterEqual},
+			"author_id":      {Column: "author_id", Kind: FilterID},
+			"created_after":  {Column: "created_at", Kind: FilterAfter},
+			"created_before": {Column: "created_at", Kind: FilterBefore},
+		},
+	}
+	SubscriptionListSpec = ListSpec{
+		DefaultSort: "-id",
+		Sort:        map[string]string{"id": "id", "created_at": "created_at", "updated_at": "updated_at", "email": "email"},
+		Filters: map[string]Filter{
+			"email":          {Column: "email", Kind: FilterEqual},
+			"author_id":      {Column: "author_id", Kind: FilterID},
+			"created_after":  {Column: "created_at", Kind: FilterAfter},
+			"created_before": {Column: "created_at", Kind: FilterBefore},
+		},
+	}
+	UserListSpec = ListSpec{
+		DefaultSort: "id",
+		Sort:        map[string]string{"id": "id", "created_at": "created_at", "nickname": "nickname", "email": "email"},
+		Filters: map[string]Filter{
+			"email":          {Column: "email", Kind: FilterEqual},
+			"nickname":       {Column: "nickname", Kind: FilterEqual},
+			"created_after":  {Column: "created_at", Kind: FilterAfter},
+			"created_before": {Column: "created_at", Kind: FilterBefore},
+		},
+	}
+	ProfileLinkListSpec = ListSpec{
+		DefaultSort: "-id",
+		Sort:        map[string]string{"id": "id", "created_at": "created_at"},
+		Filters: map[string]Filter{
+			"author_id":      {Column: "author_id", Kind: FilterID},
+			"profile_id":     {Column: "profile_id", Kind: FilterID},
+			"created_after":  {Column: "created_at", Kind: FilterAfter},
+			"created_before": {Column: "created_at", Kind: FilterBefore},
+		},
+	}
+	SubscriptionReportListSpec = ListSpec{
+		DefaultSort: "-id",
+		Sort:        map[string]string{"id": "id", "created_at": "created_at", "path": "path"},
+		Filters: map[string]Filter{
+			"path":           {Column: "path", Kind: FilterEqual},
+			"profile_id":     {Column: "profile_id", Kind: FilterID},
+			"author_id":      {Column: "profile_id", Kind: FilterID, Subquery: "SELECT id FROM subscriptions WHERE author_id = ?"},
+			"created_after":  {Column: "created_at", Kind: FilterAfter},
+			"created_before": {Column: "created_at", Kind: FilterBefore},
+		},
+	}
+	APIKeyListSpec = ListSpec{
+		DefaultSort: "-id",
+		Sort:        map[string]string{"id": "id", "created_at": "created_at", "name": "name"},
+		Filters: map[string]Filter{
+			"group_id":       {Column: "group_id", Kind: FilterID},
+			"user_id":        {Column: "user_id", Kind: FilterID},
+			"created_after":  {Column: "created_at", Kind: FilterAfter},
+			"created_before": {Column: "created_at", Kind: FilterBefore},
+		},
+	}
+	UserGroupListSpec = ListSpec{
+		DefaultSort: "id",
+		Sort:        map[string]string{"id": "id", "name": "name"},
+		Filters: map[string]Filter{
+			"parent_id": {Column: "parent_id", Kind: FilterID},
+		},
+	}
+	PermissionListSpec = ListSpec{
+		DefaultSort: "id",
+		Sort:        map[string]string{"id": "id", "name": "name"},
+		Filters: map[string]Filter{
+			"name": {Column: "name", Kind: FilterEqual},
+		},
+	}
+	GroupedUserListSpec = ListSpec{
+		DefaultSort: "-id",
+		Sort:        map[string]string{"id": "id", "created_at": "created_at"},
+		Filters: map[string]Filter{
+			"user_id": {Column: "user_id", Kind: FilterID},
+		},
+	}
+	GroupPermissionListSpec = ListSpec{
+		DefaultSort: "-id",
+		Sort:        map[string]string{"id": "id", "created_at": "created_at"},
+		Filters: map[string]Filter{
+			"perms_id": {Column: "perms_id", Kind: FilterID},
+		},
+	}
+)
+
+// ParseListQuery - Разбор параметров limit, cursor, sort и фильтров списка; ошибки во всех параметрах
+// возвращаются сразу. Без limit выводится GET_LIMIT записей, больше GET_LIMIT запросить нельзя
+func ParseListQuery(values url.Values, spec ListSpec) (ListQuery, error) {
+	query := ListQuery{Limit: getLimit, Sort: spec.DefaultSort, Filters: map[string]interface{}{}}
+	var fields []apierror.FieldError
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > getLimit {
+			fields = append(fields, apierror.Field("limit", apierror.FieldInvalid))
+		} else {
+			query.Limit = limit
+		}
+	}
+	if raw := values.Get("sort"); raw != "" {
+		if _, ok := spec.Sort[strings.TrimPrefix(raw, "-")]; ok {
+			query.Sort = raw
+		} else {
+			fields = append(fields, apierror.Field("sort", apierror.FieldInvalid))
+		}
+	}
+	if raw := values.Get("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil || cursor.Sort != query.Sort {
+			fields = append(fields, apierror.Field("cursor", apierror.FieldInvalid))
+		} else {
+			query.cursor = cursor
+		}
+	}
+
+	for _, name := range spec.filterNames() {
+		raw := values.Get(name)
+		if raw == "" {
+			continue
+		}
+		value, ok := parseFilter(spec.Filters[name].Kind, raw)
+		if !ok {
+			fields = append(fields, apierror.Field(name, apierror.FieldInvalid))
+			continue
+		}
+		query.Filters[name] = value
+	}
+
+	if len(fields) > 0 {
+		return query, apierror.Validation(fields...)
+	}
+	return query, nil
+}
+
+// filterNames - Названия фильтров по алфавиту, чтобы условия запроса всегда шли в одном порядке
+func (spec ListSpec) filterNames() []string {
+	names := make([]string, 0, len(spec.Filters))
+	for name := range spec.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseFilter - Значение фильтра нужного вида
+func parseFilter(kind, raw string) (interface{}, bool) {
+	switch kind {
+	case FilterID:
+		id, err := strconv.ParseUint(raw, 10, 64)
+		return id, err == nil
+	case FilterAfter, FilterBefore:
+		t, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339, raw)
+		}
+		return t, err == nil
+	}
+	return raw, true
+}
+
+// Paginate - Выборка страницы списка в rows (указатель на срез моделей) по фильтрам, сортировке и курсору
+// из query. Записи упорядочены по полю сортировки и ID, следующая страница начинается после последней
+// записи текущей. Возвращает курсор следующей страницы или пустую строку, если страница последняя
+func Paginate(db *gorm.DB, spec ListSpec, query ListQuery, rows interface{}) (string, error) {
+	column := spec.Sort[strings.TrimPrefix(query.Sort, "-")]
+	op, dir := ">", "ASC"
+	if strings.HasPrefix(query.Sort, "-") {
+		op, dir = "<", "DESC"
+	}
+
+	for _, name := range spec.filterNames() {
+		value, ok := query.Filters[name]
+		if !ok {
+			continue
+		}
+		filter := spec.Filters[name]
+		switch {
+		case filter.Subquery != "":
+			db = db.Where(filter.Column+" IN ("+filter.Subquery+")", value)
+		case filter.Kind == FilterAfter:
+			db = db.Where(filter.Column+" >= ?", value)
+		case filter.Kind == FilterBefore:
+			db = db.Where(filter.Column+" < ?", value)
+		default:
+			db = db.Where(filter.Column+" = ?", value)
+		}
+	}
+	if query.cursor != nil {
+		if column == "id" {
+			db = db.Where("id "+op+" ?", query.cursor.ID)
+		} else {
+			db = db.Where(column+" "+op+" ? OR ("+column+" = ? AND id "+op+" ?)", query.cursor.Value, query.cursor.Value, query.cursor.ID)
+		}
+	}
+	order := column + " " + dir
+	if column != "id" {
+		order += ", id " + dir
+	}
+
+	err := db.Order(order).Limit(query.Limit + 1).Find(rows).Error
+	if err != nil {
+		return "", err
+	}
+	slice := reflect.ValueOf(rows).Elem()
+	if slice.Len() <= query.Limit {
+		return "", nil
+	}
+	slice.Set(slice.Slice(0, query.Limit))
+
+	scope := db.NewScope(slice.Index(query.Limit - 1).Addr().Interface())
+	next := listCursor{Sort: query.Sort}
+	if field, ok := scope.FieldByName("id"); ok {
+		next.ID = field.Field.Uint()
+	}
+	if field, ok := scope.FieldByName(column); ok && column != "id" {
+		next.Value = cursorValue(field.Field.Interface())
+	}
+	return encodeCursor(next), nil
+}
+
+// cursorValue - Значение поля сортировки для курсора; время записывается с наносекундами, чтобы
+// сравнение в PostgreSQL не пропускало записи
+func cursorValue(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+// encodeCursor - Курсор в виде строки для параметра cursor
+func encodeCursor(cursor listCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor - Курсор из параметра cursor
+func decodeCursor(raw string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &listCursor{}
+	err = json.Unmarshal(data, cursor)
+	if err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
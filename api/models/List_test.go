@@ -0,0 +1,228 @@
+package models
+
+import (
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+
+	"github.com/doka-guide/api/api/apierror"
+
+	// Драйвер для работы с PostgreSQL
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// invalidFields – поля с ошибками из ошибки проверки параметров списка
+func invalidFields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	apiErr, ok := err.(*apierror.Error)
+	if !ok || apiErr.Code != apierror.CodeValidationFailed {
+		t.Fatalf("error = %v, want validation error", err)
+	}
+	fields := []string{}
+	for _, field := range apiErr.Fields {
+		if field.Code != apierror.FieldInvalid {
+			t.Errorf("field %s code = %s, want %s", field.Field, field.Code, apierror.FieldInvalid)
+		}
+		fields = append(fields, field.Field)
+	}
+	return fields
+}
+
+func TestParseListQueryLimit(t *testing.T) {
+	previous := getLimit
+	getLimit = 100
+	t.Cleanup(func() { getLimit = previous })
+
+	tests := []struct {
+		raw     string
+		want    int
+		invalid bool
+	}{
+		{"", 100, false},
+		{"1", 1, false},
+		{"50", 50, false},
+		{"100", 100, false},
+		{"101", 100, true},
+		{"0", 100, true},
+		{"-1", 100, true},
+		{"ten", 100, true},
+	}
+	for _, tt := range tests {
+		query, err := ParseListQuery(url.Values{"limit": {tt.raw}}, FormListSpec)
+		fields := invalidFields(t, err)
+		if tt.invalid != reflect.DeepEqual(fields, []string{"limit"}) {
+			t.Errorf("limit=%q: invalid fields %v", tt.raw, fields)
+		}
+		if query.Limit != tt.want {
+			t.Errorf("limit=%q: Limit = %d, want %d", tt.raw, query.Limit, tt.want)
+		}
+	}
+}
+
+func TestParseListQuerySort(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		invalid bool
+	}{
+		{"", "-id", false},
+		{"created_at", "created_at", false},
+		{"-created_at", "-created_at", false},
+		{"type", "type", false},
+		{"password", "-id", true},
+		{"-author_id", "-id", true},
+		{"id; DROP TABLE forms", "-id", true},
+	}
+	for _, tt := range tests {
+		query, err := ParseListQuery(url.Values{"sort": {tt.raw}}, FormListSpec)
+		fields := invalidFields(t, err)
+		if tt.invalid != reflect.DeepEqual(fields, []string{"sort"}) {
+			t.Errorf("sort=%q: invalid fields %v", tt.raw, fields)
+		}
+		if query.Sort != tt.want {
+			t.Errorf("sort=%q: Sort = %q, want %q", tt.raw, query.Sort, tt.want)
+		}
+	}
+}
+
+func TestParseListQueryFilters(t *testing.T) {
+	tests := []struct {
+		title   string
+		values  url.Values
+		want    map[string]interface{}
+		invalid []string
+	}{
+		{"no filters", url.Values{}, map[string]interface{}{}, nil},
+		{"id filter", url.Values{"author_id": {"7"}}, map[string]interface{}{"author_id": uint64(7)}, nil},
+		{"equal filter", url.Values{"type": {"feedback"}}, map[string]interface{}{"type": "feedback"}, nil},
+		{"date filter", url.Values{"created_after": {"2024-01-02"}},
+			map[string]interface{}{"created_after": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}, nil},
+		{"unknown parameter ignored", url.Values{"password": {"x"}}, map[string]interface{}{}, nil},
+		{"bad id", url.Values{"author_id": {"seven"}}, map[string]interface{}{}, []string{"author_id"}},
+		{"negative id", url.Values{"author_id": {"-7"}}, map[string]interface{}{}, []string{"author_id"}},
+		{"bad date", url.Values{"created_before": {"yesterday"}}, map[string]interface{}{}, []string{"created_before"}},
+		{"all errors at once", url.Values{"limit": {"0"}, "sort": {"x"}, "author_id": {"x"}, "created_after": {"x"}},
+			map[string]interface{}{}, []string{"limit", "sort", "author_id", "created_after"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			query, err := ParseListQuery(tt.values, FormListSpec)
+			if fields := invalidFields(t, err); !reflect.DeepEqual(fields, tt.invalid) {
+				t.Errorf("invalid fields = %v, want %v", fields, tt.invalid)
+			}
+			if !reflect.DeepEqual(query.Filters, tt.want) {
+				t.Errorf("Filters = %#v, want %#v", query.Filters, tt.want)
+			}
+		})
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	cursor := listCursor{Sort: "-created_at", Value: "2024-01-02T03:04:05.123456789Z", ID: 42}
+	raw := encodeCursor(cursor)
+
+	query, err := ParseListQuery(url.Values{"sort": {"-created_at"}, "cursor": {raw}}, FormListSpec)
+	if err != nil {
+		t.Fatalf("ParseListQuery: %v", err)
+	}
+	if query.cursor == nil || *query.cursor != cursor {
+		t.Fatalf("cursor = %+v, want %+v", query.cursor, cursor)
+	}
+
+	tampered := []byte(raw)
+	tampered[len(tampered)/2] ^= 0x20
+	tests := []struct {
+		title string
+		raw   string
+		sort  string
+	}{
+		{"other sort", raw, "created_at"},
+		{"default sort", raw, ""},
+		{"changed byte", string(tampered), "-created_at"},
+		{"truncated", raw[:len(raw)-4], "-created_at"},
+		{"not base64", "!!!" + raw, "-created_at"},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("id=42")), "-created_at"},
+		{"standard base64", base64.StdEncoding.EncodeToString([]byte(`{"s":"-created_at","id":42}`)) + "==", "-created_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			values := url.Values{"cursor": {tt.raw}}
+			if tt.sort != "" {
+				values.Set("sort", tt.sort)
+			}
+			query, err := ParseListQuery(values, FormListSpec)
+			if fields := invalidFields(t, err); !reflect.DeepEqual(fields, []string{"cursor"}) {
+				t.Errorf("invalid fields = %v, want [cursor]", fields)
+			}
+			if query.cursor != nil {
+				t.Errorf("cursor = %+v, want nil", query.cursor)
+			}
+		})
+	}
+}
+
+func TestCursorValue(t *testing.T) {
+	moment := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.FixedZone("MSK", 3*60*60))
+	if got := cursorValue(moment); got != "2024-01-02T00:04:05.123456789Z" {
+		t.Errorf("cursorValue(time) = %q", got)
+	}
+	if got := cursorValue("feedback"); got != "feedback" {
+		t.Errorf("cursorValue(string) = %q", got)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open("postgres", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.LogMode(false)
+	defer db.Close()
+
+	query, err := ParseListQuery(url.Values{"limit": {"2"}, "sort": {"type"}, "author_id": {"7"}}, FormListSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "forms" WHERE \(author_id = \$1\) ORDER BY type ASC, id ASC LIMIT 3`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(3, "feedback").AddRow(1, "question").AddRow(2, "question"))
+	forms := []Form{}
+	next, err := Paginate(db.Model(&Form{}), FormListSpec, query, &forms)
+	if err != nil {
+		t.Fatalf("Paginate: %v", err)
+	}
+	if len(forms) != 2 {
+		t.Fatalf("page has %d rows, want 2", len(forms))
+	}
+
+	// Следующая страница начинается после последней записи текущей
+	query, err = ParseListQuery(url.Values{"limit": {"2"}, "sort": {"type"}, "author_id": {"7"}, "cursor": {next}}, FormListSpec)
+	if err != nil {
+		t.Fatalf("next cursor %q: %v", next, err)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "forms" WHERE \(author_id = \$1\) AND \(type > \$2 OR \(type = \$3 AND id > \$4\)\) ORDER BY type ASC, id ASC LIMIT 3`).
+		WithArgs(7, "question", "question", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(2, "question"))
+	next, err = Paginate(db.Model(&Form{}), FormListSpec, query, &forms)
+	if err != nil {
+		t.Fatalf("Paginate: %v", err)
+	}
+	if next != "" || len(forms) != 1 {
+		t.Errorf("last page: next = %q, rows = %d", next, len(forms))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
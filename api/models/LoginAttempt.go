@@ -0,0 +1,71 @@
+// Package models - пакет для описания моделей, которые используются для хранения данных
+package models
+
+import (
+	"time"
+
+	"github.com/doka-guide/api/api/utils/throttle"
+	"github.com/jinzhu/gorm"
+)
+
+// LoginAttempt - счётчик неудачных попыток входа для учётной записи или IP-адреса
+type LoginAttempt struct {
+	ID            uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	Subject       string     `gorm:"size:255;not null;unique" json:"subject"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	CreatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// LoginAttemptStore - хранилище счётчиков неудачных попыток входа в PostgreSQL (общее для всех экземпляров API)
+type LoginAttemptStore struct {
+	DB *gorm.DB
+}
+
+// Get - Текущее состояние счётчика
+func (s LoginAttemptStore) Get(key string) (throttle.Attempts, error) {
+	attempt := LoginAttempt{}
+	err := s.DB.Model(&LoginAttempt{}).Where("subject = ?", key).Take(&attempt).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return throttle.Attempts{}, nil
+		}
+		return throttle.Attempts{}, err
+	}
+	return attempt.toAttempts(), nil
+}
+
+// Increment - Учёт неудачной попытки (атомарно, чтобы параллельные запросы не терялись)
+func (s LoginAttemptStore) Increment(key string, now time.Time) (throttle.Attempts, error) {
+	err := s.DB.Exec("INSERT INTO login_attempts (subject, failures, last_failure_at, created_at, updated_at) VALUES (?, 1, ?, ?, ?) ON CONFLICT (subject) DO UPDATE SET failures = login_attempts.failures + 1, last_failure_at = EXCLUDED.last_failure_at, updated_at = EXCLUDED.updated_at", key, now, now, now).Error
+	if err != nil {
+		return throttle.Attempts{}, err
+	}
+	return s.Get(key)
+}
+
+// Lock - Блокировка до момента until
+func (s LoginAttemptStore) Lock(key string, until time.Time) error {
+	return s.DB.Model(&LoginAttempt{}).Where("subject = ?", key).UpdateColumns(
+		map[string]interface{}{
+			"locked_until": until,
+			"updated_at":   time.Now(),
+		},
+	).Error
+}
+
+// Reset - Сброс счётчика и блокировки
+func (s LoginAttemptStore) Reset(key string) error {
+	return s.DB.Where("subject = ?", key).Delete(&LoginAttempt{}).Error
+}
+
+// toAttempts - Преобразование записи в состояние счётчика
+func (a *LoginAttempt) toAttempts() throttle.Attempts {
+	attempts := throttle.Attempts{Failures: a.Failures, LastFailure: a.LastFailureAt}
+	if a.LockedUntil != nil {
+		attempts.LockedUntil = *a.LockedUntil
+	}
+	return attempts
+}
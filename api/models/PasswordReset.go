@@ -0,0 +1,99 @@
+// Package models - пакет для описания моделей, которые используются для хранения данных
+package models
+
+import (
+	"time"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/jinzhu/gorm"
+)
+
+// PasswordReset - одноразовый токен для сброса пароля пользователя
+type PasswordReset struct {
+	ID        uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	Hash      string     `gorm:"size:64;not null;unique" json:"-"`
+	UserID    uint64     `gorm:"not null;index" json:"user_id"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// Prepare - Подготовка токена сброса пароля
+func (p *PasswordReset) Prepare() {
+	p.ID = 0
+	p.UsedAt = nil
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = time.Now()
+}
+
+// Validate - Валидация токена сброса пароля
+func (p *PasswordReset) Validate() error {
+	if p.Hash == "" {
+		return apierror.Required("hash")
+	}
+	if p.UserID < 1 {
+		return apierror.Required("user_id")
+	}
+	return nil
+}
+
+// IsActive - Проверка, что токен сброса пароля не использован и не просрочен
+func (p *PasswordReset) IsActive() bool {
+	return p.UsedAt == nil && time.Now().Before(p.ExpiresAt)
+}
+
+// SavePasswordReset - Сохранение токена сброса пароля (ранее выданные неиспользованные токены пользователя становятся недействительными)
+func (p *PasswordReset) SavePasswordReset(db *gorm.DB) (*PasswordReset, error) {
+	var err = p.UseAllUserPasswordResets(db, p.UserID)
+	if err != nil {
+		return &PasswordReset{}, err
+	}
+	err = db.Create(&p).Error
+	if err != nil {
+		return &PasswordReset{}, err
+	}
+	return p, nil
+}
+
+// FindPasswordResetByHash - Поиск токена сброса пароля по хэшу
+func (p *PasswordReset) FindPasswordResetByHash(db *gorm.DB, hash string) (*PasswordReset, error) {
+	var err = db.Model(&PasswordReset{}).Where("hash = ?", hash).Take(&p).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return &PasswordReset{}, apierror.ErrNotFound
+		}
+		return &PasswordReset{}, err
+	}
+	return p, nil
+}
+
+// UseAPasswordReset - Отметка об использовании токена сброса пароля
+func (p *PasswordReset) UseAPasswordReset(db *gorm.DB) error {
+	now := time.Now()
+	db = db.Model(&PasswordReset{}).Where("id = ? AND used_at IS NULL", p.ID).UpdateColumns(
+		map[string]interface{}{
+			"used_at":    now,
+			"updated_at": now,
+		},
+	)
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected == 0 {
+		return apierror.ErrTokenInvalid
+	}
+	p.UsedAt = &now
+	return nil
+}
+
+// UseAllUserPasswordResets - Отметка об использовании всех неиспользованных токенов сброса пароля пользователя
+func (p *PasswordReset) UseAllUserPasswordResets(db *gorm.DB, uid uint64) error {
+	now := time.Now()
+	return db.Model(&PasswordReset{}).Where("user_id = ? AND used_at IS NULL", uid).UpdateColumns(
+		map[string]interface{}{
+			"used_at":    now,
+			"updated_at": now,
+		},
+	).Error
+}
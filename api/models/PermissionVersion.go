@@ -0,0 +1,88 @@
+// Package models - пакет для описания моделей, которые используются для хранения данных
+package models
+
+import (
+	"sync"
+	"time"
+
+	"github.com/doka-guide/api/api/utils/rbac"
+	"github.com/jinzhu/gorm"
+)
+
+// PermissionVersion - счётчик изменений прав доступа (в таблице одна строка)
+type PermissionVersion struct {
+	ID        uint64    `gorm:"primary_key" json:"id"`
+	Version   int64     `gorm:"not null;default:0" json:"version"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// permissionVersionCache - последняя прочитанная версия прав, чтобы не обращаться к базе данных на каждый запрос
+var permissionVersionCache struct {
+	sync.Mutex
+	version   int64
+	checkedAt time.Time
+}
+
+// BumpPermissionVersion - Увеличение версии прав: токены со старой версией перестают проверяться по разрешениям в них
+func BumpPermissionVersion(db *gorm.DB) error {
+	err := db.Exec("INSERT INTO permission_versions (id, version, updated_at) VALUES (1, 1, ?) ON CONFLICT (id) DO UPDATE SET version = permission_versions.version + 1, updated_at = EXCLUDED.updated_at", time.Now()).Error
+	permissionVersionCache.Lock()
+	permissionVersionCache.checkedAt = time.Time{}
+	permissionVersionCache.Unlock()
+	return err
+}
+
+// CurrentPermissionVersion - Текущая версия прав из базы данных
+func CurrentPermissionVersion(db *gorm.DB) (int64, error) {
+	current := PermissionVersion{}
+	err := db.Model(&PermissionVersion{}).Where("id = 1").Take(&current).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return 0, err
+	}
+	return current.Version, nil
+}
+
+// PermissionStore - хранилище прав доступа в базе данных с кэшированием версии прав на время TTL
+type PermissionStore struct {
+	DB  *gorm.DB
+	TTL time.Duration
+}
+
+// PermissionVersion - Текущая версия прав (при ошибке базы данных возвращается -1, и права проверяются по базе)
+func (s PermissionStore) PermissionVersion() int64 {
+	permissionVersionCache.Lock()
+	defer permissionVersionCache.Unlock()
+	if time.Since(permissionVersionCache.checkedAt) < s.TTL {
+		return permissionVersionCache.version
+	}
+	version, err := CurrentPermissionVersion(s.DB)
+	if err != nil {
+		return -1
+	}
+	permissionVersionCache.version = version
+	permissionVersionCache.checkedAt = time.Now()
+	return version
+}
+
+// UserPermissions - Разрешения и запреты пользователя и версия прав, прочитанная до них
+func (s PermissionStore) UserPermissions(uid uint64) ([]rbac.Grant, int64, error) {
+	version, err := CurrentPermissionVersion(s.DB)
+	if err != nil {
+		return []rbac.Grant{}, 0, err
+	}
+	grants, err := FindUserGrants(s.DB, uid)
+	if err != nil {
+		return []rbac.Grant{}, 0, err
+	}
+	return grants, version, nil
+}
+
+// UserHasPermission - Проверка разрешения пользователя
+func (s PermissionStore) UserHasPermission(uid uint64, permName string) bool {
+	return UserHasPermission(s.DB, uid, permName)
+}
+
+// GroupHasPermission - Проверка разрешения группы
+func (s PermissionStore) GroupHasPermission(gid uint64, permName string) bool {
+	return GroupHasPermission(s.DB, gid, permName)
+}
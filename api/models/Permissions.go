@@ -2,19 +2,18 @@
 package models
 
 import (
-	"errors"
 	"html"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/jinzhu/gorm"
 )
 
 // Permission — произвольные разрешения
 type Permission struct {
 	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
-	Name      string    `gorm:"size:255;not null;unique" json:"nickname"`
+	Name      string    `gorm:"size:255;not null;unique" json:"name"`
 	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
@@ -27,51 +26,63 @@ func (u *Permission) Prepare() {
 	u.UpdatedAt = time.Now()
 }
 
+// Validate - Валидация информации о произвольных разрешениях
+func (u *Permission) Validate() error {
+	if u.Name == "" {
+		return apierror.Required("name")
+	}
+	return nil
+}
+
 // SavePermission - Сохранение информации о произвольных разрешениях
 func (u *Permission) SavePermission(db *gorm.DB) (*Permission, error) {
-	var err = db.Debug().Create(&u).Error
+	var err = db.Create(&u).Error
 	if err != nil {
 		return &Permission{}, err
 	}
 	return u, nil
 }
 
-// FindAllPermissions - Вывод всех произвольных разрешений (максимальное количество задаётся параметром GET_LIMIT)
-func (u *Permission) FindAllPermissions(db *gorm.DB) (*[]Permission, error) {
-	var err error
+// FindAllPermissions - Вывод всех произвольных разрешений страницами с сортировкой и фильтрами из query
+func (u *Permission) FindAllPermissions(db *gorm.DB, query ListQuery) (*[]Permission, string, error) {
 	users := []Permission{}
-	err = db.Debug().Model(&Permission{}).Limit(os.Getenv("GET_LIMIT")).Find(&users).Error
+	next, err := Paginate(db.Model(&Permission{}), PermissionListSpec, query, &users)
 	if err != nil {
-		return &[]Permission{}, err
+		return &[]Permission{}, "", err
 	}
-	return &users, err
+	return &users, next, nil
 }
 
 // FindPermissionByID - Вывод информации о произвольных разрешениях с ID
 func (u *Permission) FindPermissionByID(db *gorm.DB, uid uint64) (*Permission, error) {
-	var err = db.Debug().Model(User{}).Where("id = ?", uid).Take(&u).Error
+	var err = db.Model(&Permission{}).Where("id = ?", uid).Take(&u).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &Permission{}, apierror.ErrNotFound
+	}
 	if err != nil {
 		return &Permission{}, err
 	}
-	if gorm.IsRecordNotFoundError(err) {
-		return &Permission{}, errors.New("Permission Not Found")
-	}
-	return u, err
+	return u, nil
 }
 
 // UpdateAPermission - Обновление информации о произвольных разрешениях
 func (u *Permission) UpdateAPermission(db *gorm.DB, uid uint64) (*Permission, error) {
-	db = db.Debug().Model(&Permission{}).Where("id = ?", uid).Take(&Permission{}).UpdateColumns(
+	db = db.Model(&Permission{}).Where("id = ?", uid).Take(&Permission{}).UpdateColumns(
 		map[string]interface{}{
-			"name":      u.Name,
-			"update_at": time.Now(),
+			"name":       u.Name,
+			"updated_at": time.Now(),
 		},
 	)
 	if db.Error != nil {
 		return &Permission{}, db.Error
 	}
+	// UpdateColumns не вызывает AfterSave, а переименование меняет права в выданных токенах
+	err := BumpPermissionVersion(db)
+	if err != nil {
+		return &Permission{}, err
+	}
 	// Вывод обновленной информации о произвольных разрешениях
-	err := db.Debug().Model(&Permission{}).Where("id = ?", uid).Take(&u).Error
+	err = db.Model(&Permission{}).Where("id = ?", uid).Take(&u).Error
 	if err != nil {
 		return &Permission{}, err
 	}
@@ -80,9 +91,19 @@ func (u *Permission) UpdateAPermission(db *gorm.DB, uid uint64) (*Permission, er
 
 // DeleteAPermission - Удаление произвольных разрешений
 func (u *Permission) DeleteAPermission(db *gorm.DB, uid uint64) (int64, error) {
-	db = db.Debug().Model(&Permission{}).Where("id = ?", uid).Take(&Permission{}).Delete(&Permission{})
+	db = db.Model(&Permission{}).Where("id = ?", uid).Take(&Permission{}).Delete(&Permission{})
 	if db.Error != nil {
 		return 0, db.Error
 	}
 	return db.RowsAffected, nil
 }
+
+// AfterSave - Увеличение версии прав после сохранения разрешения
+func (u *Permission) AfterSave(tx *gorm.DB) error {
+	return BumpPermissionVersion(tx)
+}
+
+// AfterDelete - Увеличение версии прав после удаления разрешения
+func (u *Permission) AfterDelete(tx *gorm.DB) error {
+	return BumpPermissionVersion(tx)
+}
@@ -3,13 +3,12 @@ package models
 
 import (
 	"crypto/sha256"
-	"errors"
 	"fmt"
 	"html"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/doka-guide/api/api/utils/randomize"
 	"github.com/jinzhu/gorm"
 )
@@ -45,10 +44,10 @@ func (p *ProfileLink) Prepare() {
 // Validate - Валидация ссылок на профили подписчиков
 func (p *ProfileLink) Validate() error {
 	if p.Hash == "" {
-		return errors.New("Необходимо сгенерировать хэш для ссылки")
+		return apierror.Required("hash")
 	}
 	if p.AuthorID < 1 {
-		return errors.New("Необходимо указать ID пользователя")
+		return apierror.Required("author_id")
 	}
 	return nil
 }
@@ -56,12 +55,12 @@ func (p *ProfileLink) Validate() error {
 // SaveProfileLink - Сохранение ссылок на профили подписчиков
 func (p *ProfileLink) SaveProfileLink(db *gorm.DB) (*ProfileLink, error) {
 	var err error
-	err = db.Debug().Model(&ProfileLink{}).Create(&p).Error
+	err = db.Model(&ProfileLink{}).Create(&p).Error
 	if err != nil {
 		return &ProfileLink{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
+		err = db.Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
 		if err != nil {
 			return &ProfileLink{}, err
 		}
@@ -69,38 +68,37 @@ func (p *ProfileLink) SaveProfileLink(db *gorm.DB) (*ProfileLink, error) {
 	return p, nil
 }
 
-// FindAllProfileLinks - Вывод всех ссылок  на профили подписчиков (максимальное количество задаётся параметром GET_LIMIT)
-func (p *ProfileLink) FindAllProfileLinks(db *gorm.DB) (*[]ProfileLink, error) {
-	var err error
+// FindAllProfileLinks - Вывод всех ссылок  на профили подписчиков страницами с сортировкой и фильтрами из query
+func (p *ProfileLink) FindAllProfileLinks(db *gorm.DB, query ListQuery) (*[]ProfileLink, string, error) {
 	posts := []ProfileLink{}
-	err = db.Debug().Model(&ProfileLink{}).Order("id DESC").Limit(os.Getenv("GET_LIMIT")).Find(&posts).Error
+	next, err := Paginate(db.Model(&ProfileLink{}), ProfileLinkListSpec, query, &posts)
 	if err != nil {
-		return &[]ProfileLink{}, err
+		return &[]ProfileLink{}, "", err
 	}
 	if len(posts) > 0 {
 		for i := range posts {
-			err := db.Debug().Model(&User{}).Where("id = ?", posts[i].AuthorID).Take(&posts[i].Author).Error
+			err := db.Model(&User{}).Where("id = ?", posts[i].AuthorID).Take(&posts[i].Author).Error
 			if err != nil {
-				return &[]ProfileLink{}, err
+				return &[]ProfileLink{}, "", err
 			}
 		}
 	}
-	return &posts, nil
+	return &posts, next, nil
 }
 
 // FindProfileLinkByHash - Вывод данных ссылки на профиль подписчика с Hash
 func (p *ProfileLink) FindProfileLinkByHash(db *gorm.DB, hash string) (*ProfileLink, error) {
 	var err error
-	err = db.Debug().Model(&ProfileLink{}).Where("hash = ?", hash).Take(&p).Error
+	err = db.Model(&ProfileLink{}).Where("hash = ?", hash).Take(&p).Error
 	if err != nil {
 		return &ProfileLink{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
+		err = db.Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
 		if err != nil {
 			return &ProfileLink{}, err
 		}
-		err = db.Debug().Model(&Subscription{}).Where("id = ?", p.ProfileID).Take(&p.Profile).Error
+		err = db.Model(&Subscription{}).Where("id = ?", p.ProfileID).Take(&p.Profile).Error
 		if err != nil {
 			return &ProfileLink{}, err
 		}
@@ -110,10 +108,10 @@ func (p *ProfileLink) FindProfileLinkByHash(db *gorm.DB, hash string) (*ProfileL
 
 // DeleteAProfileLink - Удаление ссылок на профили подписчиков
 func (p *ProfileLink) DeleteAProfileLink(db *gorm.DB, pid uint64, uid uint64) (int64, error) {
-	db = db.Debug().Model(&ProfileLink{}).Where("id = ? and author_id = ?", pid, uid).Take(&ProfileLink{}).Delete(&ProfileLink{})
+	db = db.Model(&ProfileLink{}).Where("id = ? and author_id = ?", pid, uid).Take(&ProfileLink{}).Delete(&ProfileLink{})
 	if db.Error != nil {
 		if gorm.IsRecordNotFoundError(db.Error) {
-			return 0, errors.New("ProfileLink not found")
+			return 0, apierror.ErrNotFound
 		}
 		return 0, db.Error
 	}
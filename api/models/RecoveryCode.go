@@ -0,0 +1,62 @@
+// Package models - пакет для описания моделей, которые используются для хранения данных
+package models
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/jinzhu/gorm"
+)
+
+// RecoveryCode - одноразовый код восстановления для входа без приложения-аутентификатора
+type RecoveryCode struct {
+	ID        uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	Hash      string     `gorm:"size:64;not null" json:"-"`
+	UserID    uint64     `gorm:"not null;index" json:"user_id"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// ReplaceUserRecoveryCodes - Замена всех кодов восстановления пользователя новыми (передаются хэши кодов)
+func (c *RecoveryCode) ReplaceUserRecoveryCodes(db *gorm.DB, uid uint64, hashes []string) error {
+	tx := db.Begin()
+	err := tx.Where("user_id = ?", uid).Delete(&RecoveryCode{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, hash := range hashes {
+		code := RecoveryCode{Hash: hash, UserID: uid, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		err = tx.Create(&code).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// UseARecoveryCode - Использование кода восстановления пользователя (код с хэшем hash действует один раз)
+func (c *RecoveryCode) UseARecoveryCode(db *gorm.DB, uid uint64, hash string) error {
+	now := time.Now()
+	db = db.Model(&RecoveryCode{}).Where("user_id = ? AND hash = ? AND used_at IS NULL", uid, hash).UpdateColumns(
+		map[string]interface{}{
+			"used_at":    now,
+			"updated_at": now,
+		},
+	)
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected == 0 {
+		return apierror.New(apierror.CodeMFACodeInvalid, http.StatusUnprocessableEntity)
+	}
+	return nil
+}
+
+// DeleteAllUserRecoveryCodes - Удаление всех кодов восстановления пользователя
+func (c *RecoveryCode) DeleteAllUserRecoveryCodes(db *gorm.DB, uid uint64) error {
+	return db.Where("user_id = ?", uid).Delete(&RecoveryCode{}).Error
+}
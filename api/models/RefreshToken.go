@@ -0,0 +1,118 @@
+// Package models - пакет для описания моделей, которые используются для хранения данных
+package models
+
+import (
+	"time"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/jinzhu/gorm"
+)
+
+// RefreshToken - токен обновления, по которому выдаётся новый токен доступа
+type RefreshToken struct {
+	ID           uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	Hash         string     `gorm:"size:64;not null;unique" json:"-"`
+	UserID       uint64     `gorm:"not null;index" json:"user_id"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID uint64     `json:"replaced_by_id"`
+	CreatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// Prepare - Подготовка токена обновления
+func (t *RefreshToken) Prepare() {
+	t.ID = 0
+	t.RevokedAt = nil
+	t.ReplacedByID = 0
+	t.CreatedAt = time.Now()
+	t.UpdatedAt = time.Now()
+}
+
+// Validate - Валидация токена обновления
+func (t *RefreshToken) Validate() error {
+	if t.Hash == "" {
+		return apierror.Required("hash")
+	}
+	if t.UserID < 1 {
+		return apierror.Required("user_id")
+	}
+	return nil
+}
+
+// IsActive - Проверка, что токен обновления не отозван и не просрочен
+func (t *RefreshToken) IsActive() bool {
+	return t.RevokedAt == nil && time.Now().Before(t.ExpiresAt)
+}
+
+// SaveRefreshToken - Сохранение токена обновления
+func (t *RefreshToken) SaveRefreshToken(db *gorm.DB) (*RefreshToken, error) {
+	var err = db.Create(&t).Error
+	if err != nil {
+		return &RefreshToken{}, err
+	}
+	return t, nil
+}
+
+// FindRefreshTokenByHash - Поиск токена обновления по хэшу
+func (t *RefreshToken) FindRefreshTokenByHash(db *gorm.DB, hash string) (*RefreshToken, error) {
+	var err = db.Model(&RefreshToken{}).Where("hash = ?", hash).Take(&t).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return &RefreshToken{}, apierror.ErrNotFound
+		}
+		return &RefreshToken{}, err
+	}
+	return t, nil
+}
+
+// RevokeARefreshToken - Отзыв токена обновления (replacedByID – ID токена, выданного взамен). Отзывается только
+// действующий токен: возвращает количество отозванных записей, 0 – если токен уже отозван другим запросом
+func (t *RefreshToken) RevokeARefreshToken(db *gorm.DB, replacedByID uint64) (int64, error) {
+	now := time.Now()
+	db = db.Model(&RefreshToken{}).Where("id = ? AND revoked_at IS NULL", t.ID).UpdateColumns(
+		map[string]interface{}{
+			"revoked_at":     now,
+			"replaced_by_id": replacedByID,
+			"updated_at":     now,
+		},
+	)
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	if db.RowsAffected > 0 {
+		t.RevokedAt = &now
+		t.ReplacedByID = replacedByID
+	}
+	return db.RowsAffected, nil
+}
+
+// SetReplacedBy - Запись ID токена, выданного взамен отозванного
+func (t *RefreshToken) SetReplacedBy(db *gorm.DB, replacedByID uint64) error {
+	err := db.Model(&RefreshToken{}).Where("id = ?", t.ID).UpdateColumns(
+		map[string]interface{}{
+			"replaced_by_id": replacedByID,
+			"updated_at":     time.Now(),
+		},
+	).Error
+	if err != nil {
+		return err
+	}
+	t.ReplacedByID = replacedByID
+	return nil
+}
+
+// RevokeAllUserRefreshTokens - Отзыв всех действующих токенов обновления пользователя
+func (t *RefreshToken) RevokeAllUserRefreshTokens(db *gorm.DB, uid uint64) (int64, error) {
+	now := time.Now()
+	db = db.Model(&RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", uid).UpdateColumns(
+		map[string]interface{}{
+			"revoked_at": now,
+			"updated_at": now,
+		},
+	)
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
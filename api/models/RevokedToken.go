@@ -0,0 +1,59 @@
+// Package models - пакет для описания моделей, которые используются для хранения данных
+package models
+
+import (
+	"time"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/jinzhu/gorm"
+)
+
+// RevokedToken - отозванный до окончания срока действия токен доступа
+type RevokedToken struct {
+	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
+	JTI       string    `gorm:"size:64;not null;unique" json:"jti"`
+	UserID    uint64    `gorm:"not null" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// Validate - Валидация отозванного токена
+func (t *RevokedToken) Validate() error {
+	if t.JTI == "" {
+		return apierror.Required("jti")
+	}
+	if t.UserID < 1 {
+		return apierror.Required("user_id")
+	}
+	return nil
+}
+
+// SaveRevokedToken - Добавление токена в список отозванных (заодно удаляет записи о просроченных токенах)
+func (t *RevokedToken) SaveRevokedToken(db *gorm.DB) (*RevokedToken, error) {
+	t.CreatedAt = time.Now()
+	var err = db.Create(&t).Error
+	if err != nil {
+		return &RevokedToken{}, err
+	}
+	err = db.Where("expires_at < ?", time.Now()).Delete(&RevokedToken{}).Error
+	if err != nil {
+		return &RevokedToken{}, err
+	}
+	return t, nil
+}
+
+// RevokedTokenList - список отозванных токенов доступа, хранящийся в базе данных
+type RevokedTokenList struct {
+	DB *gorm.DB
+}
+
+// IsRevoked - Проверка, отозван ли токен с идентификатором jti
+func (l RevokedTokenList) IsRevoked(jti string) bool {
+	var count int
+	err := l.DB.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	if err != nil {
+		// При недоступности базы данных токен считается отозванным
+		return true
+	}
+	return count > 0
+}
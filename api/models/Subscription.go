@@ -2,12 +2,11 @@
 package models
 
 import (
-	"errors"
 	"html"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/jinzhu/gorm"
 )
 
@@ -35,13 +34,13 @@ func (p *Subscription) Prepare() {
 // Validate - Валидация подписки
 func (p *Subscription) Validate() error {
 	if p.Email == "" {
-		return errors.New("Необходимо указать электронную почту для подписки")
+		return apierror.Required("email")
 	}
 	if p.Data == "" {
-		return errors.New("Нужна дата и время отправки подписки")
+		return apierror.Required("data")
 	}
 	if p.AuthorID < 1 {
-		return errors.New("Необходимо указать ID пользователя")
+		return apierror.Required("author_id")
 	}
 	return nil
 }
@@ -49,12 +48,12 @@ func (p *Subscription) Validate() error {
 // SaveSubscription - Сохранение подписки
 func (p *Subscription) SaveSubscription(db *gorm.DB) (*Subscription, error) {
 	var err error
-	err = db.Debug().Model(&Subscription{}).Create(&p).Error
+	err = db.Model(&Subscription{}).Create(&p).Error
 	if err != nil {
 		return &Subscription{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
+		err = db.Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
 		if err != nil {
 			return &Subscription{}, err
 		}
@@ -62,34 +61,33 @@ func (p *Subscription) SaveSubscription(db *gorm.DB) (*Subscription, error) {
 	return p, nil
 }
 
-// FindAllSubscriptions - Вывод все подписки (максимальное количество задаётся параметром GET_LIMIT)
-func (p *Subscription) FindAllSubscriptions(db *gorm.DB) (*[]Subscription, error) {
-	var err error
+// FindAllSubscriptions - Вывод все подписки страницами с сортировкой и фильтрами из query
+func (p *Subscription) FindAllSubscriptions(db *gorm.DB, query ListQuery) (*[]Subscription, string, error) {
 	posts := []Subscription{}
-	err = db.Debug().Model(&Subscription{}).Order("id DESC").Limit(os.Getenv("GET_LIMIT")).Find(&posts).Error
+	next, err := Paginate(db.Model(&Subscription{}), SubscriptionListSpec, query, &posts)
 	if err != nil {
-		return &[]Subscription{}, err
+		return &[]Subscription{}, "", err
 	}
 	if len(posts) > 0 {
 		for i := range posts {
-			err := db.Debug().Model(&User{}).Where("id = ?", posts[i].AuthorID).Take(&posts[i].Author).Error
+			err := db.Model(&User{}).Where("id = ?", posts[i].AuthorID).Take(&posts[i].Author).Error
 			if err != nil {
-				return &[]Subscription{}, err
+				return &[]Subscription{}, "", err
 			}
 		}
 	}
-	return &posts, nil
+	return &posts, next, nil
 }
 
 // FindSubscriptionByID - Вывод данных подписки с ID
 func (p *Subscription) FindSubscriptionByID(db *gorm.DB, pid uint64) (*Subscription, error) {
 	var err error
-	err = db.Debug().Model(&Subscription{}).Where("id = ?", pid).Take(&p).Error
+	err = db.Model(&Subscription{}).Where("id = ?", pid).Take(&p).Error
 	if err != nil {
 		return &Subscription{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
+		err = db.Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
 		if err != nil {
 			return &Subscription{}, err
 		}
@@ -100,12 +98,12 @@ func (p *Subscription) FindSubscriptionByID(db *gorm.DB, pid uint64) (*Subscript
 // UpdateASubscription - Обновление подписки
 func (p *Subscription) UpdateASubscription(db *gorm.DB) (*Subscription, error) {
 	var err error
-	err = db.Debug().Model(&Subscription{}).Where("id = ?", p.ID).Updates(Subscription{Email: p.Email, Data: p.Data, UpdatedAt: time.Now()}).Error
+	err = db.Model(&Subscription{}).Where("id = ?", p.ID).Updates(Subscription{Email: p.Email, Data: p.Data, UpdatedAt: time.Now()}).Error
 	if err != nil {
 		return &Subscription{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
+		err = db.Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
 		if err != nil {
 			return &Subscription{}, err
 		}
@@ -115,10 +113,10 @@ func (p *Subscription) UpdateASubscription(db *gorm.DB) (*Subscription, error) {
 
 // DeleteASubscription - Удаление подписки
 func (p *Subscription) DeleteASubscription(db *gorm.DB, pid uint64, uid uint64) (int64, error) {
-	db = db.Debug().Model(&Subscription{}).Where("id = ? and author_id = ?", pid, uid).Take(&Subscription{}).Delete(&Subscription{})
+	db = db.Model(&Subscription{}).Where("id = ? and author_id = ?", pid, uid).Take(&Subscription{}).Delete(&Subscription{})
 	if db.Error != nil {
 		if gorm.IsRecordNotFoundError(db.Error) {
-			return 0, errors.New("Subscription not found")
+			return 0, apierror.ErrNotFound
 		}
 		return 0, db.Error
 	}
@@ -134,6 +132,6 @@ type SubscriptionFormsWithHashResult struct {
 // SubscriptionFormsWithHash - Вывод адресов электронной почты и настроек с указанием хэша
 func (p *Form) SubscriptionFormsWithHash(db *gorm.DB, start string, end string) *[]SubscriptionFormsWithHashResult {
 	posts := []SubscriptionFormsWithHashResult{}
-	db.Debug().Raw("SELECT email, hash, data FROM subscriptions JOIN profile_links ON subscriptions.id=profile_links.profile_id WHERE subscriptions.created_at >= ? AND subscriptions.created_at <= ? ORDER BY subscriptions.created_at ASC", start, end).Scan(&posts)
+	db.Raw("SELECT email, hash, data FROM subscriptions JOIN profile_links ON subscriptions.id=profile_links.profile_id WHERE subscriptions.created_at >= ? AND subscriptions.created_at <= ? ORDER BY subscriptions.created_at ASC", start, end).Scan(&posts)
 	return &posts
 }
@@ -2,10 +2,9 @@
 package models
 
 import (
-	"errors"
-	"os"
 	"time"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/jinzhu/gorm"
 )
 
@@ -30,7 +29,7 @@ func (p *SubscriptionReport) Prepare() {
 // Validate - Валидация ссылок на ресурсы, которые запросил пользователь
 func (p *SubscriptionReport) Validate() error {
 	if p.Path == "" {
-		return errors.New("Необходимо указать ссылку к ресурсу")
+		return apierror.Required("path")
 	}
 	return nil
 }
@@ -38,33 +37,32 @@ func (p *SubscriptionReport) Validate() error {
 // SaveSubscriptionReport - Сохранение ссылок на ресурсы, которые запросил пользователь
 func (p *SubscriptionReport) SaveSubscriptionReport(db *gorm.DB) (*SubscriptionReport, error) {
 	var err error
-	err = db.Debug().Model(&SubscriptionReport{}).Create(&p).Error
+	err = db.Model(&SubscriptionReport{}).Create(&p).Error
 	if err != nil {
 		return &SubscriptionReport{}, err
 	}
 	return p, nil
 }
 
-// FindAllSubscriptionReports - Вывод всех ссылок на ресурсы, которые запросил пользователь (максимальное количество задаётся параметром GET_LIMIT)
-func (p *SubscriptionReport) FindAllSubscriptionReports(db *gorm.DB) (*[]SubscriptionReport, error) {
-	var err error
+// FindAllSubscriptionReports - Вывод всех ссылок на ресурсы, которые запросил пользователь страницами с сортировкой и фильтрами из query
+func (p *SubscriptionReport) FindAllSubscriptionReports(db *gorm.DB, query ListQuery) (*[]SubscriptionReport, string, error) {
 	posts := []SubscriptionReport{}
-	err = db.Debug().Model(&SubscriptionReport{}).Order("id DESC").Limit(os.Getenv("GET_LIMIT")).Find(&posts).Error
+	next, err := Paginate(db.Model(&SubscriptionReport{}), SubscriptionReportListSpec, query, &posts)
 	if err != nil {
-		return &[]SubscriptionReport{}, err
+		return &[]SubscriptionReport{}, "", err
 	}
-	return &posts, nil
+	return &posts, next, nil
 }
 
 // FindSubscriptionReportByPath - Вывод данных ссылки на ресурсы, которые запросили пользователи
 func (p *SubscriptionReport) FindSubscriptionReportByPath(db *gorm.DB, path string) (*SubscriptionReport, error) {
 	var err error
-	err = db.Debug().Model(&SubscriptionReport{}).Where("path = ?", path).Take(&p).Error
+	err = db.Model(&SubscriptionReport{}).Where("path = ?", path).Take(&p).Error
 	if err != nil {
 		return &SubscriptionReport{}, err
 	}
 	if p.ID != 0 {
-		err = db.Debug().Model(&Subscription{}).Where("id = ?", p.ProfileID).Take(&p.Profile).Error
+		err = db.Model(&Subscription{}).Where("id = ?", p.ProfileID).Take(&p.Profile).Error
 		if err != nil {
 			return &SubscriptionReport{}, err
 		}
@@ -74,10 +72,11 @@ func (p *SubscriptionReport) FindSubscriptionReportByPath(db *gorm.DB, path stri
 
 // DeleteASubscriptionReport - Удаление ссылок на ресурсы, которые запросил пользователь
 func (p *SubscriptionReport) DeleteASubscriptionReport(db *gorm.DB, pid uint64, uid uint64) (int64, error) {
-	db = db.Debug().Model(&SubscriptionReport{}).Where("id = ? and author_id = ?", pid, uid).Take(&SubscriptionReport{}).Delete(&SubscriptionReport{})
+	// У отчёта нет своего автора, он принадлежит автору подписки
+	db = db.Model(&SubscriptionReport{}).Where("id = ? and profile_id IN (SELECT id FROM subscriptions WHERE author_id = ?)", pid, uid).Take(&SubscriptionReport{}).Delete(&SubscriptionReport{})
 	if db.Error != nil {
 		if gorm.IsRecordNotFoundError(db.Error) {
-			return 0, errors.New("SubscriptionReport not found")
+			return 0, apierror.ErrNotFound
 		}
 		return 0, db.Error
 	}
@@ -2,24 +2,30 @@
 package models
 
 import (
-	"errors"
 	"html"
-	"log"
-	"os"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/badoux/checkmail"
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // User - произвольный пользователь
 type User struct {
-	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
-	Nickname  string    `gorm:"size:255;not null;unique" json:"nickname"`
-	Email     string    `gorm:"size:100;not null;unique" json:"email"`
-	Password  string    `gorm:"size:100;not null;" json:"password"`
+	ID         uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	Nickname   string     `gorm:"size:255;not null;unique" json:"nickname"`
+	Email      string     `gorm:"size:100;not null;unique" json:"email"`
+	Password   string     `gorm:"size:100;not null;" json:"password"`
+	VerifiedAt *time.Time `json:"verified_at"`
+
+	// Двухфакторная аутентификация (TOTP): секрет не отдаётся наружу
+	TOTPSecret    string     `gorm:"size:64" json:"-"`
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at"`
+	TOTPLastStep  int64      `gorm:"not null;default:0" json:"-"`
+
 	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
@@ -49,87 +55,63 @@ func (u *User) Prepare() {
 	u.ID = 0
 	u.Nickname = html.EscapeString(strings.TrimSpace(u.Nickname))
 	u.Email = html.EscapeString(strings.TrimSpace(u.Email))
+	u.VerifiedAt = nil
+	u.TOTPSecret = ""
+	u.TOTPEnabledAt = nil
+	u.TOTPLastStep = 0
 	u.CreatedAt = time.Now()
 	u.UpdatedAt = time.Now()
 }
 
-// Validate - Валидация учётных данных пользователя
+// Validate - Валидация учётных данных пользователя; возвращает ошибки во всех полях сразу
 func (u *User) Validate(action string) error {
-	switch strings.ToLower(action) {
-	case "update":
-		if u.Nickname == "" {
-			return errors.New("Требуется имя пользователя")
-		}
-		if u.Password == "" {
-			return errors.New("Требуется пароль")
-		}
-		if u.Email == "" {
-			return errors.New("Требуется электронная почта")
-		}
-		if err := checkmail.ValidateFormat(u.Email); err != nil {
-			return errors.New("Такой почты быть не может")
-		}
-
-		return nil
-	case "login":
-		if u.Password == "" {
-			return errors.New("Требуется пароль")
-		}
-		if u.Email == "" {
-			return errors.New("Требуется электронная почта")
-		}
-		if err := checkmail.ValidateFormat(u.Email); err != nil {
-			return errors.New("Такой почты быть не может")
-		}
-		return nil
-
-	default:
-		if u.Nickname == "" {
-			return errors.New("Требуется имя пользователя")
-		}
-		if u.Password == "" {
-			return errors.New("Требуется пароль")
-		}
-		if u.Email == "" {
-			return errors.New("Требуется электронная почта")
-		}
-		if err := checkmail.ValidateFormat(u.Email); err != nil {
-			return errors.New("Такой почты быть не может")
-		}
-		return nil
+	var fields []apierror.FieldError
+	if strings.ToLower(action) != "login" && u.Nickname == "" {
+		fields = append(fields, apierror.Field("nickname", apierror.FieldRequired))
+	}
+	if u.Password == "" {
+		fields = append(fields, apierror.Field("password", apierror.FieldRequired))
+	}
+	if u.Email == "" {
+		fields = append(fields, apierror.Field("email", apierror.FieldRequired))
+	} else if err := checkmail.ValidateFormat(u.Email); err != nil {
+		fields = append(fields, apierror.Field("email", apierror.FieldInvalid))
 	}
+	if len(fields) > 0 {
+		return apierror.Validation(fields...)
+	}
+	return nil
 }
 
 // SaveUser - Сохранение информации о пользователе
 func (u *User) SaveUser(db *gorm.DB) (*User, error) {
-	var err = db.Debug().Create(&u).Error
+	var err = db.Create(&u).Error
 	if err != nil {
 		return &User{}, err
 	}
 	return u, nil
 }
 
-// FindAllUsers - Вывод всех пользователей (максимальное количество задаётся параметром GET_LIMIT)
-func (u *User) FindAllUsers(db *gorm.DB) (*[]User, error) {
-	var err error
+// FindAllUsers - Вывод всех пользователей страницами с сортировкой и фильтрами из query
+func (u *User) FindAllUsers(db *gorm.DB, query ListQuery) (*[]User, string, error) {
 	users := []User{}
-	err = db.Debug().Model(&User{}).Limit(os.Getenv("GET_LIMIT")).Find(&users).Error
+	next, err := Paginate(db.Model(&User{}), UserListSpec, query, &users)
 	if err != nil {
-		return &[]User{}, err
+		return &[]User{}, "", err
 	}
-	return &users, err
+	return &users, next, nil
 }
 
 // FindUserByID - Вывод информации о пользователе с ID
 func (u *User) FindUserByID(db *gorm.DB, uid uint64) (*User, error) {
-	var err = db.Debug().Model(User{}).Where("id = ?", uid).Take(&u).Error
+	var err = db.Model(User{}).Where("id = ?", uid).Take(&u).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &User{}, apierror.ErrNotFound
+	}
 	if err != nil {
 		return &User{}, err
 	}
-	if gorm.IsRecordNotFoundError(err) {
-		return &User{}, errors.New("User Not Found")
-	}
-	return u, err
+	return u, nil
 }
 
 // UpdateAUser - Обновление информации о пользователе
@@ -137,30 +119,139 @@ func (u *User) UpdateAUser(db *gorm.DB, uid uint64) (*User, error) {
 	// Хеширование пароля
 	err := u.BeforeSave()
 	if err != nil {
-		log.Fatal(err)
+		return &User{}, err
+	}
+	current := User{}
+	err = db.Model(&User{}).Where("id = ?", uid).Take(&current).Error
+	if err != nil {
+		return &User{}, err
+	}
+	columns := map[string]interface{}{
+		"password":   u.Password,
+		"nickname":   u.Nickname,
+		"email":      u.Email,
+		"updated_at": time.Now(),
+	}
+	// Новый адрес электронной почты нужно подтвердить заново
+	if u.Email != current.Email {
+		columns["verified_at"] = nil
+	}
+	db = db.Model(&User{}).Where("id = ?", uid).UpdateColumns(columns)
+	if db.Error != nil {
+		return &User{}, db.Error
+	}
+	// Вывод обновленной информации о пользователе
+	err = db.Model(&User{}).Where("id = ?", uid).Take(&u).Error
+	if err != nil {
+		return &User{}, err
 	}
-	db = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&User{}).UpdateColumns(
+	return u, nil
+}
+
+// IsVerified - Проверка, подтверждена ли электронная почта пользователя
+func (u *User) IsVerified() bool {
+	return u.VerifiedAt != nil
+}
+
+// VerifyAUser - Подтверждение электронной почты пользователя
+func (u *User) VerifyAUser(db *gorm.DB, uid uint64) (*User, error) {
+	now := time.Now()
+	db = db.Model(&User{}).Where("id = ?", uid).Take(&User{}).UpdateColumns(
 		map[string]interface{}{
-			"password":  u.Password,
-			"nickname":  u.Nickname,
-			"email":     u.Email,
-			"update_at": time.Now(),
+			"verified_at": now,
+			"updated_at":  now,
 		},
 	)
 	if db.Error != nil {
 		return &User{}, db.Error
 	}
-	// Вывод обновленной информации о пользователе
-	err = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&u).Error
+	err := db.Model(&User{}).Where("id = ?", uid).Take(&u).Error
 	if err != nil {
 		return &User{}, err
 	}
 	return u, nil
 }
 
+// IsTOTPEnabled - Проверка, включена ли у пользователя двухфакторная аутентификация
+func (u *User) IsTOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// RequiresTOTP - Проверка, состоит ли пользователь в группе, которая требует двухфакторную аутентификацию
+func (u *User) RequiresTOTP(db *gorm.DB, uid uint64) bool {
+	var count int
+	db.Model(&GroupedUser{}).Joins("JOIN user_groups ON user_groups.id = grouped_users.group_id").Where("grouped_users.user_id = ? AND user_groups.require_mfa = ?", uid, true).Count(&count)
+	return count > 0
+}
+
+// SetTOTPSecret - Сохранение нового (ещё не подтверждённого) секрета двухфакторной аутентификации
+func (u *User) SetTOTPSecret(db *gorm.DB, uid uint64, secret string) error {
+	err := db.Model(&User{}).Where("id = ? AND totp_enabled_at IS NULL", uid).UpdateColumns(
+		map[string]interface{}{
+			"totp_secret":    secret,
+			"totp_last_step": 0,
+			"updated_at":     time.Now(),
+		},
+	).Error
+	if err != nil {
+		return err
+	}
+	u.TOTPSecret = secret
+	u.TOTPLastStep = 0
+	return nil
+}
+
+// UseTOTPStep - Отметка об использовании кода из интервала step (каждый код принимается только один раз)
+func (u *User) UseTOTPStep(db *gorm.DB, uid uint64, step int64) error {
+	db = db.Model(&User{}).Where("id = ? AND totp_last_step < ?", uid, step).UpdateColumn("totp_last_step", step)
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected == 0 {
+		return apierror.New(apierror.CodeMFACodeInvalid, http.StatusUnprocessableEntity)
+	}
+	u.TOTPLastStep = step
+	return nil
+}
+
+// EnableTOTP - Включение двухфакторной аутентификации
+func (u *User) EnableTOTP(db *gorm.DB, uid uint64) error {
+	now := time.Now()
+	err := db.Model(&User{}).Where("id = ?", uid).UpdateColumns(
+		map[string]interface{}{
+			"totp_enabled_at": now,
+			"updated_at":      now,
+		},
+	).Error
+	if err != nil {
+		return err
+	}
+	u.TOTPEnabledAt = &now
+	return nil
+}
+
+// DisableTOTP - Отключение двухфакторной аутентификации
+func (u *User) DisableTOTP(db *gorm.DB, uid uint64) error {
+	err := db.Model(&User{}).Where("id = ?", uid).UpdateColumns(
+		map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+			"updated_at":      time.Now(),
+		},
+	).Error
+	if err != nil {
+		return err
+	}
+	u.TOTPSecret = ""
+	u.TOTPEnabledAt = nil
+	u.TOTPLastStep = 0
+	return nil
+}
+
 // DeleteAUser - Удаление пользователя
 func (u *User) DeleteAUser(db *gorm.DB, uid uint64) (int64, error) {
-	db = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&User{}).Delete(&User{})
+	db = db.Model(&User{}).Where("id = ?", uid).Take(&User{}).Delete(&User{})
 
 	if db.Error != nil {
 		return 0, db.Error
@@ -2,20 +2,24 @@
 package models
 
 import (
-	"errors"
 	"html"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/doka-guide/api/api/apierror"
 	"github.com/jinzhu/gorm"
 )
 
 // UserGroup - произвольная группа пользователей
 type UserGroup struct {
-	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
-	Name      string    `gorm:"size:255;not null;unique" json:"nickname"`
-	Email     string    `gorm:"size:100;not null;unique" json:"email"`
+	ID         uint64 `gorm:"primary_key;auto_increment" json:"id"`
+	Name       string `gorm:"size:255;not null;unique" json:"name"`
+	Email      string `gorm:"size:100;not null;unique" json:"email"`
+	RequireMFA bool   `gorm:"not null;default:false" json:"require_mfa"`
+
+	// Родительская группа, разрешения которой наследует группа
+	ParentID *uint64 `gorm:"index" json:"parent_id"`
+
 	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
@@ -25,67 +29,152 @@ func (u *UserGroup) Prepare() {
 	u.ID = 0
 	u.Name = html.EscapeString(strings.TrimSpace(u.Name))
 	u.Email = html.EscapeString(strings.TrimSpace(u.Email))
+	if u.ParentID != nil && *u.ParentID == 0 {
+		u.ParentID = nil
+	}
 	u.CreatedAt = time.Now()
 	u.UpdatedAt = time.Now()
 }
 
+// Validate - Валидация информации о группе пользователей
+func (u *UserGroup) Validate() error {
+	if u.Name == "" {
+		return apierror.Required("name")
+	}
+	if u.Email == "" {
+		return apierror.Required("email")
+	}
+	return nil
+}
+
+// ValidateParent - Проверка родительской группы: она существует и не является потомком группы с ID (иначе получится цикл)
+func (u *UserGroup) ValidateParent(db *gorm.DB, uid uint64) error {
+	if u.ParentID == nil {
+		return nil
+	}
+	if uid != 0 && *u.ParentID == uid {
+		return apierror.Invalid("parent_id")
+	}
+	var count int
+	err := db.Model(&UserGroup{}).Where("id = ?", *u.ParentID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return apierror.Reference("parent_id")
+	}
+	if uid == 0 {
+		return nil
+	}
+	var cycle struct {
+		Count int
+	}
+	err = db.Raw(`WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM user_groups WHERE id = ?
+		UNION
+		SELECT user_groups.id, user_groups.parent_id FROM user_groups JOIN ancestors ON user_groups.id = ancestors.parent_id
+	) SELECT COUNT(*) AS count FROM ancestors WHERE id = ?`, *u.ParentID, uid).Scan(&cycle).Error
+	if err != nil {
+		return err
+	}
+	if cycle.Count > 0 {
+		return apierror.Invalid("parent_id")
+	}
+	return nil
+}
+
 // SaveUserGroup - Сохранение информации о группе пользователей
 func (u *UserGroup) SaveUserGroup(db *gorm.DB) (*UserGroup, error) {
-	var err = db.Debug().Create(&u).Error
+	var err = db.Create(&u).Error
 	if err != nil {
 		return &UserGroup{}, err
 	}
 	return u, nil
 }
 
-// FindAllUserGroups - Вывод всех групп пользователей (максимальное количество задаётся параметром GET_LIMIT)
-func (u *UserGroup) FindAllUserGroups(db *gorm.DB) (*[]UserGroup, error) {
-	var err error
+// FindAllUserGroups - Вывод всех групп пользователей страницами с сортировкой и фильтрами из query
+func (u *UserGroup) FindAllUserGroups(db *gorm.DB, query ListQuery) (*[]UserGroup, string, error) {
 	users := []UserGroup{}
-	err = db.Debug().Model(&UserGroup{}).Limit(os.Getenv("GET_LIMIT")).Find(&users).Error
+	next, err := Paginate(db.Model(&UserGroup{}), UserGroupListSpec, query, &users)
 	if err != nil {
-		return &[]UserGroup{}, err
+		return &[]UserGroup{}, "", err
 	}
-	return &users, err
+	return &users, next, nil
 }
 
 // FindUserGroupByID - Вывод информации о группе пользователей с ID
 func (u *UserGroup) FindUserGroupByID(db *gorm.DB, uid uint64) (*UserGroup, error) {
-	var err = db.Debug().Model(User{}).Where("id = ?", uid).Take(&u).Error
+	var err = db.Model(&UserGroup{}).Where("id = ?", uid).Take(&u).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &UserGroup{}, apierror.ErrNotFound
+	}
 	if err != nil {
 		return &UserGroup{}, err
 	}
-	if gorm.IsRecordNotFoundError(err) {
-		return &UserGroup{}, errors.New("UserGroup Not Found")
-	}
-	return u, err
+	return u, nil
 }
 
 // UpdateAUserGroup - Обновление информации о группе пользователей
 func (u *UserGroup) UpdateAUserGroup(db *gorm.DB, uid uint64) (*UserGroup, error) {
-	db = db.Debug().Model(&UserGroup{}).Where("id = ?", uid).Take(&UserGroup{}).UpdateColumns(
+	db = db.Model(&UserGroup{}).Where("id = ?", uid).Take(&UserGroup{}).UpdateColumns(
 		map[string]interface{}{
-			"name":      u.Name,
-			"email":     u.Email,
-			"update_at": time.Now(),
+			"name":        u.Name,
+			"email":       u.Email,
+			"require_mfa": u.RequireMFA,
+			"parent_id":   u.ParentID,
+			"updated_at":  time.Now(),
 		},
 	)
 	if db.Error != nil {
 		return &UserGroup{}, db.Error
 	}
+	// Смена родительской группы меняет унаследованные разрешения участников
+	err := BumpPermissionVersion(db)
+	if err != nil {
+		return &UserGroup{}, err
+	}
 	// Вывод обновленной информации о группе пользователей
-	err := db.Debug().Model(&UserGroup{}).Where("id = ?", uid).Take(&u).Error
+	err = db.Model(&UserGroup{}).Where("id = ?", uid).Take(&u).Error
 	if err != nil {
 		return &UserGroup{}, err
 	}
 	return u, nil
 }
 
-// DeleteAUserGroup - Удаление группы пользователей
+// DeleteAUserGroup - Удаление группы пользователей вместе с её участниками и разрешениями
 func (u *UserGroup) DeleteAUserGroup(db *gorm.DB, uid uint64) (int64, error) {
-	db = db.Debug().Model(&UserGroup{}).Where("id = ?", uid).Take(&UserGroup{}).Delete(&UserGroup{})
-	if db.Error != nil {
-		return 0, db.Error
+	var affected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&UserGroup{}).Where("id = ?", uid).Take(&UserGroup{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("group_id = ?", uid).Delete(&GroupedUser{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("group_id = ?", uid).Delete(&GroupPermission{}).Error
+		if err != nil {
+			return err
+		}
+		// Дочерние группы перестают наследовать разрешения удалённой группы
+		err = tx.Model(&UserGroup{}).Where("parent_id = ?", uid).UpdateColumn("parent_id", gorm.Expr("NULL")).Error
+		if err != nil {
+			return err
+		}
+		err = BumpPermissionVersion(tx)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", uid).Delete(&UserGroup{})
+		affected = result.RowsAffected
+		return result.Error
+	})
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, apierror.ErrNotFound
+	}
+	if err != nil {
+		return 0, err
 	}
-	return db.RowsAffected, nil
+	return affected, nil
 }
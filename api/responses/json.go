@@ -5,8 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"github.com/doka-guide/api/api/apierror"
+	"github.com/doka-guide/api/api/utils/logger"
 )
 
+// ErrorResponse - тело ответа с ошибкой
+type ErrorResponse struct {
+	// Сообщение на языке из заголовка Content-Language
+	Error string `json:"error"`
+	// Постоянный код ошибки
+	Code apierror.Code `json:"code"`
+	// Ошибки в полях запроса
+	Details []apierror.FieldError `json:"details,omitempty"`
+	// Идентификатор запроса из заголовка X-Request-ID
+	RequestID string `json:"request_id,omitempty"`
+}
+
 func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.WriteHeader(statusCode)
 	err := json.NewEncoder(w).Encode(data)
@@ -15,14 +30,32 @@ func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	}
 }
 
+// ERROR - Ответ с ошибкой: код ответа берётся из ошибки apierror.Error, если это она, иначе statusCode.
+// Сообщение переводится на язык из заголовка Content-Language, который выставляет посредник
+// по Accept-Language. Причины внутренних ошибок записываются в журнал и не отдаются клиенту
 func ERROR(w http.ResponseWriter, statusCode int, err error) {
-	if err != nil {
-		JSON(w, statusCode, struct {
-			Error string `json:"error"`
-		}{
-			Error: err.Error(),
-		})
-		return
+	if err == nil {
+		err = apierror.New(apierror.CodeBadRequest, http.StatusBadRequest)
+	}
+	apiErr := apierror.From(err, statusCode)
+	status := apiErr.Status
+	if status == 0 {
+		status = statusCode
+	}
+	requestID := w.Header().Get("X-Request-ID")
+	if status >= http.StatusInternalServerError && apiErr.Cause != nil {
+		logger.Default().With("request_id", requestID).Error("Внутренняя ошибка", "code", apiErr.Code, "error", apiErr.Cause)
+	} else if apiErr.Cause != nil {
+		logger.Default().With("request_id", requestID).Debug("Ошибка запроса", "code", apiErr.Code, "error", apiErr.Cause)
+	}
+	lang := w.Header().Get("Content-Language")
+	if lang == "" {
+		lang = apierror.DefaultLanguage
 	}
-	JSON(w, http.StatusBadRequest, nil)
+	JSON(w, status, ErrorResponse{
+		Error:     apiErr.Localize(lang),
+		Code:      apiErr.Code,
+		Details:   apiErr.LocalizeFields(lang),
+		RequestID: requestID,
+	})
 }
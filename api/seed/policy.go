@@ -0,0 +1,587 @@
+// Package seed - пакет для установки структуры БД и записей по умолчанию
+package seed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doka-guide/api/api/models"
+	"github.com/jinzhu/gorm"
+)
+
+// Обозначения изменений в выводе плана
+const (
+	OpCreate = "+" // запись будет добавлена
+	OpUpdate = "~" // запись будет изменена
+	OpExtra  = "!" // запись есть только в базе данных и не удаляется
+)
+
+// Policy - политика доступа: разрешения, группы и пользователи, которые должны быть в базе данных
+type Policy struct {
+	Permissions []string      `json:"permissions"`
+	Groups      []PolicyGroup `json:"groups"`
+	Users       []PolicyUser  `json:"users"`
+}
+
+// PolicyGroup - группа пользователей в политике доступа
+type PolicyGroup struct {
+	Name       string   `json:"name"`
+	Email      string   `json:"email"`
+	RequireMFA flag     `json:"require_mfa"`
+	Parent     string   `json:"parent"`
+	Allow      []string `json:"allow"`
+	Deny       []string `json:"deny"`
+
+	requireMFA bool
+}
+
+// PolicyUser - пользователь в политике доступа
+type PolicyUser struct {
+	Nickname string   `json:"nickname"`
+	Email    string   `json:"email"`
+	Password string   `json:"password"`
+	Verified flag     `json:"verified"`
+	Groups   []string `json:"groups"`
+
+	verified bool
+}
+
+// Change - изменение базы данных, которое нужно для применения политики
+type Change struct {
+	Op    string
+	Text  string
+	apply func(tx *gorm.DB) error
+}
+
+// String - Строка изменения для вывода плана
+func (c Change) String() string {
+	return c.Op + " " + c.Text
+}
+
+// flag - логическое значение, которое можно записать в файле строкой с переменной окружения
+type flag string
+
+// UnmarshalJSON - Чтение логического значения из true/false или строки
+func (f *flag) UnmarshalJSON(data []byte) error {
+	var b bool
+	if json.Unmarshal(data, &b) == nil {
+		*f = flag(strconv.FormatBool(b))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("Ожидается true, false или строка")
+	}
+	*f = flag(s)
+	return nil
+}
+
+// parse - Логическое значение флага (пустая строка – false)
+func (f flag) parse() (bool, error) {
+	if f == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(strings.ToLower(string(f)))
+}
+
+// LoadPolicy - Чтение политики доступа из JSON-файла с подстановкой переменных окружения ${VAR} и ${VAR:-значение}
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := Policy{}
+	err = json.Unmarshal(data, &policy)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	missing := map[string]bool{}
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			fallback := ""
+			if i := strings.Index(name, ":-"); i >= 0 {
+				name, fallback = name[:i], name[i+2:]
+				if value := os.Getenv(name); value != "" {
+					return value
+				}
+				return fallback
+			}
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				missing[name] = true
+			}
+			return value
+		})
+	}
+	expandAll := func(list []string) {
+		for i := range list {
+			list[i] = strings.TrimSpace(expand(list[i]))
+		}
+	}
+
+	expandAll(policy.Permissions)
+	for i := range policy.Groups {
+		g := &policy.Groups[i]
+		g.Name = strings.TrimSpace(expand(g.Name))
+		g.Email = strings.TrimSpace(expand(g.Email))
+		g.RequireMFA = flag(strings.TrimSpace(expand(string(g.RequireMFA))))
+		g.Parent = strings.TrimSpace(expand(g.Parent))
+		expandAll(g.Allow)
+		expandAll(g.Deny)
+	}
+	for i := range policy.Users {
+		u := &policy.Users[i]
+		u.Nickname = strings.TrimSpace(expand(u.Nickname))
+		u.Email = strings.TrimSpace(expand(u.Email))
+		u.Password = expand(u.Password)
+		u.Verified = flag(strings.TrimSpace(expand(string(u.Verified))))
+		expandAll(u.Groups)
+	}
+	if len(missing) > 0 {
+		names := []string{}
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("%s: не заданы переменные окружения: %s", path, strings.Join(names, ", "))
+	}
+
+	err = policy.validate()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &policy, nil
+}
+
+// validate - Проверка ссылок между записями политики и упорядочивание групп (родительские группы раньше дочерних)
+func (p *Policy) validate() error {
+	for _, name := range p.Permissions {
+		if name == "" {
+			return errors.New("Пустое название разрешения")
+		}
+	}
+
+	groups := map[string]*PolicyGroup{}
+	for i := range p.Groups {
+		g := &p.Groups[i]
+		if g.Name == "" || g.Email == "" {
+			return errors.New("Необходимо указать название и электронную почту группы")
+		}
+		if groups[g.Name] != nil {
+			return fmt.Errorf("Группа %s указана дважды", g.Name)
+		}
+		groups[g.Name] = g
+		requireMFA, err := g.RequireMFA.parse()
+		if err != nil {
+			return fmt.Errorf("Группа %s: require_mfa: %v", g.Name, err)
+		}
+		g.requireMFA = requireMFA
+		allowed := map[string]bool{}
+		for _, name := range g.Allow {
+			if name == "" {
+				return fmt.Errorf("Группа %s: пустое название разрешения", g.Name)
+			}
+			allowed[name] = true
+		}
+		for _, name := range g.Deny {
+			if name == "" {
+				return fmt.Errorf("Группа %s: пустое название разрешения", g.Name)
+			}
+			if allowed[name] {
+				return fmt.Errorf("Группа %s: разрешение %s одновременно выдано и запрещено", g.Name, name)
+			}
+		}
+	}
+	for _, g := range p.Groups {
+		if g.Parent != "" && groups[g.Parent] == nil {
+			return fmt.Errorf("Группа %s: родительская группа %s не описана в политике", g.Name, g.Parent)
+		}
+	}
+
+	// Упорядочивание групп: родительская группа создаётся раньше дочерних
+	sorted := make([]PolicyGroup, 0, len(p.Groups))
+	placed := map[string]bool{}
+	for len(sorted) < len(p.Groups) {
+		progress := false
+		for _, g := range p.Groups {
+			if placed[g.Name] || (g.Parent != "" && !placed[g.Parent]) {
+				continue
+			}
+			sorted = append(sorted, g)
+			placed[g.Name] = true
+			progress = true
+		}
+		if !progress {
+			return errors.New("Родительские группы образуют цикл")
+		}
+	}
+	p.Groups = sorted
+
+	emails := map[string]bool{}
+	for i := range p.Users {
+		u := &p.Users[i]
+		if u.Nickname == "" || u.Email == "" || u.Password == "" {
+			return errors.New("Необходимо указать имя, электронную почту и пароль пользователя")
+		}
+		if emails[u.Email] {
+			return fmt.Errorf("Пользователь %s указан дважды", u.Email)
+		}
+		emails[u.Email] = true
+		verified, err := u.Verified.parse()
+		if err != nil {
+			return fmt.Errorf("Пользователь %s: verified: %v", u.Email, err)
+		}
+		u.verified = verified
+		for _, name := range u.Groups {
+			if groups[name] == nil {
+				return fmt.Errorf("Пользователь %s: группа %s не описана в политике", u.Email, name)
+			}
+		}
+	}
+	return nil
+}
+
+// permissionNames - Все разрешения политики: из общего списка и из разрешений и запретов групп
+func (p *Policy) permissionNames() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	add := func(list []string) {
+		for _, name := range list {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	add(p.Permissions)
+	for _, g := range p.Groups {
+		add(g.Allow)
+		add(g.Deny)
+	}
+	return names
+}
+
+// Plan - Сравнение политики с базой данных: список изменений, которые нужны для её применения
+func (p *Policy) Plan(db *gorm.DB) ([]Change, error) {
+	changes := []Change{}
+
+	// Текущее состояние базы данных
+	permissions := []models.Permission{}
+	err := db.Model(&models.Permission{}).Find(&permissions).Error
+	if err != nil {
+		return nil, err
+	}
+	permissionIDs := map[string]uint64{}
+	permissionNames := map[uint64]string{}
+	for _, permission := range permissions {
+		permissionIDs[permission.Name] = permission.ID
+		permissionNames[permission.ID] = permission.Name
+	}
+	groups := []models.UserGroup{}
+	err = db.Model(&models.UserGroup{}).Find(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+	groupsByName := map[string]models.UserGroup{}
+	groupNames := map[uint64]string{}
+	for _, group := range groups {
+		groupsByName[group.Name] = group
+		groupNames[group.ID] = group.Name
+	}
+
+	// Разрешения
+	for _, name := range p.permissionNames() {
+		if _, ok := permissionIDs[name]; ok {
+			continue
+		}
+		permission := models.Permission{Name: name}
+		changes = append(changes, Change{
+			Op:   OpCreate,
+			Text: "разрешение " + name,
+			apply: func(tx *gorm.DB) error {
+				return tx.Model(&models.Permission{}).Create(&permission).Error
+			},
+		})
+	}
+
+	// Группы
+	for _, g := range p.Groups {
+		g := g
+		existing, ok := groupsByName[g.Name]
+		if !ok {
+			changes = append(changes, Change{
+				Op:   OpCreate,
+				Text: describeGroup(g),
+				apply: func(tx *gorm.DB) error {
+					group := models.UserGroup{Name: g.Name, Email: g.Email, RequireMFA: g.requireMFA}
+					if g.Parent != "" {
+						parentID, err := groupID(tx, g.Parent)
+						if err != nil {
+							return err
+						}
+						group.ParentID = &parentID
+					}
+					return tx.Model(&models.UserGroup{}).Create(&group).Error
+				},
+			})
+			continue
+		}
+		diffs := []string{}
+		if existing.Email != g.Email {
+			diffs = append(diffs, fmt.Sprintf("email %s → %s", existing.Email, g.Email))
+		}
+		if existing.RequireMFA != g.requireMFA {
+			diffs = append(diffs, fmt.Sprintf("require_mfa %t → %t", existing.RequireMFA, g.requireMFA))
+		}
+		parent := ""
+		if existing.ParentID != nil {
+			parent = groupNames[*existing.ParentID]
+		}
+		if parent != g.Parent {
+			diffs = append(diffs, fmt.Sprintf("parent %q → %q", parent, g.Parent))
+		}
+		if len(diffs) > 0 {
+			id := existing.ID
+			changes = append(changes, Change{
+				Op:   OpUpdate,
+				Text: "группа " + g.Name + ": " + strings.Join(diffs, ", "),
+				apply: func(tx *gorm.DB) error {
+					var parentID *uint64
+					if g.Parent != "" {
+						pid, err := groupID(tx, g.Parent)
+						if err != nil {
+							return err
+						}
+						parentID = &pid
+					}
+					err := tx.Model(&models.UserGroup{}).Where("id = ?", id).UpdateColumns(
+						map[string]interface{}{
+							"email":       g.Email,
+							"require_mfa": g.requireMFA,
+							"parent_id":   parentID,
+							"updated_at":  time.Now(),
+						},
+					).Error
+					if err != nil {
+						return err
+					}
+					// UpdateColumns не вызывает AfterSave, а смена родителя меняет унаследованные права
+					return models.BumpPermissionVersion(tx)
+				},
+			})
+		}
+
+		// Разрешения и запреты группы
+		grants := map[uint64]bool{}
+		if ok {
+			current := []models.GroupPermission{}
+			err = db.Model(&models.GroupPermission{}).Where("group_id = ?", existing.ID).Find(&current).Error
+			if err != nil {
+				return nil, err
+			}
+			for _, grant := range current {
+				grants[grant.PermsID] = grant.Deny
+			}
+		}
+		declared := map[string]bool{}
+		for _, list := range []struct {
+			names []string
+			deny  bool
+		}{{g.Allow, false}, {g.Deny, true}} {
+			for _, name := range list.names {
+				name, deny := name, list.deny
+				declared[name] = true
+				current, found := grants[permissionIDs[name]]
+				if found && current == deny {
+					continue
+				}
+				if found {
+					changes = append(changes, Change{
+						Op:   OpUpdate,
+						Text: fmt.Sprintf("группа %s: %s %s → %s", g.Name, name, grantKind(current), grantKind(deny)),
+						apply: func(tx *gorm.DB) error {
+							err := tx.Model(&models.GroupPermission{}).Where("group_id = ? AND perms_id = ?", existing.ID, permissionIDs[name]).UpdateColumn("deny", deny).Error
+							if err != nil {
+								return err
+							}
+							return models.BumpPermissionVersion(tx)
+						},
+					})
+					continue
+				}
+				changes = append(changes, Change{
+					Op:   OpCreate,
+					Text: fmt.Sprintf("группа %s: %s %s", g.Name, grantKind(deny), name),
+					apply: func(tx *gorm.DB) error {
+						gid, err := groupID(tx, g.Name)
+						if err != nil {
+							return err
+						}
+						pid, err := permissionID(tx, name)
+						if err != nil {
+							return err
+						}
+						grant := models.GroupPermission{GroupID: gid, PermsID: pid, Deny: deny}
+						return tx.Model(&models.GroupPermission{}).Create(&grant).Error
+					},
+				})
+			}
+		}
+		extras := []string{}
+		for pid, deny := range grants {
+			if !declared[permissionNames[pid]] {
+				extras = append(extras, fmt.Sprintf("группа %s: %s %s", g.Name, grantKind(deny), permissionNames[pid]))
+			}
+		}
+		sort.Strings(extras)
+		for _, text := range extras {
+			changes = append(changes, Change{Op: OpExtra, Text: text})
+		}
+	}
+
+	// Пользователи и их группы
+	for _, u := range p.Users {
+		u := u
+		existing := models.User{}
+		err = db.Model(&models.User{}).Where("email = ?", u.Email).Take(&existing).Error
+		if err != nil && !gorm.IsRecordNotFoundError(err) {
+			return nil, err
+		}
+		found := err == nil
+		if !found {
+			changes = append(changes, Change{
+				Op:   OpCreate,
+				Text: fmt.Sprintf("пользователь %s <%s>", u.Nickname, u.Email),
+				apply: func(tx *gorm.DB) error {
+					user := models.User{Nickname: u.Nickname, Email: u.Email, Password: u.Password}
+					if u.verified {
+						verifiedAt := time.Now()
+						user.VerifiedAt = &verifiedAt
+					}
+					return tx.Model(&models.User{}).Create(&user).Error
+				},
+			})
+		} else if existing.Nickname != u.Nickname {
+			id := existing.ID
+			changes = append(changes, Change{
+				Op:   OpUpdate,
+				Text: fmt.Sprintf("пользователь %s: nickname %s → %s", u.Email, existing.Nickname, u.Nickname),
+				apply: func(tx *gorm.DB) error {
+					return tx.Model(&models.User{}).Where("id = ?", id).UpdateColumn("nickname", u.Nickname).Error
+				},
+			})
+		}
+
+		memberships := map[uint64]bool{}
+		if found {
+			current := []models.GroupedUser{}
+			err = db.Model(&models.GroupedUser{}).Where("user_id = ?", existing.ID).Find(&current).Error
+			if err != nil {
+				return nil, err
+			}
+			for _, membership := range current {
+				memberships[membership.GroupID] = true
+			}
+		}
+		declared := map[string]bool{}
+		for _, name := range u.Groups {
+			name := name
+			declared[name] = true
+			if group, ok := groupsByName[name]; ok && memberships[group.ID] {
+				continue
+			}
+			changes = append(changes, Change{
+				Op:   OpCreate,
+				Text: fmt.Sprintf("пользователь %s: группа %s", u.Email, name),
+				apply: func(tx *gorm.DB) error {
+					gid, err := groupID(tx, name)
+					if err != nil {
+						return err
+					}
+					uid, err := userID(tx, u.Email)
+					if err != nil {
+						return err
+					}
+					membership := models.GroupedUser{GroupID: gid, UserID: uid}
+					return tx.Model(&models.GroupedUser{}).Create(&membership).Error
+				},
+			})
+		}
+		extras := []string{}
+		for gid := range memberships {
+			if !declared[groupNames[gid]] {
+				extras = append(extras, fmt.Sprintf("пользователь %s: группа %s", u.Email, groupNames[gid]))
+			}
+		}
+		sort.Strings(extras)
+		for _, text := range extras {
+			changes = append(changes, Change{Op: OpExtra, Text: text})
+		}
+	}
+	return changes, nil
+}
+
+// Apply - Применение изменений в одной транзакции (записи только из базы данных не удаляются)
+func Apply(db *gorm.DB, changes []Change) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			if change.apply == nil {
+				continue
+			}
+			err := change.apply(tx)
+			if err != nil {
+				return fmt.Errorf("%s: %v", change, err)
+			}
+		}
+		return nil
+	})
+}
+
+// describeGroup - Описание новой группы для вывода плана
+func describeGroup(g PolicyGroup) string {
+	text := fmt.Sprintf("группа %s <%s>", g.Name, g.Email)
+	if g.requireMFA {
+		text += ", require_mfa"
+	}
+	if g.Parent != "" {
+		text += ", parent " + g.Parent
+	}
+	return text
+}
+
+// grantKind - Вид записи группа-разрешение для вывода плана
+func grantKind(deny bool) string {
+	if deny {
+		return "запрет"
+	}
+	return "разрешение"
+}
+
+// groupID - ID группы по названию
+func groupID(tx *gorm.DB, name string) (uint64, error) {
+	group := models.UserGroup{}
+	err := tx.Model(&models.UserGroup{}).Where("name = ?", name).Take(&group).Error
+	return group.ID, err
+}
+
+// permissionID - ID разрешения по названию
+func permissionID(tx *gorm.DB, name string) (uint64, error) {
+	permission := models.Permission{}
+	err := tx.Model(&models.Permission{}).Where("name = ?", name).Take(&permission).Error
+	return permission.ID, err
+}
+
+// userID - ID пользователя по электронной почте
+func userID(tx *gorm.DB, email string) (uint64, error) {
+	user := models.User{}
+	err := tx.Model(&models.User{}).Where("email = ?", email).Take(&user).Error
+	return user.ID, err
+}
@@ -2,298 +2,74 @@
 package seed
 
 import (
-	"log"
-	"os"
-
+	"github.com/doka-guide/api/api/config"
+	"github.com/doka-guide/api/api/migrations"
 	"github.com/doka-guide/api/api/models"
+	"github.com/doka-guide/api/api/utils/logger"
 	"github.com/jinzhu/gorm"
 )
 
-// Load - загрузка базы данных
-func Load(db *gorm.DB) {
-	// Пользователи по умолчанию
-	var users = []models.User{
-		{
-			Nickname: os.Getenv("USER_NAME"),
-			Email:    os.Getenv("USER_MAIL"),
-			Password: os.Getenv("USER_PASS"),
-		},
-		{
-			Nickname: os.Getenv("ADMIN_NAME"),
-			Email:    os.Getenv("ADMIN_MAIL"),
-			Password: os.Getenv("ADMIN_PASS"),
-		},
+// Load - загрузка базы данных: в режиме отладки таблицы пересоздаются, затем применяется политика доступа
+func Load(db *gorm.DB, cfg *config.Config) {
+	// Пересоздание таблиц в режиме отладки
+	if cfg.IsDebug() && !cfg.Seed.DryRun {
+		reset(db)
 	}
 
-	// Группы пользователей по умолчанию
-	var groups = []models.UserGroup{
-		{
-			Name:  os.Getenv("USER_GROUP_NAME"),
-			Email: os.Getenv("USER_GROUP_MAIL"),
-		},
-		{
-			Name:  os.Getenv("ADMIN_GROUP_NAME"),
-			Email: os.Getenv("ADMIN_GROUP_MAIL"),
-		},
+	// Файл политики применяется при каждом запуске; в режиме отладки по умолчанию используется policy.json
+	path := cfg.Seed.PolicyFile
+	if path == "" && cfg.IsDebug() {
+		path = "policy.json"
 	}
-
-	var groupedUsers = []models.GroupedUser{
-		{
-			GroupID: 1,
-			UserID:  1,
-		},
-		{
-			GroupID: 2,
-			UserID:  2,
-		},
+	if path == "" {
+		return
 	}
-
-	// Права на запросы для групп пользователей
-	var permissions = []models.Permission{
-		{Name: os.Getenv("PERMISSION_ENTITY_USER") + "-" + os.Getenv("PERMISSION_REQUEST_OPTIONS")},
-		{Name: os.Getenv("PERMISSION_ENTITY_USER") + "-" + os.Getenv("PERMISSION_REQUEST_GET")},
-		{Name: os.Getenv("PERMISSION_ENTITY_USER") + "-" + os.Getenv("PERMISSION_REQUEST_POST")},
-		{Name: os.Getenv("PERMISSION_ENTITY_USER") + "-" + os.Getenv("PERMISSION_REQUEST_PUT")},
-		{Name: os.Getenv("PERMISSION_ENTITY_USER") + "-" + os.Getenv("PERMISSION_REQUEST_DELETE")},
-
-		{Name: os.Getenv("PERMISSION_ENTITY_FORM") + "-" + os.Getenv("PERMISSION_REQUEST_OPTIONS")},
-		{Name: os.Getenv("PERMISSION_ENTITY_FORM") + "-" + os.Getenv("PERMISSION_REQUEST_GET")},
-		{Name: os.Getenv("PERMISSION_ENTITY_FORM") + "-" + os.Getenv("PERMISSION_REQUEST_POST")},
-		{Name: os.Getenv("PERMISSION_ENTITY_FORM") + "-" + os.Getenv("PERMISSION_REQUEST_PUT")},
-		{Name: os.Getenv("PERMISSION_ENTITY_FORM") + "-" + os.Getenv("PERMISSION_REQUEST_DELETE")},
-
-		{Name: os.Getenv("PERMISSION_ENTITY_PROFILE_LINK") + "-" + os.Getenv("PERMISSION_REQUEST_OPTIONS")},
-		{Name: os.Getenv("PERMISSION_ENTITY_PROFILE_LINK") + "-" + os.Getenv("PERMISSION_REQUEST_GET")},
-		{Name: os.Getenv("PERMISSION_ENTITY_PROFILE_LINK") + "-" + os.Getenv("PERMISSION_REQUEST_POST")},
-		{Name: os.Getenv("PERMISSION_ENTITY_PROFILE_LINK") + "-" + os.Getenv("PERMISSION_REQUEST_PUT")},
-		{Name: os.Getenv("PERMISSION_ENTITY_PROFILE_LINK") + "-" + os.Getenv("PERMISSION_REQUEST_DELETE")},
-
-		{Name: os.Getenv("PERMISSION_ENTITY_SUBSCRIPTION") + "-" + os.Getenv("PERMISSION_REQUEST_OPTIONS")},
-		{Name: os.Getenv("PERMISSION_ENTITY_SUBSCRIPTION") + "-" + os.Getenv("PERMISSION_REQUEST_GET")},
-		{Name: os.Getenv("PERMISSION_ENTITY_SUBSCRIPTION") + "-" + os.Getenv("PERMISSION_REQUEST_POST")},
-		{Name: os.Getenv("PERMISSION_ENTITY_SUBSCRIPTION") + "-" + os.Getenv("PERMISSION_REQUEST_PUT")},
-		{Name: os.Getenv("PERMISSION_ENTITY_SUBSCRIPTION") + "-" + os.Getenv("PERMISSION_REQUEST_DELETE")},
-
-		{Name: os.Getenv("PERMISSION_ENTITY_SUBSCRIPTION_REPORT") + "-" + os.Getenv("PERMISSION_REQUEST_OPTIONS")},
-		{Name: os.Getenv("PERMISSION_ENTITY_SUBSCRIPTION_REPORT") + "-" + os.Getenv("PERMISSION_REQUEST_GET")},
-		{Name: os.Getenv("PERMISSION_ENTITY_SUBSCRIPTION_REPORT") + "-" + os.Getenv("PERMISSION_REQUEST_POST")},
-		{Name: os.Getenv("PERMISSION_ENTITY_SUBSCRIPTION_REPORT") + "-" + os.Getenv("PERMISSION_REQUEST_PUT")},
-		{Name: os.Getenv("PERMISSION_ENTITY_SUBSCRIPTION_REPORT") + "-" + os.Getenv("PERMISSION_REQUEST_DELETE")},
+	changes, err := ApplyPolicy(db, path, cfg.Seed.DryRun)
+	if err != nil {
+		logger.Fatal("Не удаётся применить политику доступа", "file", path, "error", err)
 	}
-
-	var groupPermissions = []models.GroupPermission{
-		{
-			GroupID: 1,
-			PermsID: 6,
-		},
-		{
-			GroupID: 1,
-			PermsID: 8,
-		},
-		{
-			GroupID: 1,
-			PermsID: 16,
-		},
-		{
-			GroupID: 1,
-			PermsID: 17,
-		},
-		{
-			GroupID: 1,
-			PermsID: 18,
-		},
-		{
-			GroupID: 1,
-			PermsID: 19,
-		},
-		{
-			GroupID: 2,
-			PermsID: 1,
-		},
-		{
-			GroupID: 2,
-			PermsID: 2,
-		},
-		{
-			GroupID: 2,
-			PermsID: 3,
-		},
-		{
-			GroupID: 2,
-			PermsID: 4,
-		},
-		{
-			GroupID: 2,
-			PermsID: 5,
-		},
-		{
-			GroupID: 2,
-			PermsID: 6,
-		},
-		{
-			GroupID: 2,
-			PermsID: 7,
-		},
-		{
-			GroupID: 2,
-			PermsID: 8,
-		},
-		{
-			GroupID: 2,
-			PermsID: 9,
-		},
-		{
-			GroupID: 2,
-			PermsID: 10,
-		},
-		{
-			GroupID: 2,
-			PermsID: 11,
-		},
-		{
-			GroupID: 2,
-			PermsID: 12,
-		},
-		{
-			GroupID: 2,
-			PermsID: 13,
-		},
-		{
-			GroupID: 2,
-			PermsID: 14,
-		},
-		{
-			GroupID: 2,
-			PermsID: 15,
-		},
-		{
-			GroupID: 2,
-			PermsID: 16,
-		},
-		{
-			GroupID: 2,
-			PermsID: 17,
-		},
-		{
-			GroupID: 2,
-			PermsID: 18,
-		},
-		{
-			GroupID: 2,
-			PermsID: 19,
-		},
-		{
-			GroupID: 2,
-			PermsID: 20,
-		},
-		{
-			GroupID: 2,
-			PermsID: 21,
-		},
-		{
-			GroupID: 2,
-			PermsID: 22,
-		},
-		{
-			GroupID: 2,
-			PermsID: 23,
-		},
-		{
-			GroupID: 2,
-			PermsID: 24,
-		},
-		{
-			GroupID: 2,
-			PermsID: 25,
-		},
+	for _, change := range changes {
+		logger.Info("Изменение политики доступа", "op", change.Op, "change", change.Text)
 	}
+	if cfg.Seed.DryRun {
+		logger.Info("Политика доступа не применена (пробный запуск)", "file", path, "changes", len(changes))
+		return
+	}
+	logger.Info("Политика доступа применена", "file", path, "changes", len(changes))
+}
 
-	// Создание записей по умолчанию в режиме отладки
-	if os.Getenv("MODE") == "DEBUG" {
-		// Удаление таблиц из базы данных
-		err := db.Debug().DropTableIfExists(&models.Form{}, &models.ProfileLink{}, &models.SubscriptionReport{}, &models.Subscription{}, &models.GroupedUser{}, &models.User{}, &models.GroupPermission{}, &models.UserGroup{}, &models.Permission{}).Error
-		if err != nil {
-			log.Fatalf("Не удаётся удалить таблицу: %v", err)
-		}
-
-		// Автоматическая миграция  схемы базы данных
-		err = db.Debug().AutoMigrate(&models.User{}, &models.UserGroup{}, &models.GroupedUser{}, &models.Permission{}, &models.GroupPermission{}, &models.Subscription{}, &models.ProfileLink{}, &models.SubscriptionReport{}, &models.Form{}).Error
-		if err != nil {
-			log.Fatalf("Не удаётся произвести миграцию: %v", err)
-		}
+// ApplyPolicy - Применение политики доступа из файла path: возвращает список изменений и записывает их
+// в базу данных (при dryRun изменения только возвращаются)
+func ApplyPolicy(db *gorm.DB, path string, dryRun bool) ([]Change, error) {
+	policy, err := LoadPolicy(path)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := policy.Plan(db)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return changes, nil
+	}
+	err = Apply(db, changes)
+	if err != nil {
+		return changes, err
+	}
+	return changes, nil
+}
 
-		// Установка внешних ключей (связей)
-		err = db.Debug().Model(&models.GroupedUser{}).AddForeignKey("user_id", "users(id)", "cascade", "cascade").Error
-		if err != nil {
-			log.Fatalf("Установка внешнего ключа завершилась неудачей (grouped_users -> users): %v", err)
-		}
-		err = db.Debug().Model(&models.GroupedUser{}).AddForeignKey("group_id", "user_groups(id)", "cascade", "cascade").Error
-		if err != nil {
-			log.Fatalf("Установка внешнего ключа завершилась неудачей (grouped_users -> user_group): %v", err)
-		}
-		err = db.Debug().Model(&models.GroupPermission{}).AddForeignKey("perms_id", "permissions(id)", "cascade", "cascade").Error
-		if err != nil {
-			log.Fatalf("Установка внешнего ключа завершилась неудачей (group_permissions -> permissions): %v", err)
-		}
-		err = db.Debug().Model(&models.GroupPermission{}).AddForeignKey("group_id", "user_groups(id)", "cascade", "cascade").Error
-		if err != nil {
-			log.Fatalf("Установка внешнего ключа завершилась неудачей (group_permissions -> user_group): %v", err)
-		}
-		err = db.Debug().Model(&models.Form{}).AddForeignKey("author_id", "users(id)", "cascade", "cascade").Error
-		if err != nil {
-			log.Fatalf("Установка внешнего ключа завершилась неудачей (forms -> users): %v", err)
-		}
-		err = db.Debug().Model(&models.Subscription{}).AddForeignKey("author_id", "users(id)", "cascade", "cascade").Error
-		if err != nil {
-			log.Fatalf("Установка внешнего ключа завершилась неудачей (subscriptions -> users): %v", err)
-		}
-		err = db.Debug().Model(&models.ProfileLink{}).AddForeignKey("author_id", "users(id)", "cascade", "cascade").Error
-		if err != nil {
-			log.Fatalf("Установка внешнего ключа завершилась неудачей (profileLinks -> users): %v", err)
-		}
-		err = db.Debug().Model(&models.ProfileLink{}).AddForeignKey("profile_id", "subscriptions(id)", "cascade", "cascade").Error
-		if err != nil {
-			log.Fatalf("Установка внешнего ключа завершилась неудачей (profileLinks -> subscriptions): %v", err)
-		}
-		err = db.Debug().Model(&models.SubscriptionReport{}).AddForeignKey("profile_id", "subscriptions(id)", "cascade", "cascade").Error
-		if err != nil {
-			log.Fatalf("Установка внешнего ключа завершилась неудачей (subscription report -> users): %v", err)
-		}
-		err = db.Debug().Model(&models.SubscriptionReport{}).AddForeignKey("profile_id", "subscriptions(id)", "cascade", "cascade").Error
-		if err != nil {
-			log.Fatalf("Установка внешнего ключа завершилась неудачей (subscription report -> subscriptions): %v", err)
-		}
+// reset - Удаление таблиц и создание схемы базы данных миграциями
+func reset(db *gorm.DB) {
+	// Удаление таблиц из базы данных
+	err := db.DropTableIfExists(&models.Form{}, &models.ProfileLink{}, &models.SubscriptionReport{}, &models.Subscription{}, &models.GroupedUser{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.PasswordReset{}, &models.APIKey{}, &models.RecoveryCode{}, &models.LoginAttempt{}, &models.PermissionVersion{}, &models.User{}, &models.GroupPermission{}, &models.UserGroup{}, &models.Permission{}, "schema_migrations").Error
+	if err != nil {
+		logger.Fatal("Не удаётся удалить таблицу", "error", err)
+	}
 
-		// Запись записей по умолчанию
-		for i := range users {
-			err = db.Debug().Model(&models.User{}).Create(&users[i]).Error
-			if err != nil {
-				log.Fatalf("Не удаётся добавить пользователей: %v", err)
-			}
-		}
-		for i := range groups {
-			err = db.Debug().Model(&models.UserGroup{}).Create(&groups[i]).Error
-			if err != nil {
-				log.Fatalf("Не удаётся добавить группы пользователей: %v", err)
-			}
-		}
-		for i := range groupedUsers {
-			err = db.Debug().Model(&models.GroupedUser{}).Create(&groupedUsers[i]).Error
-			if err != nil {
-				log.Fatalf("Не удаётся добавить соответствие пользователя и группы пользователей: %v", err)
-			}
-		}
-		for i := range permissions {
-			err = db.Debug().Model(&models.Permission{}).Create(&permissions[i]).Error
-			if err != nil {
-				log.Fatalf("Не удаётся добавить права пользователей: %v", err)
-			}
-		}
-		for i := range groupPermissions {
-			err = db.Debug().Model(&models.GroupPermission{}).Create(&groupPermissions[i]).Error
-			if err != nil {
-				log.Fatalf("Не удаётся добавить соответствие группы пользователей и прав пользователей: %v", err)
-			}
-		}
+	// Создание таблиц, индексов и внешних ключей
+	_, err = migrations.Up(db)
+	if err != nil {
+		logger.Fatal("Не удаётся произвести миграцию", "error", err)
 	}
 }
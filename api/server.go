@@ -5,24 +5,138 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 
+	"github.com/doka-guide/api/api/config"
 	"github.com/doka-guide/api/api/controllers"
 	"github.com/doka-guide/api/api/seed"
-
-	"github.com/joho/godotenv"
+	"github.com/doka-guide/api/api/utils/logger"
 )
 
 var server = controllers.Server{}
 
+// Command - команда командной строки
+type Command struct {
+	Usage       string
+	Description string
+	Run         func(args []string) error
+}
+
+// commands - команды командной строки по названиям
+var commands = map[string]Command{
+	"serve": {
+		Usage:       "serve",
+		Description: "запуск сервера (команда по умолчанию)",
+		Run:         serve,
+	},
+	"migrate": {
+		Usage:       "migrate up|down [N]|status",
+		Description: "применение, откат и состояние миграций базы данных",
+		Run:         migrate,
+	},
+	"seed": {
+		Usage:       "seed [--file policy.json] [--dry-run]",
+		Description: "применение политики доступа без запуска сервера",
+		Run:         seedPolicy,
+	},
+	"create-admin": {
+		Usage:       "create-admin --email адрес [--nickname имя] [--password пароль] [--group группа]",
+		Description: "создание администратора или добавление пользователя в группу администраторов",
+		Run:         createAdmin,
+	},
+	"reset-password": {
+		Usage:       "reset-password --email адрес [--password пароль]",
+		Description: "смена пароля пользователя и отзыв его токенов обновления",
+		Run:         resetPassword,
+	},
+	"export-subscribers": {
+		Usage:       "export-subscribers [--from дата] [--to дата] [--format csv|json] [--output файл]",
+		Description: "выгрузка подписчиков со ссылками на профиль",
+		Run:         exportSubscribers,
+	},
+	"send-test-mail": {
+		Usage:       "send-test-mail --to адрес",
+		Description: "отправка тестового письма для проверки настроек почты",
+		Run:         sendTestMail,
+	},
+	"check-config": {
+		Usage:       "check-config",
+		Description: "проверка параметров, шаблонов, ключей и соединения с базой данных",
+		Run:         checkConfig,
+	},
+}
+
+// Main - Выполнение команды из аргументов командной строки (без аргументов запускается сервер)
+func Main(args []string) {
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return
+	}
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Неизвестная команда: %s\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+	err := command.Run(args)
+	if err != nil {
+		logger.Fatal("Ошибка выполнения команды", "command", name, "error", err)
+	}
+}
+
+// Run - Запуск сервера
 func Run() {
-	var err = godotenv.Load()
+	Main(nil)
+}
+
+// usage - Вывод списка команд
+func usage() {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "Команды:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n      %s\n", commands[name].Usage, commands[name].Description)
+	}
+}
+
+// serve - Команда serve: миграции, политика доступа и запуск сервера
+func serve(args []string) error {
+	cfg := loadConfig()
+	server.Initialize(cfg)
+	seed.Load(server.DB, cfg)
+	return server.Run(cfg.Address())
+}
+
+// loadConfig - Чтение настроек из окружения, файла '.env' и файла CONFIG_FILE и настройка журнала;
+// при ошибках выводит сразу все проблемы и завершает работу
+func loadConfig() *config.Config {
+	cfg, err := config.Load(".env")
+	if cfg != nil {
+		setupLogger(cfg.Log)
+	}
 	if err != nil {
-		log.Fatalf("Не могу получить доступ к файлу '.env': %v", err)
-	} else {
-		fmt.Println("Значения из файла '.env' получены.")
+		logger.Fatal("Ошибка в настройках", "error", err)
 	}
+	return cfg
+}
+
+// setupLogger - Настройка журнала по умолчанию; записи стандартного пакета log тоже попадают в него
+func setupLogger(cfg config.Log) {
+	level, _ := logger.ParseLevel(cfg.Level)
+	logger.SetDefault(logger.New(os.Stderr, level, cfg.Format == "json"))
+	log.SetFlags(0)
+	log.SetOutput(logger.Default().Writer(logger.LevelInfo))
+}
 
-	server.Initialize(os.Getenv("DB_DRIVER"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_PORT"), os.Getenv("DB_HOST"), os.Getenv("DB_NAME"))
-	seed.Load(server.DB)
-	server.Run(os.Getenv("APP_HOST") + ":" + os.Getenv("APP_PORT"))
+// connect - Чтение настроек и подключение к базе данных для команд, которым не нужен сервер
+func connect() (*config.Config, error) {
+	cfg := loadConfig()
+	return cfg, server.Connect(cfg.DB)
 }
@@ -0,0 +1,274 @@
+// Package logger - пакет для структурированного журнала с уровнями важности
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level - уровень важности записи журнала
+type Level int
+
+// Уровни важности по возрастанию
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String - Название уровня важности
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	}
+	return "error"
+}
+
+// ParseLevel - Уровень важности по названию: debug, info, warn или error
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("неизвестный уровень журнала '%s'", name)
+}
+
+// Logger - журнал: записи в формате JSON (по одной на строку) или в виде текста для чтения в терминале
+type Logger struct {
+	out   *output
+	level Level
+	json  bool
+	// Поля, которые добавляются к каждой записи (например, идентификатор запроса)
+	fields []interface{}
+}
+
+// output - место записи журнала, общее для всех производных журналов
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// New - Создание журнала: записи ниже level пропускаются
+func New(w io.Writer, level Level, json bool) *Logger {
+	return &Logger{out: &output{w: w}, level: level, json: json}
+}
+
+// std - журнал по умолчанию
+var std = New(os.Stderr, LevelInfo, false)
+
+// SetDefault - Замена журнала по умолчанию
+func SetDefault(l *Logger) {
+	std = l
+}
+
+// Default - Журнал по умолчанию
+func Default() *Logger {
+	return std
+}
+
+// With - Журнал, который добавляет к каждой записи поля в виде пар ключ-значение
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+	return &Logger{out: l.out, level: l.level, json: l.json, fields: fields}
+}
+
+// Enabled - Попадут ли в журнал записи уровня level
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug - Отладочная запись
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.Log(LevelDebug, msg, keyvals...)
+}
+
+// Info - Информационная запись
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.Log(LevelInfo, msg, keyvals...)
+}
+
+// Warn - Предупреждение
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.Log(LevelWarn, msg, keyvals...)
+}
+
+// Error - Ошибка
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.Log(LevelError, msg, keyvals...)
+}
+
+// Fatal - Ошибка, после которой работа приложения завершается
+func (l *Logger) Fatal(msg string, keyvals ...interface{}) {
+	l.Log(LevelError, msg, keyvals...)
+	os.Exit(1)
+}
+
+// Log - Запись уровня level с сообщением msg и полями в виде пар ключ-значение
+func (l *Logger) Log(level Level, msg string, keyvals ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+	if len(fields)%2 == 1 {
+		fields = append(fields, "(нет значения)")
+	}
+
+	var line string
+	if l.json {
+		line = formatJSON(time.Now(), level, msg, fields)
+	} else {
+		line = formatText(time.Now(), level, msg, fields)
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	io.WriteString(l.out.w, line)
+}
+
+// formatJSON - Запись в виде объекта JSON: время, уровень и сообщение идут первыми
+func formatJSON(t time.Time, level Level, msg string, fields []interface{}) string {
+	var b strings.Builder
+	b.WriteString(`{"time":`)
+	writeJSON(&b, t.UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, level.String())
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, msg)
+	for i := 0; i < len(fields); i += 2 {
+		b.WriteString(",")
+		writeJSON(&b, fmt.Sprint(fields[i]))
+		b.WriteString(":")
+		writeJSON(&b, jsonValue(fields[i+1]))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// jsonValue - Значение поля для JSON: ошибки и значения с методом String записываются строкой
+func jsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case error:
+		return value.Error()
+	case time.Duration:
+		return value.String()
+	case fmt.Stringer:
+		return value.String()
+	}
+	return v
+}
+
+// writeJSON - Запись значения в JSON; значения, которые не кодируются, записываются строкой
+func writeJSON(b *strings.Builder, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
+// formatText - Запись в виде строки: время, уровень, сообщение и поля key=value
+func formatText(t time.Time, level Level, msg string, fields []interface{}) string {
+	var b strings.Builder
+	b.WriteString(t.Format("2006-01-02 15:04:05.000"))
+	b.WriteString(" ")
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(fields); i += 2 {
+		b.WriteString(" ")
+		b.WriteString(fmt.Sprint(fields[i]))
+		b.WriteString("=")
+		b.WriteString(textValue(fields[i+1]))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// textValue - Значение поля для текстовой записи: строки с пробелами и кавычками берутся в кавычки
+func textValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// Writer - Приёмник для стандартного пакета log: каждая строка становится записью уровня level
+func (l *Logger) Writer(level Level) io.Writer {
+	return writer{logger: l, level: level}
+}
+
+// writer - приёмник строк для стандартного пакета log
+type writer struct {
+	logger *Logger
+	level  Level
+}
+
+// Write - Запись строки в журнал
+func (w writer) Write(p []byte) (int, error) {
+	w.logger.Log(w.level, strings.TrimRight(string(p), "\r\n"))
+	return len(p), nil
+}
+
+// contextKey - ключ журнала запроса в контексте
+type contextKey struct{}
+
+// NewContext - Контекст с журналом запроса
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext - Журнал запроса из контекста; если его нет, журнал по умолчанию
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return std
+}
+
+// Debug - Отладочная запись в журнал по умолчанию
+func Debug(msg string, keyvals ...interface{}) {
+	std.Debug(msg, keyvals...)
+}
+
+// Info - Информационная запись в журнал по умолчанию
+func Info(msg string, keyvals ...interface{}) {
+	std.Info(msg, keyvals...)
+}
+
+// Warn - Предупреждение в журнал по умолчанию
+func Warn(msg string, keyvals ...interface{}) {
+	std.Warn(msg, keyvals...)
+}
+
+// Error - Ошибка в журнал по умолчанию
+func Error(msg string, keyvals ...interface{}) {
+	std.Error(msg, keyvals...)
+}
+
+// Fatal - Ошибка в журнал по умолчанию и завершение работы приложения
+func Fatal(msg string, keyvals ...interface{}) {
+	std.Fatal(msg, keyvals...)
+}
@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+)
+
+// SQL - журнал запросов к базе данных для gorm (метод Print). Значения параметров запроса
+// (адреса почты, хеши паролей, токены) не записываются: в журнал попадает только текст запроса
+// с подстановками $1, $2, ... и количество параметров
+type SQL struct {
+	Logger *Logger
+}
+
+// Print - Запись сообщения gorm: ["sql", источник, длительность, запрос, параметры, число строк],
+// ["error", источник, ошибка] или ["log", источник, ошибка] (gorm записывает так ошибки запросов)
+func (s SQL) Print(values ...interface{}) {
+	if len(values) < 2 {
+		return
+	}
+	if values[0] == "sql" && len(values) >= 6 {
+		duration, _ := values[2].(time.Duration)
+		params, _ := values[4].([]interface{})
+		s.Logger.Info("SQL",
+			"query", values[3],
+			"params", len(params),
+			"rows", values[5],
+			"duration_ms", float64(duration.Microseconds())/1000,
+			"source", values[1],
+		)
+		return
+	}
+	s.Logger.Error("Ошибка базы данных", "error", fmt.Sprint(values[2:]...), "source", values[1])
+}
@@ -2,23 +2,102 @@
 package mail
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/mail"
 	"net/smtp"
-	"os"
+	"regexp"
+	"sync"
 	"time"
 
+	"github.com/doka-guide/api/api/utils/logger"
+	"github.com/doka-guide/api/api/utils/metrics"
 	"github.com/doka-guide/api/api/utils/randomize"
 )
 
-// SendMail – отправка письма по SSL/TLS соединению
-func SendMail(toSender string, toAddress string, subj string, textBody string, htmlBody string, isBulk bool) error {
+// Mailer – отправитель писем через SMTP-сервер из настроек
+type Mailer struct {
+	// Адрес сервера в виде host:port
+	Host     string
+	User     string
+	Password string
+	Sender   string
+	// Ссылка для отписки от рассылки (заголовок List-Unsubscribe)
+	UnsubscribeURL string
+
+	// Письма, отправляемые в фоне: при остановке сервера их отправка дожидается завершения
+	jobs    sync.WaitGroup
+	mu      sync.Mutex
+	stopped bool
+}
+
+// SendAsync – отправка письма в фоне; ошибка отправки записывается в журнал.
+// После вызова Wait письма отправляются сразу, без фона
+func (m *Mailer) SendAsync(toSender string, toAddress string, subj string, textBody string, htmlBody string, isBulk bool) {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		m.logError(m.Send(toSender, toAddress, subj, textBody, htmlBody, isBulk))
+		return
+	}
+	m.jobs.Add(1)
+	m.mu.Unlock()
+
+	go func() {
+		defer m.jobs.Done()
+		m.logError(m.Send(toSender, toAddress, subj, textBody, htmlBody, isBulk))
+	}()
+}
+
+// Wait – ожидание отправки фоновых писем, но не дольше, чем позволяет ctx
+func (m *Mailer) Wait(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopped = true
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// logError – запись ошибки отправки письма в журнал (без адреса получателя)
+func (m *Mailer) logError(err error) {
+	if err != nil {
+		logger.Error("Ошибка отправки письма", "error", err)
+	}
+}
+
+// sentTotal – количество отправленных писем и ошибок отправки
+var sentTotal = metrics.NewCounterVec("doka_mail_sent_total", "Количество писем по результату отправки: success или failure", "result")
+
+// Send – отправка письма по SSL/TLS соединению
+func (m *Mailer) Send(toSender string, toAddress string, subj string, textBody string, htmlBody string, isBulk bool) error {
+	err := m.send(toSender, toAddress, subj, textBody, htmlBody, isBulk)
+	if err != nil {
+		sentTotal.Inc("failure")
+	} else {
+		sentTotal.Inc("success")
+	}
+	return err
+}
+
+// send – формирование письма и отправка через SMTP-сервер
+func (m *Mailer) send(toSender string, toAddress string, subj string, textBody string, htmlBody string, isBulk bool) error {
 	to := mail.Address{Name: toSender, Address: toAddress}
 	from := mail.Address{
-		Name:    os.Getenv("MAIL_SENDER"),
-		Address: os.Getenv("MAIL_USER"),
+		Name:    m.Sender,
+		Address: m.User,
 	}
 
 	// Формирование уникального разделителя
@@ -33,11 +112,11 @@ func SendMail(toSender string, toAddress string, subj string, textBody string, h
 	headers["Subject"] = subj
 	if isBulk {
 		headers["Precedence"] = "bulk"
-		headers["Reply-To"] = os.Getenv("MAIL_SENDER")
-		headers["List-Unsubscribe"] = "<mailto:" + os.Getenv("MAIL_USER") + ">, <" + os.Getenv("MAIL_URL") + ">"
+		headers["Reply-To"] = m.Sender
+		headers["List-Unsubscribe"] = "<mailto:" + m.User + ">, <" + m.UnsubscribeURL + ">"
 	}
 	headers["Content-Type"] = "multipart/alternative; boundary=\"" + boundary + "\""
-	headers["X-Sender"] = os.Getenv("MAIL_SENDER")
+	headers["X-Sender"] = m.Sender
 	headers["User-Agent"] = "Doka API"
 
 	// Формирование заголовков письма
@@ -75,12 +154,12 @@ func SendMail(toSender string, toAddress string, subj string, textBody string, h
 	message += "\r\n" + "--" + boundary + "--" + "\r\n"
 
 	// Соединение с SMTP-сервером
-	serverName := os.Getenv("MAIL_HOST")
+	serverName := m.Host
 	host, _, _ := net.SplitHostPort(serverName)
 	auth := smtp.PlainAuth(
 		"Hi",
-		os.Getenv("MAIL_USER"),
-		os.Getenv("MAIL_PASS"),
+		m.User,
+		m.Password,
 		host,
 	)
 
@@ -138,3 +217,17 @@ func SendMail(toSender string, toAddress string, subj string, textBody string, h
 
 	return nil
 }
+
+// RenderTemplate – чтение шаблона письма и подстановка значений вместо переменных вида {{ name }}
+func RenderTemplate(path string, vars map[string]string) (string, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	result := string(body)
+	for name, value := range vars {
+		varRegex := regexp.MustCompile(`{{ ` + regexp.QuoteMeta(name) + ` }}`)
+		result = varRegex.ReplaceAllLiteralString(result, value)
+	}
+	return result, nil
+}
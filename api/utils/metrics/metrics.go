@@ -0,0 +1,295 @@
+// Package metrics - пакет для метрик в текстовом формате Prometheus
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OtherLabel - значение меток для новых рядов сверх ограничения Limit
+const OtherLabel = "other"
+
+// DefBuckets - границы корзин гистограммы длительности в секундах
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector - метрика, которая умеет записать себя в текстовом формате
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry - набор метрик
+type Registry struct {
+	mu         sync.Mutex
+	names      []string
+	collectors map[string]collector
+}
+
+// NewRegistry - Создание пустого набора метрик
+func NewRegistry() *Registry {
+	return &Registry{collectors: map[string]collector{}}
+}
+
+// Default - набор метрик по умолчанию, который отдаёт Handler
+var Default = NewRegistry()
+
+// register - Добавление метрики; метрика с тем же названием заменяется
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.collectors[name] = c
+}
+
+// Write - Запись всех метрик в текстовом формате Prometheus
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	names := append([]string{}, r.names...)
+	collectors := make([]collector, 0, len(names))
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.Unlock()
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler - Обработчик, который отдаёт метрики набора по умолчанию
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Default.Write(w)
+	})
+}
+
+// desc - название, описание и метки метрики
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+// header - Запись строк HELP и TYPE
+func (d *desc) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.Replace(d.help, "\n", " ", -1))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// series - ряды метрики по значениям меток
+type series struct {
+	mu     sync.Mutex
+	keys   []string
+	values map[string][]string
+	// Наибольшее число рядов; новые ряды сверх него попадают в ряд со значениями меток OtherLabel
+	limit int
+}
+
+// key - Ключ ряда по значениям меток; при превышении ограничения — ключ ряда OtherLabel
+func (s *series) key(d *desc, values []string) (string, bool) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: у %s метки %v, передано значений: %d", d.name, d.labels, len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	if _, ok := s.values[key]; ok {
+		return key, false
+	}
+	if s.limit > 0 && len(s.keys) >= s.limit {
+		other := make([]string, len(values))
+		for i := range other {
+			other[i] = OtherLabel
+		}
+		values = other
+		key = strings.Join(values, "\xff")
+		if _, ok := s.values[key]; ok {
+			return key, false
+		}
+	}
+	s.keys = append(s.keys, key)
+	s.values[key] = values
+	return key, true
+}
+
+// sorted - Ключи рядов по алфавиту
+func (s *series) sorted() []string {
+	keys := append([]string{}, s.keys...)
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec - счётчик с метками
+type CounterVec struct {
+	desc
+	series
+	counts map[string]float64
+}
+
+// NewCounterVec - Создание счётчика с метками в наборе по умолчанию
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		desc:   desc{name: name, help: help, kind: "counter", labels: labels},
+		series: series{values: map[string][]string{}},
+		counts: map[string]float64{},
+	}
+	Default.register(name, c)
+	return c
+}
+
+// Limit - Ограничение числа рядов для меток из запроса пользователя
+func (c *CounterVec) Limit(n int) *CounterVec {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limit = n
+	return c
+}
+
+// Inc - Увеличение счётчика на единицу
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add - Увеличение счётчика на v
+func (c *CounterVec) Add(v float64, values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, _ := c.key(&c.desc, values)
+	c.counts[key] += v
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w)
+	for _, key := range c.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labels(c.labels, c.values[key], "", ""), number(c.counts[key]))
+	}
+}
+
+// HistogramVec - гистограмма с метками
+type HistogramVec struct {
+	desc
+	series
+	buckets []float64
+	counts  map[string][]uint64
+	sums    map[string]float64
+	totals  map[string]uint64
+}
+
+// NewHistogramVec - Создание гистограммы с метками в наборе по умолчанию
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		series:  series{values: map[string][]string{}},
+		buckets: append([]float64{}, buckets...),
+		counts:  map[string][]uint64{},
+		sums:    map[string]float64{},
+		totals:  map[string]uint64{},
+	}
+	sort.Float64s(h.buckets)
+	Default.register(name, h)
+	return h
+}
+
+// Limit - Ограничение числа рядов для меток из запроса пользователя
+func (h *HistogramVec) Limit(n int) *HistogramVec {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.limit = n
+	return h
+}
+
+// Observe - Учёт значения v
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key, created := h.key(&h.desc, values)
+	if created {
+		h.counts[key] = make([]uint64, len(h.buckets))
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[key][i]++
+		}
+	}
+	h.sums[key] += v
+	h.totals[key]++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	for _, key := range h.sorted() {
+		values := h.values[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels(h.labels, values, "le", number(bound)), h.counts[key][i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels(h.labels, values, "le", "+Inf"), h.totals[key])
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels(h.labels, values, "", ""), number(h.sums[key]))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels(h.labels, values, "", ""), h.totals[key])
+	}
+}
+
+// funcMetric - метрика без меток, значение которой вычисляется при чтении
+type funcMetric struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc - Создание показателя, значение которого возвращает fn
+func NewGaugeFunc(name, help string, fn func() float64) {
+	Default.register(name, &funcMetric{desc: desc{name: name, help: help, kind: "gauge"}, fn: fn})
+}
+
+// NewCounterFunc - Создание счётчика, значение которого возвращает fn (например, из статистики пакета database/sql)
+func NewCounterFunc(name, help string, fn func() float64) {
+	Default.register(name, &funcMetric{desc: desc{name: name, help: help, kind: "counter"}, fn: fn})
+}
+
+func (f *funcMetric) write(w io.Writer) {
+	f.header(w)
+	fmt.Fprintf(w, "%s %s\n", f.name, number(f.fn()))
+}
+
+// labels - Запись меток {name="value",...}; extraName добавляет метку le для корзин гистограммы
+func labels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	parts := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		parts = append(parts, name+`="`+escape(values[i])+`"`)
+	}
+	if extraName != "" {
+		parts = append(parts, extraName+`="`+extraValue+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// escape - Экранирование значения метки
+func escape(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	return strings.Replace(value, "\n", `\n`, -1)
+}
+
+// number - Запись числа в формате Prometheus
+func number(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
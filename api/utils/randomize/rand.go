@@ -1,6 +1,8 @@
 package randomize
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"math/rand"
 	"time"
 )
@@ -16,3 +18,12 @@ func GetRandomString(length int) string {
 	}
 	return string(b)
 }
+
+// GetSecureToken — генерация криптографически стойкой строки из size случайных байт в шестнадцатеричном виде
+func GetSecureToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,87 @@
+// Package rbac - пакет для сопоставления разрешений с шаблонами и запретами
+package rbac
+
+import "strings"
+
+// Wildcard – символ шаблона, который заменяет любую последовательность символов
+const Wildcard = "*"
+
+// AnySuffix – окончание разрешения на операции с любыми записями (FORM-DELETE-ANY)
+const AnySuffix = "-ANY"
+
+// Grant – разрешение группы: название или шаблон (FORM-*, *-GET, *) и признак запрета
+type Grant struct {
+	Pattern string
+	Deny    bool
+}
+
+// Match – проверка, подходит ли название разрешения под шаблон. Звёздочка заменяет любую
+// последовательность символов, включая дефис, поэтому SUBSCRIPTION-* подходит и для SUBSCRIPTION-REPORT-GET
+func Match(pattern string, name string) bool {
+	if !strings.Contains(pattern, Wildcard) {
+		return pattern == name
+	}
+	parts := strings.Split(pattern, Wildcard)
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return strings.HasSuffix(name, last)
+}
+
+// Allowed – проверка разрешения по набору правил. Порядок проверки:
+//  1. подходящий запрет важнее любых разрешений (в том числе шаблонов вроде FORM-*);
+//  2. запрет PERM действует и на PERM-ANY: запретив удалять свои записи, нельзя разрешить удалять чужие,
+//     а запрет *-GET подходит и для FORM-GET-ANY. Запрет PERM-ANY на PERM не действует;
+//  3. без подходящего запрета операция разрешена, если подходит хотя бы одно разрешение;
+//  4. без подходящих правил операция запрещена
+func Allowed(grants []Grant, name string) bool {
+	base := strings.TrimSuffix(name, AnySuffix)
+	allowed := false
+	for _, grant := range grants {
+		if grant.Deny {
+			if Match(grant.Pattern, name) || Match(grant.Pattern, base) {
+				return false
+			}
+			continue
+		}
+		if Match(grant.Pattern, name) {
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// Split – разделение правил на разрешения и запреты (в таком виде они записываются в токен)
+func Split(grants []Grant) ([]string, []string) {
+	allow := []string{}
+	deny := []string{}
+	for _, grant := range grants {
+		if grant.Deny {
+			deny = append(deny, grant.Pattern)
+		} else {
+			allow = append(allow, grant.Pattern)
+		}
+	}
+	return allow, deny
+}
+
+// Join – объединение разрешений и запретов в набор правил
+func Join(allow []string, deny []string) []Grant {
+	grants := make([]Grant, 0, len(allow)+len(deny))
+	for _, pattern := range allow {
+		grants = append(grants, Grant{Pattern: pattern})
+	}
+	for _, pattern := range deny {
+		grants = append(grants, Grant{Pattern: pattern, Deny: true})
+	}
+	return grants
+}
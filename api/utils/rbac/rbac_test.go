@@ -0,0 +1,72 @@
+package rbac
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"FORM-GET", "FORM-GET", true},
+		{"FORM-GET", "FORM-GET-ANY", false},
+		{"FORM-*", "FORM-DELETE", true},
+		{"FORM-*", "SUBSCRIPTION-GET", false},
+		{"*-GET", "USER-GET", true},
+		{"*-GET", "FORM-GET-ANY", false},
+		{"SUBSCRIPTION-*", "SUBSCRIPTION-REPORT-GET", true},
+		{"*", "ANYTHING", true},
+		{"FORM-*-ANY", "FORM-DELETE-ANY", true},
+		{"FORM-*-ANY", "FORM-DELETE", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		title  string
+		grants []Grant
+		name   string
+		want   bool
+	}{
+		{"no grants", nil, "FORM-GET", false},
+		{"exact grant", []Grant{{Pattern: "FORM-GET"}}, "FORM-GET", true},
+		{"exact grant other name", []Grant{{Pattern: "FORM-GET"}}, "FORM-POST", false},
+		{"exact grant does not cover ANY", []Grant{{Pattern: "FORM-GET"}}, "FORM-GET-ANY", false},
+		{"wildcard grant", []Grant{{Pattern: "FORM-*"}}, "FORM-DELETE", true},
+		{"wildcard grant covers ANY", []Grant{{Pattern: "FORM-*"}}, "FORM-DELETE-ANY", true},
+		{"global wildcard grant", []Grant{{Pattern: "*"}}, "USER-UNLOCK", true},
+		{"exact deny", []Grant{{Pattern: "FORM-DELETE", Deny: true}}, "FORM-DELETE", false},
+		{"wildcard deny", []Grant{{Pattern: "*-DELETE", Deny: true}}, "FORM-DELETE", false},
+		{"exact deny beats wildcard grant", []Grant{{Pattern: "FORM-*"}, {Pattern: "FORM-DELETE", Deny: true}}, "FORM-DELETE", false},
+		{"wildcard deny beats exact grant", []Grant{{Pattern: "FORM-GET"}, {Pattern: "*-GET", Deny: true}}, "FORM-GET", false},
+		{"deny order does not matter", []Grant{{Pattern: "FORM-DELETE", Deny: true}, {Pattern: "FORM-*"}}, "FORM-DELETE", false},
+		{"deny leaves other grants", []Grant{{Pattern: "FORM-*"}, {Pattern: "FORM-DELETE", Deny: true}}, "FORM-GET", true},
+		{"deny covers ANY", []Grant{{Pattern: "FORM-*"}, {Pattern: "FORM-DELETE", Deny: true}}, "FORM-DELETE-ANY", false},
+		{"wildcard deny covers ANY", []Grant{{Pattern: "*"}, {Pattern: "*-GET", Deny: true}}, "FORM-GET-ANY", false},
+		{"deny of ANY leaves own", []Grant{{Pattern: "FORM-*"}, {Pattern: "FORM-DELETE-ANY", Deny: true}}, "FORM-DELETE", true},
+		{"deny of ANY", []Grant{{Pattern: "FORM-*"}, {Pattern: "FORM-DELETE-ANY", Deny: true}}, "FORM-DELETE-ANY", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := Allowed(tt.grants, tt.name); got != tt.want {
+				t.Errorf("Allowed(%v, %q) = %v, want %v", tt.grants, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitJoin(t *testing.T) {
+	grants := []Grant{{Pattern: "FORM-*"}, {Pattern: "FORM-DELETE", Deny: true}}
+	allow, deny := Split(grants)
+	if len(allow) != 1 || allow[0] != "FORM-*" || len(deny) != 1 || deny[0] != "FORM-DELETE" {
+		t.Fatalf("Split(%v) = %v, %v", grants, allow, deny)
+	}
+	if Allowed(Join(allow, deny), "FORM-DELETE-ANY") {
+		t.Error("deny lost after Split and Join")
+	}
+}
@@ -0,0 +1,163 @@
+// Package throttle - пакет для ограничения частоты неудачных попыток (защита от перебора паролей)
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Attempts – состояние счётчика неудачных попыток
+type Attempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store – хранилище счётчиков неудачных попыток
+type Store interface {
+	// Get – текущее состояние счётчика (нулевое, если попыток не было)
+	Get(key string) (Attempts, error)
+	// Increment – учёт неудачной попытки, возвращает новое состояние счётчика
+	Increment(key string, now time.Time) (Attempts, error)
+	// Lock – блокировка до момента until
+	Lock(key string, until time.Time) error
+	// Reset – сброс счётчика и блокировки
+	Reset(key string) error
+}
+
+// Limiter – правила задержек и блокировок поверх хранилища счётчиков
+type Limiter struct {
+	Store Store
+
+	// FreeAttempts – количество неудачных попыток без задержки
+	FreeAttempts int
+	// MaxFailures – количество неудачных попыток, после которого включается блокировка
+	MaxFailures int
+	// BaseDelay – задержка после первой попытки сверх FreeAttempts, дальше она удваивается
+	BaseDelay time.Duration
+	// MaxDelay – максимальная задержка между попытками
+	MaxDelay time.Duration
+	// LockoutDuration – длительность блокировки
+	LockoutDuration time.Duration
+	// Window – время без неудачных попыток, после которого счётчик начинается заново
+	Window time.Duration
+}
+
+// NewLimiter – создание правил со значениями по умолчанию
+func NewLimiter(store Store, maxFailures int, lockout time.Duration) *Limiter {
+	return &Limiter{
+		Store:           store,
+		FreeAttempts:    3,
+		MaxFailures:     maxFailures,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute * 5,
+		LockoutDuration: lockout,
+		Window:          time.Hour * 24,
+	}
+}
+
+// Check – время, которое нужно подождать до следующей попытки (0 – попытка разрешена)
+func (l *Limiter) Check(key string) (time.Duration, error) {
+	attempts, err := l.Store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	if now.Before(attempts.LockedUntil) {
+		return attempts.LockedUntil.Sub(now), nil
+	}
+	if attempts.Failures <= l.FreeAttempts || now.Sub(attempts.LastFailure) > l.Window {
+		return 0, nil
+	}
+	next := attempts.LastFailure.Add(l.delay(attempts.Failures))
+	if now.Before(next) {
+		return next.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// Fail – учёт неудачной попытки и, если их слишком много, блокировка
+func (l *Limiter) Fail(key string) error {
+	now := time.Now()
+	attempts, err := l.Store.Get(key)
+	if err != nil {
+		return err
+	}
+	if attempts.Failures > 0 && now.Sub(attempts.LastFailure) > l.Window {
+		if err = l.Store.Reset(key); err != nil {
+			return err
+		}
+	}
+	attempts, err = l.Store.Increment(key, now)
+	if err != nil {
+		return err
+	}
+	if l.MaxFailures > 0 && attempts.Failures >= l.MaxFailures && attempts.Failures%l.MaxFailures == 0 {
+		return l.Store.Lock(key, now.Add(l.LockoutDuration))
+	}
+	return nil
+}
+
+// Succeed – сброс счётчика после успешной попытки
+func (l *Limiter) Succeed(key string) error {
+	return l.Store.Reset(key)
+}
+
+// delay – экспоненциальная задержка после failures неудачных попыток
+func (l *Limiter) delay(failures int) time.Duration {
+	delay := l.BaseDelay
+	for i := l.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= l.MaxDelay {
+			return l.MaxDelay
+		}
+	}
+	return delay
+}
+
+// MemoryStore – хранилище счётчиков в памяти процесса (подходит для одного экземпляра API)
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempts
+}
+
+// NewMemoryStore – создание хранилища счётчиков в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: map[string]Attempts{}}
+}
+
+// Get – текущее состояние счётчика
+func (s *MemoryStore) Get(key string) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[key], nil
+}
+
+// Increment – учёт неудачной попытки
+func (s *MemoryStore) Increment(key string, now time.Time) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts := s.attempts[key]
+	attempts.Failures++
+	attempts.LastFailure = now
+	s.attempts[key] = attempts
+	return attempts, nil
+}
+
+// Lock – блокировка до момента until
+func (s *MemoryStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts := s.attempts[key]
+	attempts.LockedUntil = until
+	s.attempts[key] = attempts
+	return nil
+}
+
+// Reset – сброс счётчика и блокировки
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
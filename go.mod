@@ -3,15 +3,14 @@ module github.com/doka-guide/api
 go 1.17
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.0
 	github.com/badoux/checkmail v1.2.1
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/gorilla/mux v1.8.0
 	github.com/jinzhu/gorm v1.9.16
 	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.7
 	golang.org/x/crypto v0.6.0
 )
 
-require (
-	github.com/jinzhu/inflection v1.0.0 // indirect
-	github.com/lib/pq v1.10.7 // indirect
-)
+require github.com/jinzhu/inflection v1.0.0 // indirect
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"os"
+
 	"github.com/doka-guide/api/api"
 )
 
 func main() {
-	api.Run()
+	api.Main(os.Args[1:])
 }